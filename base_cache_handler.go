@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/merlinapp/datarepo-go/drreflect"
 	"github.com/spf13/cast"
+	"log"
 	"reflect"
 	"time"
 )
@@ -13,10 +14,20 @@ type baseCacheHandler struct {
 	keyPrefix string
 	// Name of the field that defines the key that will be used to store elements in the cache
 	keyFieldName string
+	// Fields of a composite key, nil if the key is a single field
+	keyFields []string
+	// Derives the key of the values instead of reading the keyFieldName field, nil if keys are read from the field
+	keyFunc KeyFunc
 	// expiration time of entries in the cache
 	expiration time.Duration
 	// handler used for reflection purposes - this represents the type of element to be stored in the cache
 	typeHandler drreflect.TypeHandler
+	// verifies a sample of the cache hits against the DataFetcher, nil if read-repair is disabled
+	readRepair *cacheReadRepair
+	// generation folded into the keys of the entries, nil if the cache isn't generational
+	generation *cacheGeneration
+	// receives the results filled into the cache, nil if caches aren't cross-populated
+	fillListener fillListener
 }
 
 func (c *baseCacheHandler) CachedType() reflect.Type {
@@ -31,42 +42,69 @@ func (c *baseCacheHandler) Delete(ctx context.Context, cacheStore CacheStore, ke
 	return cacheStore.Delete(ctx, c.cacheKey(key))
 }
 
-func (c *baseCacheHandler) Get(ctx context.Context, cacheStore CacheStore, key interface{}, fetcher DataFetcher) (Result, error) {
+func (c *baseCacheHandler) Get(ctx context.Context, cacheStore CacheStore, key interface{}, fetcher DataFetcher, options ReadOptions) (Result, error) {
+	if options.SkipCache {
+		result, err := fetcher.FindByKey(ctx, c.keyFieldName, key)
+		if err != nil {
+			return nil, err
+		}
+		return fetchedResult(result, options), nil
+	}
+
 	strKey := c.cacheKey(key)
 	cached := c.typeHandler.NewPtrToElement()
-	found, err := cacheStore.Get(ctx, strKey, cached.Ptr())
+	found, metadata, err := c.getFromStore(ctx, cacheStore, strKey, cached.Ptr(), options)
 	if err != nil {
 		return nil, err
 	}
 
 	if !found {
+		if options.CacheOnly {
+			return EmptyResult{}, nil
+		}
 		result, err := fetcher.FindByKey(ctx, c.keyFieldName, key)
 		if err != nil {
 			return nil, err
 		}
 		if !result.IsEmpty() {
 			cacheStore.Set(ctx, strKey, result.StoredValue(), c.expiration)
+			c.filled(ctx, []Result{result})
 		}
-		return result, err
+		return fetchedResult(result, options), err
 	}
 
-	return ValueResult{Value: cached.Ptr()}, nil
+	if !options.CacheOnly {
+		c.sampleReadRepair(cacheStore, fetcher, []interface{}{key})
+	}
+	return cachedResult(cached.Ptr(), metadata, options), nil
 }
 
-func (c *baseCacheHandler) GetMulti(ctx context.Context, cacheStore CacheStore, keys []interface{}, fetcher DataFetcher) ([]Result, error) {
+func (c *baseCacheHandler) GetMulti(ctx context.Context, cacheStore CacheStore, keys []interface{}, fetcher DataFetcher, options ReadOptions) ([]Result, error) {
+	if options.SkipCache {
+		results, err := fetcher.FindByKeys(ctx, c.keyFieldName, keys)
+		if err != nil {
+			return nil, err
+		}
+		for i := range results {
+			results[i] = fetchedResult(results[i], options)
+		}
+		return results, nil
+	}
+
 	strKeys := make([]string, len(keys))
 	for i, key := range keys {
 		strKeys[i] = c.cacheKey(key)
 	}
 	cached := c.typeHandler.NewPtrToSlice()
 	cached.MakeSlice(0, len(keys))
-	found, err := cacheStore.GetMulti(ctx, strKeys, cached.Ptr())
+	found, metadata, err := c.getMultiFromStore(ctx, cacheStore, strKeys, cached.Ptr(), options)
 	if err != nil {
 		return nil, err
 	}
 
 	missingKeyMap := make(map[interface{}]int)
 	missingKeys := make([]interface{}, 0, len(keys))
+	var hits []interface{}
 	results := make([]Result, len(keys))
 	proc := func(i int, handler drreflect.PointerVHandler) {
 		if !found[i] {
@@ -76,29 +114,145 @@ func (c *baseCacheHandler) GetMulti(ctx context.Context, cacheStore CacheStore,
 			}
 			results[i] = EmptyResult{}
 		} else {
-			results[i] = ValueResult{Value: handler.Element()}
+			results[i] = cachedResult(handler.Element(), metadata[i], options)
+			hits = append(hits, keys[i])
 		}
 	}
 	cached.ForEach(proc)
+	if !options.CacheOnly {
+		c.sampleReadRepair(cacheStore, fetcher, hits)
+	}
 
-	if len(missingKeys) > 0 {
+	if len(missingKeys) > 0 && !options.CacheOnly {
 		missingResults, err := fetcher.FindByKeys(ctx, c.keyFieldName, missingKeys)
 		if err != nil {
 			return nil, err
 		}
+		var filled []Result
 		for i, key := range keys {
 			if idx, ok := missingKeyMap[key]; ok {
 				results[i] = missingResults[idx]
 				if !results[i].IsEmpty() {
 					cacheStore.Set(ctx, strKeys[i], results[i].StoredValue(), c.expiration)
+					filled = append(filled, results[i])
 				}
+				results[i] = fetchedResult(results[i], options)
 			}
 		}
+		c.filled(ctx, filled)
 	}
 
 	return results, err
 }
 
+func (c *baseCacheHandler) Refresh(ctx context.Context, cacheStore CacheStore, keys []interface{}, fetcher DataFetcher) ([]Result, error) {
+	results, err := fetcher.FindByKeys(ctx, c.keyFieldName, keys)
+	if err != nil {
+		return nil, err
+	}
+	var filled []Result
+	for i, key := range keys {
+		strKey := c.cacheKey(key)
+		if results[i].IsEmpty() {
+			if err := cacheStore.Delete(ctx, strKey); err != nil {
+				return nil, err
+			}
+		} else {
+			cacheStore.Set(ctx, strKey, results[i].StoredValue(), c.expiration)
+			filled = append(filled, results[i])
+		}
+	}
+	c.filled(ctx, filled)
+	return results, nil
+}
+
+// stores the value of an entry updated by a write, deleting the entry if the value can't be stored so that
+// the previous value isn't left in the cache. The error of the write is returned so that the eviction of the
+// entry is retried if the delete fails too
+func (c *baseCacheHandler) setWritten(ctx context.Context, cacheStore CacheStore, key string, value interface{}) error {
+	err := TrySet(ctx, cacheStore, key, value, c.expiration)
+	if err == nil {
+		return nil
+	}
+	if dErr := cacheStore.Delete(ctx, key); dErr != nil {
+		log.Println("Error deleting cache entry that couldn't be updated for key: ", key, "-", dErr)
+	}
+	return err
+}
+
+// retrieves the key from the cache store, treating entries rejected by the read options as not found.
+//
+// The metadata of the entry is only read if the read options need it
+func (c *baseCacheHandler) getFromStore(ctx context.Context, cacheStore CacheStore, key string, out interface{}, options ReadOptions) (bool, EntryMetadata, error) {
+	if !options.needsMetadata() {
+		found, err := cacheStore.Get(ctx, key, out)
+		return found, EntryMetadata{}, err
+	}
+	found, metadata, err := GetWithMetadata(ctx, cacheStore, key, out)
+	return found && options.isFresh(metadata), metadata, err
+}
+
+// retrieves the keys from the cache store, treating entries rejected by the read options as not found.
+//
+// The metadata of the entries is only read if the read options need it
+func (c *baseCacheHandler) getMultiFromStore(ctx context.Context, cacheStore CacheStore, keys []string, out interface{}, options ReadOptions) ([]bool, []EntryMetadata, error) {
+	if !options.needsMetadata() {
+		found, err := cacheStore.GetMulti(ctx, keys, out)
+		return found, make([]EntryMetadata, len(keys)), err
+	}
+	found, metadata, err := GetMultiWithMetadata(ctx, cacheStore, keys, out)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range found {
+		found[i] = found[i] && options.isFresh(metadata[i])
+	}
+	return found, metadata, nil
+}
+
+// creates the result of a value read from the cache, with metadata if the read options ask for it
+func cachedResult(value interface{}, metadata EntryMetadata, options ReadOptions) Result {
+	result := ValueResult{Value: value}
+	if !options.Metadata {
+		return result
+	}
+	return withMetadata(result, cachedResultMetadata(metadata))
+}
+
+// attaches metadata to a result read from the DataFetcher if the read options ask for it
+func fetchedResult(result Result, options ReadOptions) Result {
+	if !options.Metadata {
+		return result
+	}
+	return withMetadata(result, ResultMetadata{Source: DataFetcherSource})
+}
+
+// returns the key of a value, derived with the keyFunc or read from the key fields, and false if the value
+// has no key. Composite keys are Tuple values, and they have no key if any of their fields is nil
+func (c *baseCacheHandler) valueKey(th drreflect.StructTypeHandler, value interface{}) (interface{}, bool) {
+	if c.keyFunc != nil {
+		key, ok := c.keyFunc(value)
+		return key, ok && key != nil
+	}
+	if c.keyFields == nil {
+		key := th.GetFieldValue(value, c.keyFieldName)
+		return key, key != nil
+	}
+	values := make([]interface{}, len(c.keyFields))
+	for i, field := range c.keyFields {
+		if values[i] = th.GetFieldValue(value, field); values[i] == nil {
+			return nil, false
+		}
+	}
+	return NewTuple(values...), true
+}
+
 func (c *baseCacheHandler) cacheKey(keyPart interface{}) string {
-	return c.keyPrefix + cast.ToString(keyPart)
+	return EntryKey(c.entryKeyPrefix(), keyPart)
+}
+
+// Returns the key of the cache entry of a key, given the prefix of the keys of the entries of the cache (see
+// GenerationalKeyPrefix). Keys are converted to strings with cast.ToString, so tuples use their String encoding
+func EntryKey(entryKeyPrefix string, key interface{}) string {
+	return entryKeyPrefix + cast.ToString(key)
 }
@@ -1,5 +1,13 @@
 package datarepo
 
+import (
+	"context"
+	"errors"
+	"github.com/merlinapp/datarepo-go/drreflect"
+	"strconv"
+	"time"
+)
+
 // Defines a new Builder used to create a new CachedRepository
 type Builder interface {
 	// Adds a new Unique Key cache to the builder.
@@ -22,8 +30,41 @@ type Builder interface {
 	// DataWriter to be used when new data needs to be stored in a repository
 	WithDataWriter(writer DataWriter) Builder
 	// Indicates if the cache entries should be evicted entries after data is written to the repository
-	// or if data in the cache should be updated instead
+	// or if data in the cache should be updated instead.
+	//
+	// This applies to the caches that don't define their own WritePolicy
 	EvictAfterWrite(v bool) Builder
+	// Queue used to retry the cache evictions that fail after data is written to the repository.
+	//
+	// If a queue is provided, a background worker replays the failed evictions until they succeed. The worker
+	// is stopped when the repository is shut down (see Shutdowner)
+	WithRetryQueue(queue RetryQueue, options RetryOptions) Builder
+	// Verifies a fraction of the cache hits against the DataFetcher in the background, overwriting the
+	// cache entries that don't match the data in the repository.
+	//
+	// The verifications in progress are awaited when the repository is shut down (see Shutdowner)
+	WithReadRepair(options ReadRepairOptions) Builder
+	// Indicates if the values filled into a cache by a read-through or a refresh should be stored in the rest of
+	// the caches of the repository.
+	//
+	// Unique key caches store the values under their own keys, so that reading a value by another unique key
+	// doesn't need to fetch it again, and non-unique key caches add the values to the lists they already have
+	// cached. Only the entries and values that aren't cached are stored, as the cached ones may hold values
+	// written since the fill read its values. Cross-populating isn't a write to the repository, so the
+	// WritePolicy of the caches doesn't apply, and failures to store values are only logged.
+	//
+	// The values are stored before the read returns
+	CrossPopulateCaches(v bool) Builder
+	// Creates a new CachedRepository
+	//
+	// The configuration is validated before creating the repository and all the problems found are
+	// returned together as a *BuildError
+	Build() (CachedRepository, error)
+	// Creates a new ReadOnlyCachedRepository
+	//
+	// The configuration is validated before creating the repository and all the problems found are
+	// returned together as a *BuildError
+	BuildRO() (ReadOnlyCachedRepository, error)
 	// Creates a new CachedRepository
 	//
 	// This method panics if it can't create the CachedRepository
@@ -34,11 +75,10 @@ type Builder interface {
 	BuildROCachedRepository() ReadOnlyCachedRepository
 }
 
+// Creates a new Builder for a repository that will handle data of the provided data type.
+//
+// The data type is expected to be a struct or a pointer to a struct
 func CachedRepositoryBuilder(dataType interface{}) Builder {
-	if dataType == nil {
-		panic("The data type provided must not be nil")
-	}
-
 	builder := repositoryBuilder{
 		DataType:        dataType,
 		UniqueCaches:    make(map[string]uniqueCacheConfiguration),
@@ -55,6 +95,12 @@ type repositoryBuilder struct {
 	NonUniqueCaches         map[string]nonUniqueCacheConfiguration
 	DataWriter              DataWriter
 	EvictOnWrite            bool
+	RetryQueue              RetryQueue
+	RetryOptions            RetryOptions
+	ReadRepair              *ReadRepairOptions
+	CrossPopulate           bool
+	// configuration errors found while the builder methods were invoked, reported when building
+	errs []error
 }
 
 type uniqueCacheConfiguration struct {
@@ -87,8 +133,30 @@ func (b *repositoryBuilder) EvictAfterWrite(v bool) Builder {
 	return b
 }
 
+func (b *repositoryBuilder) WithRetryQueue(queue RetryQueue, options RetryOptions) Builder {
+	b.RetryQueue = queue
+	b.RetryOptions = options
+	return b
+}
+
+func (b *repositoryBuilder) CrossPopulateCaches(v bool) Builder {
+	b.CrossPopulate = v
+	return b
+}
+
+func (b *repositoryBuilder) WithReadRepair(options ReadRepairOptions) Builder {
+	b.ReadRepair = &options
+	return b
+}
+
 func (b *repositoryBuilder) WithUniqueKeyCache(cacheDefinition UniqueKeyCacheDefinition, store CacheStore) Builder {
-	b.validateCacheName(cacheDefinition.KeyFieldName)
+	if !b.validateKeyFieldNames(cacheDefinition.KeyFieldName, cacheDefinition.KeyFieldNames) {
+		return b
+	}
+	cacheDefinition.KeyFieldName = cacheDefinition.cacheName()
+	if !b.validateCacheName(cacheDefinition.KeyFieldName) {
+		return b
+	}
 
 	cacheConfig := uniqueCacheConfiguration{
 		cacheDefinition,
@@ -99,7 +167,13 @@ func (b *repositoryBuilder) WithUniqueKeyCache(cacheDefinition UniqueKeyCacheDef
 }
 
 func (b *repositoryBuilder) WithNonUniqueKeyCache(cacheDefinition NonUniqueKeyCacheDefinition, store CacheStore) Builder {
-	b.validateCacheName(cacheDefinition.KeyFieldName)
+	if !b.validateKeyFieldNames(cacheDefinition.KeyFieldName, cacheDefinition.KeyFieldNames) {
+		return b
+	}
+	cacheDefinition.KeyFieldName = cacheDefinition.cacheName()
+	if !b.validateCacheName(cacheDefinition.KeyFieldName) {
+		return b
+	}
 
 	cacheConfig := nonUniqueCacheConfiguration{
 		cacheDefinition,
@@ -109,31 +183,44 @@ func (b *repositoryBuilder) WithNonUniqueKeyCache(cacheDefinition NonUniqueKeyCa
 	return b
 }
 
-func (b *repositoryBuilder) BuildCachedRepository() CachedRepository {
-	if b.DataWriter == nil {
-		panic("a DataWriter needs to be provided when building a new read-write cached repository")
+func (b *repositoryBuilder) Build() (CachedRepository, error) {
+	if err := b.validate(true); err != nil {
+		return nil, err
 	}
 	roRepo := b.buildReadOnlyRepository()
 	repo := cachedRepository{
 		readOnlyCachedRepository: *roRepo,
 		writer:                   b.DataWriter,
 	}
-	if b.EvictOnWrite {
-		repo.postWriteOp = repo.evictFromCaches
-	} else {
-		repo.postWriteOp = repo.setValueInCaches
+	if b.RetryQueue != nil {
+		repo.retryWorker = newRetryWorker(b.RetryQueue, b.RetryOptions, repo.caches)
+		repo.retryWorker.start()
 	}
-	return &repo
+	repo.delayedEvictor = newDelayedEvictor(repo.retryWorker)
+	return &repo, nil
 }
 
-func (b *repositoryBuilder) BuildROCachedRepository() ReadOnlyCachedRepository {
-	if len(b.UniqueCaches) > 0 && b.UniqueKeyDataFetcher == nil {
-		panic("a UniqueKeyDataFetcher needs to be provided when building a new cached repository with unique key caches defined")
+func (b *repositoryBuilder) BuildRO() (ReadOnlyCachedRepository, error) {
+	if err := b.validate(false); err != nil {
+		return nil, err
+	}
+	return b.buildReadOnlyRepository(), nil
+}
+
+func (b *repositoryBuilder) BuildCachedRepository() CachedRepository {
+	repo, err := b.Build()
+	if err != nil {
+		panic(err)
 	}
-	if len(b.NonUniqueCaches) > 0 && b.NonUniqueKeyDataFetcher == nil {
-		panic("a NonUniqueKeyDataFetcher needs to be provided when building a new cached repository with non-unique key caches defined")
+	return repo
+}
+
+func (b *repositoryBuilder) BuildROCachedRepository() ReadOnlyCachedRepository {
+	repo, err := b.BuildRO()
+	if err != nil {
+		panic(err)
 	}
-	return b.buildReadOnlyRepository()
+	return repo
 }
 
 func (b *repositoryBuilder) buildReadOnlyRepository() *readOnlyCachedRepository {
@@ -141,9 +228,12 @@ func (b *repositoryBuilder) buildReadOnlyRepository() *readOnlyCachedRepository
 	for k, v := range b.UniqueCaches {
 		cacheHandler := UniqueKeyCache(b.DataType, v.UniqueKeyCacheDefinition)
 		repo.caches[k] = Cache{
-			Handler:     cacheHandler,
-			Store:       v.CacheStore,
-			DataFetcher: b.UniqueKeyDataFetcher,
+			Handler:           cacheHandler,
+			Store:             v.CacheStore,
+			DataFetcher:       b.UniqueKeyDataFetcher,
+			WritePolicy:       b.writePolicy(v.WritePolicy),
+			DoubleDeleteDelay: doubleDeleteDelay(v.DoubleDeleteDelay),
+			generation:        generation(cacheHandler, v.CacheStore, v.Generational, v.GenerationRefreshInterval),
 		}
 	}
 	for k, v := range b.NonUniqueCaches {
@@ -151,24 +241,113 @@ func (b *repositoryBuilder) buildReadOnlyRepository() *readOnlyCachedRepository
 		fetcher := b.NonUniqueKeyDataFetcher
 		if v.CacheEmptyResults {
 			fetcher = &emptyResultDataFetcherWrapper{
-				dataType: b.DataType,
-				delegate: fetcher,
+				typeHandler: drreflect.NewReflectStructTypeHandlerFromValue(b.DataType),
+				delegate:    fetcher,
 			}
 		}
 		repo.caches[k] = Cache{
-			Handler:     cacheHandler,
-			Store:       v.CacheStore,
-			DataFetcher: fetcher,
+			Handler:           cacheHandler,
+			Store:             v.CacheStore,
+			DataFetcher:       fetcher,
+			WritePolicy:       b.writePolicy(v.WritePolicy),
+			DoubleDeleteDelay: doubleDeleteDelay(v.DoubleDeleteDelay),
+			generation:        generation(cacheHandler, v.CacheStore, v.Generational, v.GenerationRefreshInterval),
+		}
+	}
+	for k, v := range b.UniqueCaches {
+		if h, ok := repo.caches[k].Handler.(memberCacheHandler); ok {
+			members := repo.caches[v.PrimaryKeyFieldName]
+			h.setMemberCache(&members)
+		}
+	}
+	for k, v := range b.NonUniqueCaches {
+		if h, ok := repo.caches[k].Handler.(memberCacheHandler); ok {
+			members := repo.caches[v.SubKeyFieldName]
+			h.setMemberCache(&members)
+		}
+	}
+	if b.CrossPopulate {
+		for k, c := range repo.caches {
+			if h, ok := c.Handler.(fillingHandler); ok {
+				source, members := k, b.memberCacheName(k)
+				h.setFillListener(func(ctx context.Context, results []Result) {
+					repo.crossPopulate(ctx, source, members, results)
+				})
+			}
+		}
+	}
+	if b.ReadRepair != nil {
+		repo.readRepair = newReadRepairer(*b.ReadRepair)
+		for _, c := range repo.caches {
+			if h, ok := c.Handler.(readRepairHandler); ok {
+				h.setReadRepair(&cacheReadRepair{readRepairer: repo.readRepair, handler: c.Handler})
+			}
 		}
 	}
 	return &repo
 }
 
-func (b *repositoryBuilder) validateCacheName(cacheName string) {
-	if _, ok := b.UniqueCaches[cacheName]; ok {
-		panic("a cache has already been defined with the same field name: " + cacheName)
+// returns the name of the unique key cache the cache reads its values from, or an empty string if the cache
+// stores the values itself
+func (b *repositoryBuilder) memberCacheName(cacheName string) string {
+	if v, ok := b.UniqueCaches[cacheName]; ok {
+		return v.PrimaryKeyFieldName
+	}
+	if v := b.NonUniqueCaches[cacheName]; v.Normalized {
+		return v.SubKeyFieldName
 	}
-	if _, ok := b.NonUniqueCaches[cacheName]; ok {
-		panic("a cache has already been defined with the same field name: " + cacheName)
+	return ""
+}
+
+// resolves the write policy of a cache, using the policy of the repository if the cache doesn't define one
+func (b *repositoryBuilder) writePolicy(policy WritePolicy) WritePolicy {
+	if policy != WritePolicyDefault {
+		return policy
+	}
+	if b.EvictOnWrite {
+		return WritePolicyEvict
+	}
+	return WritePolicyUpdate
+}
+
+func doubleDeleteDelay(delay time.Duration) time.Duration {
+	if delay == 0 {
+		return DefaultDoubleDeleteDelay
+	}
+	return delay
+}
+
+// creates the generation of a generational cache and folds it into the keys of its handler
+func generation(handler Handler, store CacheStore, generational bool, refreshInterval time.Duration) *cacheGeneration {
+	if !generational {
+		return nil
+	}
+	g := newCacheGeneration(store, handler.CacheKeyPrefix(), refreshInterval)
+	if h, ok := handler.(generationalHandler); ok {
+		h.setGeneration(g)
+	}
+	return g
+}
+
+func (b *repositoryBuilder) validateKeyFieldNames(keyFieldName string, keyFieldNames []string) bool {
+	if keyFieldName != "" && len(keyFieldNames) > 0 {
+		b.errs = append(b.errs, errors.New("a cache can't define both a KeyFieldName and KeyFieldNames: "+keyFieldName))
+		return false
+	}
+	if len(keyFieldNames) > MaxTupleSize {
+		b.errs = append(b.errs, errors.New("a composite key can't have more than "+strconv.Itoa(MaxTupleSize)+" fields: "+
+			CompositeKeyName(keyFieldNames...)))
+		return false
+	}
+	return true
+}
+
+func (b *repositoryBuilder) validateCacheName(cacheName string) bool {
+	_, unique := b.UniqueCaches[cacheName]
+	_, nonUnique := b.NonUniqueCaches[cacheName]
+	if unique || nonUnique {
+		b.errs = append(b.errs, errors.New("a cache has already been defined with the same field name: "+cacheName))
+		return false
 	}
+	return true
 }
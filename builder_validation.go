@@ -0,0 +1,236 @@
+package datarepo
+
+import (
+	"errors"
+	"github.com/merlinapp/datarepo-go/drreflect"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Error returned when a Builder can't create a repository due to an invalid configuration.
+//
+// It holds every problem found in the configuration, not just the first one
+type BuildError struct {
+	Errors []error
+}
+
+func (e *BuildError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return "invalid repository configuration: " + strings.Join(messages, "; ")
+}
+
+// a key or subkey field of a cache
+type cacheField struct {
+	name string
+	// whether the values of the field are derived with a KeyFunc, so it doesn't need to be a field of the struct
+	derived bool
+}
+
+// a cache defined in the builder, used to validate the configuration of caches regardless of their type
+type cacheConfiguration struct {
+	keyPrefix                 string
+	store                     CacheStore
+	fields                    []cacheField
+	writePolicy               WritePolicy
+	doubleDeleteDelay         time.Duration
+	generationRefreshInterval time.Duration
+	generational              bool
+}
+
+func (b *repositoryBuilder) validate(requireWriter bool) error {
+	errs := append([]error{}, b.errs...)
+
+	if requireWriter && b.DataWriter == nil {
+		errs = append(errs, errors.New("a DataWriter needs to be provided when building a new read-write cached repository"))
+	}
+	if len(b.UniqueCaches) > 0 && b.UniqueKeyDataFetcher == nil {
+		errs = append(errs, errors.New("a UniqueKeyDataFetcher needs to be provided when building a new cached repository with unique key caches defined"))
+	}
+	if len(b.NonUniqueCaches) > 0 && b.NonUniqueKeyDataFetcher == nil {
+		errs = append(errs, errors.New("a NonUniqueKeyDataFetcher needs to be provided when building a new cached repository with non-unique key caches defined"))
+	}
+
+	if b.ReadRepair != nil && (b.ReadRepair.SampleRate < 0 || b.ReadRepair.SampleRate > 1) {
+		errs = append(errs, errors.New("the read-repair sample rate must be between 0 and 1"))
+	}
+
+	for _, name := range sortedKeys(b.UniqueCaches) {
+		v := b.UniqueCaches[name]
+		if v.PrimaryKeyFieldName == "" {
+			continue
+		}
+		primary, ok := b.UniqueCaches[v.PrimaryKeyFieldName]
+		if !ok || primary.PrimaryKeyFieldName != "" || CompositeKeyFields(primary.KeyFieldName) != nil {
+			errs = append(errs, errors.New("a unique key cache with a PrimaryKeyFieldName needs a unique key cache for it that "+
+				"stores the values and doesn't have a composite key: "+name))
+		}
+	}
+	for _, name := range sortedKeys(b.NonUniqueCaches) {
+		v := b.NonUniqueCaches[name]
+		if _, ok := b.UniqueCaches[v.SubKeyFieldName]; v.Normalized && !ok {
+			errs = append(errs, errors.New("a normalized non-unique key cache needs a unique key cache for its SubKeyFieldName: "+name))
+		}
+	}
+
+	caches := b.cacheConfigurations()
+	for _, c := range caches {
+		if c.store == nil {
+			errs = append(errs, errors.New("a CacheStore needs to be provided for the cache with prefix: "+c.keyPrefix))
+		}
+		if !c.writePolicy.isValid() {
+			errs = append(errs, errors.New("invalid write policy "+c.writePolicy.String()+" for the cache with prefix: "+c.keyPrefix))
+		}
+		if c.doubleDeleteDelay < 0 {
+			errs = append(errs, errors.New("the double delete delay must not be negative for the cache with prefix: "+c.keyPrefix))
+		}
+		if c.generationRefreshInterval < 0 {
+			errs = append(errs, errors.New("the generation refresh interval must not be negative for the cache with prefix: "+c.keyPrefix))
+		}
+	}
+	errs = append(errs, b.validateFields(caches)...)
+	errs = append(errs, validateKeyPrefixes(caches)...)
+
+	if len(errs) > 0 {
+		return &BuildError{Errors: errs}
+	}
+	return nil
+}
+
+func (b *repositoryBuilder) cacheConfigurations() []cacheConfiguration {
+	caches := make([]cacheConfiguration, 0, len(b.UniqueCaches)+len(b.NonUniqueCaches))
+	for _, name := range sortedKeys(b.UniqueCaches) {
+		v := b.UniqueCaches[name]
+		caches = append(caches, cacheConfiguration{
+			keyPrefix:                 v.KeyPrefix,
+			store:                     v.CacheStore,
+			fields:                    keyCacheFields(v.KeyFieldName, v.KeyFunc != nil),
+			writePolicy:               v.WritePolicy,
+			doubleDeleteDelay:         v.DoubleDeleteDelay,
+			generationRefreshInterval: v.GenerationRefreshInterval,
+			generational:              v.Generational,
+		})
+	}
+	for _, name := range sortedKeys(b.NonUniqueCaches) {
+		v := b.NonUniqueCaches[name]
+		fields := keyCacheFields(v.KeyFieldName, v.KeyFunc != nil)
+		// derived subkeys don't need a field name
+		if v.SubKeyFunc == nil {
+			fields = append(fields, cacheField{v.SubKeyFieldName, false})
+		}
+		caches = append(caches, cacheConfiguration{
+			keyPrefix:                 v.KeyPrefix,
+			store:                     v.CacheStore,
+			fields:                    fields,
+			writePolicy:               v.WritePolicy,
+			doubleDeleteDelay:         v.DoubleDeleteDelay,
+			generationRefreshInterval: v.GenerationRefreshInterval,
+			generational:              v.Generational,
+		})
+	}
+	return caches
+}
+
+// returns the fields of the key of a cache, which are the fields of its composite key if it's composite
+func keyCacheFields(keyFieldName string, derived bool) []cacheField {
+	compositeFields := CompositeKeyFields(keyFieldName)
+	if derived || compositeFields == nil {
+		return []cacheField{{keyFieldName, derived}}
+	}
+	fields := make([]cacheField, len(compositeFields))
+	for i, name := range compositeFields {
+		fields[i] = cacheField{name, false}
+	}
+	return fields
+}
+
+// Checks that the data type is a struct and that every field used as a key or subkey exists in the
+// struct and can be compared, as keys are used to match cached elements with fetched elements
+func (b *repositoryBuilder) validateFields(caches []cacheConfiguration) []error {
+	if b.DataType == nil {
+		return []error{errors.New("the data type provided must not be nil")}
+	}
+	t := reflect.TypeOf(b.DataType)
+	if err := drreflect.ValidateStructType(t); err != nil {
+		return []error{err}
+	}
+	th := drreflect.NewReflectStructTypeHandler(t)
+
+	var errs []error
+	for _, c := range caches {
+		for _, field := range c.fields {
+			fieldName := field.name
+			if fieldName == "" {
+				errs = append(errs, errors.New("a key field name must be defined for the cache with prefix: "+c.keyPrefix))
+				continue
+			}
+			if field.derived {
+				continue
+			}
+			fieldType, ok := th.FieldType(fieldName)
+			if !ok {
+				errs = append(errs, errors.New("field "+fieldName+" is not defined in type "+th.Type().String()))
+				continue
+			}
+			if !fieldType.Comparable() {
+				errs = append(errs, errors.New("field "+fieldName+" of type "+fieldType.String()+" can't be used as a key as it isn't comparable"))
+			}
+		}
+	}
+	return errs
+}
+
+// Checks that caches sharing the same CacheStore don't use key prefixes that could produce the same
+// keys, that is, prefixes that are equal or where one prefix starts with the other, and that the generations
+// of generational caches aren't stored under the prefix of a cache, where flushing the cache would reset them
+func validateKeyPrefixes(caches []cacheConfiguration) []error {
+	var errs []error
+	for _, g := range caches {
+		if !g.generational {
+			continue
+		}
+		for _, c := range caches {
+			if sameComponent(g.store, c.store) && strings.HasPrefix(GenerationKey(g.keyPrefix), c.keyPrefix) {
+				errs = append(errs, errors.New("the generation of the cache with prefix '"+g.keyPrefix+
+					"' is stored under the key prefix '"+c.keyPrefix+"' in the same cache store"))
+			}
+		}
+	}
+	for i := 0; i < len(caches); i++ {
+		for j := i + 1; j < len(caches); j++ {
+			if !sameComponent(caches[i].store, caches[j].store) {
+				continue
+			}
+			p1, p2 := caches[i].keyPrefix, caches[j].keyPrefix
+			if strings.HasPrefix(p1, p2) || strings.HasPrefix(p2, p1) {
+				errs = append(errs, errors.New("key prefixes '"+p1+"' and '"+p2+"' collide in the same cache store"))
+			}
+		}
+	}
+	return errs
+}
+
+// checks if both values are the same instance without panicking for values of non-comparable types
+func sameComponent(c1, c2 interface{}) bool {
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if reflect.TypeOf(c1) != reflect.TypeOf(c2) || !reflect.TypeOf(c1).Comparable() {
+		return false
+	}
+	return c1 == c2
+}
+
+func sortedKeys(m interface{}) []string {
+	keys := reflect.ValueOf(m).MapKeys()
+	result := make([]string, len(keys))
+	for i, k := range keys {
+		result[i] = k.String()
+	}
+	sort.Strings(result)
+	return result
+}
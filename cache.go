@@ -2,15 +2,22 @@ package datarepo
 
 import (
 	"context"
+	"time"
 )
 
 type Cache struct {
 	Handler     Handler
 	Store       CacheStore
 	DataFetcher DataFetcher
+	// Policy applied to the cache when data is written to the repository
+	WritePolicy WritePolicy
+	// Delay of the second eviction when the WritePolicy is WritePolicyDelayedDoubleDelete
+	DoubleDeleteDelay time.Duration
+	// generation of the cache, nil if the cache isn't generational
+	generation *cacheGeneration
 }
 
-func (c *Cache) Delete(ctx context.Context, key string) error {
+func (c *Cache) Delete(ctx context.Context, key interface{}) error {
 	return c.Handler.Delete(ctx, c.Store, key)
 }
 
@@ -18,12 +25,16 @@ func (c *Cache) DeleteValue(ctx context.Context, value interface{}) error {
 	return c.Handler.DeleteValue(ctx, c.Store, value)
 }
 
-func (c *Cache) Get(ctx context.Context, key interface{}) (Result, error) {
-	return c.Handler.Get(ctx, c.Store, key, c.DataFetcher)
+func (c *Cache) Get(ctx context.Context, key interface{}, options ReadOptions) (Result, error) {
+	return c.Handler.Get(ctx, c.Store, key, c.DataFetcher, options)
 }
 
-func (c *Cache) GetMulti(ctx context.Context, keys []interface{}) ([]Result, error) {
-	return c.Handler.GetMulti(ctx, c.Store, keys, c.DataFetcher)
+func (c *Cache) GetMulti(ctx context.Context, keys []interface{}, options ReadOptions) ([]Result, error) {
+	return c.Handler.GetMulti(ctx, c.Store, keys, c.DataFetcher, options)
+}
+
+func (c *Cache) Refresh(ctx context.Context, keys []interface{}) ([]Result, error) {
+	return c.Handler.Refresh(ctx, c.Store, keys, c.DataFetcher)
 }
 
 func (c *Cache) Set(ctx context.Context, value interface{}) error {
@@ -2,23 +2,90 @@ package datarepo
 
 import "time"
 
+// Derives the key of a value, which is a pointer to the data type of the repository. The second return value
+// is false if the value has no key, in which case it isn't cached
+type KeyFunc func(value interface{}) (interface{}, bool)
+
 type UniqueKeyCacheDefinition struct {
 	KeyPrefix string
 	// Name of the field that defines the key that will be used to store elements in the cache
 	KeyFieldName string
+	// Names of the fields of a composite key, used instead of KeyFieldName. The cache is named
+	// CompositeKeyName(KeyFieldNames...) and its keys are Tuple values with the values of the fields
+	KeyFieldNames []string
+	// Derives the key of the values instead of reading the KeyFieldName field, for keys like a lower-cased
+	// email. KeyFieldName is then only the name of the cache, and the DataFetcher must be able to find values
+	// by their derived keys
+	KeyFunc KeyFunc
+	// Stores only the primary key of the values, the key of the unique key cache defined for
+	// PrimaryKeyFieldName, and reads the values from that cache, so a secondary key like an email doesn't hold
+	// another copy of the values. The repository must define a unique key cache for PrimaryKeyFieldName that
+	// doesn't have a composite key
+	PrimaryKeyFieldName string
 	// expiration time of entries in the cache
 	Expiration time.Duration
+	// Policy applied to the cache when data is written to the repository
+	WritePolicy WritePolicy
+	// Delay of the second eviction when the WritePolicy is WritePolicyDelayedDoubleDelete.
+	// DefaultDoubleDeleteDelay is used if no delay is defined
+	DoubleDeleteDelay time.Duration
+	// Folds a generation number into the keys of the cache, so that all its entries can be invalidated at once
+	// with ReadOnlyCachedRepository.BumpGeneration. The generation is kept in the CacheStore
+	Generational bool
+	// How often generational caches re-read their generation from the CacheStore, to notice the bumps made by
+	// other processes. DefaultGenerationRefreshInterval is used if no interval is defined
+	GenerationRefreshInterval time.Duration
 }
 
 type NonUniqueKeyCacheDefinition struct {
 	KeyPrefix string
 	// Name of the field that defines the key that will be used to store elements in the cache
 	KeyFieldName string
+	// Names of the fields of a composite key, used instead of KeyFieldName. The cache is named
+	// CompositeKeyName(KeyFieldNames...) and its keys are Tuple values with the values of the fields
+	KeyFieldNames []string
+	// Derives the key of the values instead of reading the KeyFieldName field. KeyFieldName is then only the
+	// name of the cache, and the DataFetcher must be able to find values by their derived keys
+	KeyFunc KeyFunc
 	// Name of the field that defines the subkey that will be used to compare and store elements that
 	// belong to the same key
 	SubKeyFieldName string
+	// Derives the subkey of the values instead of reading the SubKeyFieldName field, which isn't needed then
+	SubKeyFunc KeyFunc
 	// Expiration time of entries in the cache
 	Expiration time.Duration
 	// Indicates if empty results should be cached
 	CacheEmptyResults bool
+	// Stores only the ordered subkeys of the values of each key, reading the values from the unique key cache
+	// defined for SubKeyFieldName, so each value is cached in a single place. The repository must define a
+	// unique key cache for SubKeyFieldName
+	Normalized bool
+	// Policy applied to the cache when data is written to the repository
+	WritePolicy WritePolicy
+	// Delay of the second eviction when the WritePolicy is WritePolicyDelayedDoubleDelete.
+	// DefaultDoubleDeleteDelay is used if no delay is defined
+	DoubleDeleteDelay time.Duration
+	// Folds a generation number into the keys of the cache, so that all its entries can be invalidated at once
+	// with ReadOnlyCachedRepository.BumpGeneration. The generation is kept in the CacheStore
+	Generational bool
+	// How often generational caches re-read their generation from the CacheStore, to notice the bumps made by
+	// other processes. DefaultGenerationRefreshInterval is used if no interval is defined
+	GenerationRefreshInterval time.Duration
+}
+
+// returns the name of the cache, which is the name of its composite key if KeyFieldNames is defined
+func (d UniqueKeyCacheDefinition) cacheName() string {
+	return cacheName(d.KeyFieldName, d.KeyFieldNames)
+}
+
+// returns the name of the cache, which is the name of its composite key if KeyFieldNames is defined
+func (d NonUniqueKeyCacheDefinition) cacheName() string {
+	return cacheName(d.KeyFieldName, d.KeyFieldNames)
+}
+
+func cacheName(keyFieldName string, keyFieldNames []string) string {
+	if len(keyFieldNames) > 0 {
+		return CompositeKeyName(keyFieldNames...)
+	}
+	return keyFieldName
 }
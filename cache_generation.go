@@ -0,0 +1,124 @@
+package datarepo
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Interval used by generational caches to re-read their generation when the cache definition doesn't define one
+const DefaultGenerationRefreshInterval = 5 * time.Second
+
+// prefix of the keys under which the current generations of caches are stored, outside the key prefixes of
+// the caches so that flushing a cache doesn't reset its generation
+const generationKeyPrefix = "datarepo:generation:"
+
+// Returns the key under which the current generation of the generational cache with the key prefix is stored
+func GenerationKey(keyPrefix string) string {
+	return generationKeyPrefix + keyPrefix
+}
+
+// Returns the prefix of the keys of the entries of the generational cache with the key prefix in the given
+// generation
+func GenerationalKeyPrefix(keyPrefix string, generation int64) string {
+	return keyPrefix + "g" + strconv.FormatInt(generation, 10) + ":"
+}
+
+// The generation of a cache, folded into the keys of its entries so that bumping it invalidates all the
+// entries at once. The generation is kept in the CacheStore to share it between processes
+type cacheGeneration struct {
+	store           CacheStore
+	key             string
+	refreshInterval time.Duration
+	mu              sync.Mutex
+	value           int64
+	loadedAt        time.Time
+}
+
+// implemented by the cache handlers that support generations
+type generationalHandler interface {
+	setGeneration(generation *cacheGeneration)
+}
+
+func newCacheGeneration(store CacheStore, keyPrefix string, refreshInterval time.Duration) *cacheGeneration {
+	if refreshInterval == 0 {
+		refreshInterval = DefaultGenerationRefreshInterval
+	}
+	return &cacheGeneration{
+		store:           store,
+		key:             GenerationKey(keyPrefix),
+		refreshInterval: refreshInterval,
+	}
+}
+
+// Returns the current generation, re-reading it from the cache store once the refresh interval elapses.
+//
+// If the generation can't be read, the last known generation is used
+func (g *cacheGeneration) current() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if time.Since(g.loadedAt) < g.refreshInterval {
+		return g.value
+	}
+	var value int64
+	found, err := g.store.Get(context.Background(), g.key, &value)
+	if err != nil {
+		log.Println("Error reading cache generation for key: ", g.key, "-", err)
+		return g.value
+	}
+	if !found {
+		value = 0
+	}
+	g.value = value
+	g.loadedAt = time.Now()
+	return g.value
+}
+
+// Increments the generation, atomically if the cache store implements CounterCacheStore
+func (g *cacheGeneration) bump(ctx context.Context) (int64, error) {
+	value, err := Incr(ctx, g.store, g.key)
+	if err != nil {
+		return 0, err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.value = value
+	g.loadedAt = time.Now()
+	return value, nil
+}
+
+func (c *baseCacheHandler) setGeneration(generation *cacheGeneration) {
+	c.generation = generation
+}
+
+// returns the prefix of the keys of the entries of the cache, including the current generation if the
+// cache is generational
+func (c *baseCacheHandler) entryKeyPrefix() string {
+	if c.generation == nil {
+		return c.keyPrefix
+	}
+	return GenerationalKeyPrefix(c.keyPrefix, c.generation.current())
+}
+
+func (r *readOnlyCachedRepository) BumpGeneration(ctx context.Context, keyFieldName string) (int64, error) {
+	cache, ok := r.caches[keyFieldName]
+	if !ok {
+		return 0, unknownKeyFieldError(keyFieldName)
+	}
+	if cache.generation == nil {
+		return 0, errors.New("the cache isn't generational for: " + keyFieldName)
+	}
+	return cache.generation.bump(ctx)
+}
+
+func (r *readOnlyCachedRepository) Flush(ctx context.Context, keyFieldName string) error {
+	cache, ok := r.caches[keyFieldName]
+	if !ok {
+		return unknownKeyFieldError(keyFieldName)
+	}
+	return Flush(ctx, cache.Store, cache.Handler.CacheKeyPrefix())
+}
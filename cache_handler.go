@@ -9,9 +9,22 @@ import (
 type Handler interface {
 	Delete(ctx context.Context, cacheStore CacheStore, key interface{}) error
 	DeleteValue(ctx context.Context, cacheStore CacheStore, value interface{}) error
-	Get(ctx context.Context, cacheStore CacheStore, key interface{}, fetcher DataFetcher) (Result, error)
-	GetMulti(ctx context.Context, cacheStore CacheStore, keys []interface{}, fetcher DataFetcher) ([]Result, error)
+	Get(ctx context.Context, cacheStore CacheStore, key interface{}, fetcher DataFetcher, options ReadOptions) (Result, error)
+	GetMulti(ctx context.Context, cacheStore CacheStore, keys []interface{}, fetcher DataFetcher, options ReadOptions) ([]Result, error)
 	Set(ctx context.Context, cacheStore CacheStore, value interface{}) error
+	// Fetches the given keys using the fetcher and overwrites their entries in the cache, bypassing the
+	// values currently cached. Entries of keys without data are deleted from the cache
+	Refresh(ctx context.Context, cacheStore CacheStore, keys []interface{}, fetcher DataFetcher) ([]Result, error)
+
+	// Returns the value of the key field of the provided value. The second return value is false
+	// if the value doesn't belong to any cache entry
+	ValueKey(value interface{}) (interface{}, bool)
+	// Returns the key of the cache entry the provided value belongs to. The second return value is false
+	// if the value doesn't belong to any cache entry
+	ValueCacheKey(value interface{}) (string, bool)
+	// Returns the value of the sub key field of the provided value, which tells apart the elements stored
+	// in the same cache entry. The second return value is false if the cache holds a single element per key
+	ValueSubKey(value interface{}) (interface{}, bool)
 
 	CachedType() reflect.Type
 	CacheKeyPrefix() string
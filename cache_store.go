@@ -2,6 +2,7 @@ package datarepo
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
@@ -21,3 +22,111 @@ type CacheStore interface {
 	// Sets the key in the cache with the provided value
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration)
 }
+
+// Metadata of an entry stored in a CacheStore
+type EntryMetadata struct {
+	// Time when the entry was stored in the cache. It's zero if the store doesn't know when the entry was stored
+	StoredAt time.Time
+	// Time when the entry expires. It's zero if the entry doesn't expire or the store doesn't know when it
+	// expires
+	ExpiresAt time.Time
+	// Name of the tier the entry was read from, for example "redis" or "memory". It's empty if the store
+	// doesn't report it
+	Source string
+}
+
+// A CacheStore that keeps metadata about the entries it stores.
+//
+// Read options that depend on the age of cached entries (see MaxStaleness) or report it (see WithMetadata)
+// require the CacheStore to implement this interface
+type MetadataCacheStore interface {
+	CacheStore
+	// Same as Get but also returns the metadata of the entry
+	GetWithMetadata(ctx context.Context, key string, out interface{}) (bool, EntryMetadata, error)
+	// Same as GetMulti but also returns the metadata of the entries, in the same order as the keys
+	GetMultiWithMetadata(ctx context.Context, keys []string, out interface{}) ([]bool, []EntryMetadata, error)
+}
+
+// Retrieves the provided key from the cache store together with the metadata of the entry.
+//
+// If the cache store doesn't implement MetadataCacheStore, then empty metadata is returned
+func GetWithMetadata(ctx context.Context, store CacheStore, key string, out interface{}) (bool, EntryMetadata, error) {
+	if ms, ok := store.(MetadataCacheStore); ok {
+		return ms.GetWithMetadata(ctx, key, out)
+	}
+	found, err := store.Get(ctx, key, out)
+	return found, EntryMetadata{}, err
+}
+
+// Retrieves the provided keys from the cache store together with the metadata of the entries.
+//
+// If the cache store doesn't implement MetadataCacheStore, then empty metadata is returned
+func GetMultiWithMetadata(ctx context.Context, store CacheStore, keys []string, out interface{}) ([]bool, []EntryMetadata, error) {
+	if ms, ok := store.(MetadataCacheStore); ok {
+		return ms.GetMultiWithMetadata(ctx, keys, out)
+	}
+	found, err := store.GetMulti(ctx, keys, out)
+	return found, make([]EntryMetadata, len(keys)), err
+}
+
+// A CacheStore that reports the values it fails to store, so that writes to a repository can detect cache
+// entries that weren't updated
+type CheckedCacheStore interface {
+	CacheStore
+	// Same as Set but returns the error if the value couldn't be stored
+	TrySet(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+}
+
+// Sets the key in the cache store with the provided value, returning the error if it couldn't be stored.
+//
+// If the cache store doesn't implement CheckedCacheStore, then its failures can't be detected and nil is returned
+func TrySet(ctx context.Context, store CacheStore, key string, value interface{}, expiration time.Duration) error {
+	if cs, ok := store.(CheckedCacheStore); ok {
+		return cs.TrySet(ctx, key, value, expiration)
+	}
+	store.Set(ctx, key, value, expiration)
+	return nil
+}
+
+// A CacheStore that can delete all the entries whose keys start with a prefix, used to flush a whole cache
+type FlushableCacheStore interface {
+	CacheStore
+	// Deletes every entry whose key starts with the prefix
+	Flush(ctx context.Context, prefix string) error
+}
+
+// A CacheStore that can increment counters atomically, used to bump the generation of caches shared by
+// several processes
+type CounterCacheStore interface {
+	CacheStore
+	// Increments the counter stored in the key, starting from zero if the key doesn't exist, and returns
+	// the incremented value. The key doesn't expire
+	Incr(ctx context.Context, key string) (int64, error)
+}
+
+// Increments the counter stored in the key and returns the incremented value.
+//
+// If the cache store doesn't implement CounterCacheStore, then the counter is read and stored again, which
+// isn't atomic
+func Incr(ctx context.Context, store CacheStore, key string) (int64, error) {
+	if cs, ok := store.(CounterCacheStore); ok {
+		return cs.Incr(ctx, key)
+	}
+	var value int64
+	if _, err := store.Get(ctx, key, &value); err != nil {
+		return 0, err
+	}
+	value++
+	store.Set(ctx, key, value, 0)
+	return value, nil
+}
+
+// Deletes every entry of the cache store whose key starts with the prefix.
+//
+// An error is returned if the cache store doesn't implement FlushableCacheStore
+func Flush(ctx context.Context, store CacheStore, prefix string) error {
+	if fs, ok := store.(FlushableCacheStore); ok {
+		return fs.Flush(ctx, prefix)
+	}
+	return errors.New("the cache store can't flush the keys with prefix: " + prefix)
+}
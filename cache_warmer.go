@@ -0,0 +1,135 @@
+package datarepo
+
+import (
+	"context"
+	"errors"
+	"github.com/merlinapp/datarepo-go/drreflect"
+	"strconv"
+	"time"
+)
+
+// Progress of a cache warm-up performed with WarmAll
+type WarmUpProgress struct {
+	// Name of the field of the cache being warmed up
+	KeyFieldName string
+	// Last key warmed up. It can be provided to WarmUpFromCursor to resume the warm-up after this key
+	Cursor interface{}
+	// Number of keys warmed up
+	Warmed int64
+	// Number of pages of keys warmed up
+	Pages int64
+	// Indicates that all the keys in the repository have been warmed up
+	Done bool
+}
+
+// Option that changes how WarmAll warms up a cache
+type WarmUpOption func(config *warmUpConfig)
+
+type warmUpConfig struct {
+	keysPerSecond float64
+	progress      func(WarmUpProgress)
+	cursor        interface{}
+}
+
+// Limits the number of keys that are warmed up per second, to avoid overloading the repository
+// while the warm-up is running
+func WarmUpRateLimit(keysPerSecond float64) WarmUpOption {
+	return func(config *warmUpConfig) {
+		config.keysPerSecond = keysPerSecond
+	}
+}
+
+// Function invoked after every page of keys is warmed up
+func WarmUpProgressCallback(callback func(progress WarmUpProgress)) WarmUpOption {
+	return func(config *warmUpConfig) {
+		config.progress = callback
+	}
+}
+
+// Resumes a warm-up after the provided cursor, as reported by WarmUpProgress.Cursor
+func WarmUpFromCursor(cursor interface{}) WarmUpOption {
+	return func(config *warmUpConfig) {
+		config.cursor = cursor
+	}
+}
+
+func (r *readOnlyCachedRepository) Warm(ctx context.Context, keyFieldName string, ids interface{}) error {
+	cache, ok := r.caches[keyFieldName]
+	if !ok {
+		return unknownKeyFieldError(keyFieldName)
+	}
+	sh := drreflect.NewReflectSliceTypeHandlerFromValue(ids)
+	_, err := cache.Refresh(ctx, sh.AsInterfaceSlice(ids))
+	return err
+}
+
+func (r *readOnlyCachedRepository) WarmAll(ctx context.Context, keyFieldName string, pageSize int, options ...WarmUpOption) (WarmUpProgress, error) {
+	config := warmUpConfig{}
+	for _, option := range options {
+		option(&config)
+	}
+	progress := WarmUpProgress{
+		KeyFieldName: keyFieldName,
+		Cursor:       config.cursor,
+	}
+
+	cache, ok := r.caches[keyFieldName]
+	if !ok {
+		return progress, unknownKeyFieldError(keyFieldName)
+	}
+	if pageSize <= 0 {
+		return progress, errors.New("invalid page size for warming up caches: " + strconv.Itoa(pageSize))
+	}
+	lister, ok := cache.DataFetcher.(KeyLister)
+	if !ok {
+		return progress, errors.New("the data fetcher can't list keys for: " + keyFieldName)
+	}
+
+	start := time.Now()
+	for !progress.Done {
+		if err := config.wait(ctx, start, progress.Warmed); err != nil {
+			return progress, err
+		}
+		keys, err := lister.ListKeys(ctx, keyFieldName, progress.Cursor, pageSize)
+		if err != nil {
+			return progress, err
+		}
+		if len(keys) > 0 {
+			if _, err := cache.Refresh(ctx, keys); err != nil {
+				return progress, err
+			}
+			progress.Cursor = keys[len(keys)-1]
+			progress.Warmed += int64(len(keys))
+			progress.Pages++
+		}
+		progress.Done = len(keys) < pageSize
+		if config.progress != nil {
+			config.progress(progress)
+		}
+	}
+	return progress, nil
+}
+
+// waits until the next page can be warmed up without exceeding the rate limit, given the number of keys
+// warmed up since the warm-up started
+func (c *warmUpConfig) wait(ctx context.Context, start time.Time, warmed int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.keysPerSecond <= 0 {
+		return nil
+	}
+	next := start.Add(time.Duration(float64(warmed) / c.keysPerSecond * float64(time.Second)))
+	delay := time.Until(next)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 )
 
+// Write operations return a *CacheMaintenanceError if the data was written successfully but the caches
+// couldn't be updated or evicted afterwards, so callers can tell it apart from a failed write.
 type CachedRepository interface {
 	ReadOnlyCachedRepository
 	// Inserts the provided value into the repository
@@ -27,19 +29,19 @@ type CachedRepository interface {
 
 type cachedRepository struct {
 	readOnlyCachedRepository
-	postWriteOp postWriteOperation
-	writer      DataWriter
+	writer         DataWriter
+	delayedEvictor *delayedEvictor
+	// replays failed cache evictions, nil if the repository doesn't have a RetryQueue
+	retryWorker *retryWorker
 }
 
-type postWriteOperation func(ctx context.Context, value interface{}) error
-
 func (r *cachedRepository) Create(ctx context.Context, value interface{}) error {
 	err := r.writer.Create(ctx, value)
 	if err != nil {
 		return err
 	}
 
-	err = r.postWriteOp(ctx, value)
+	err = r.applyWritePolicies(ctx, value)
 	if err != nil {
 		return err
 	}
@@ -52,7 +54,7 @@ func (r *cachedRepository) Update(ctx context.Context, value interface{}) error
 		return err
 	}
 
-	err = r.postWriteOp(ctx, value)
+	err = r.applyWritePolicies(ctx, value)
 	if err != nil {
 		return err
 	}
@@ -65,7 +67,7 @@ func (r *cachedRepository) PartialUpdate(ctx context.Context, value interface{})
 		return err
 	}
 
-	err = r.postWriteOp(ctx, value)
+	err = r.applyWritePolicies(ctx, value)
 	if err != nil {
 		return err
 	}
@@ -73,22 +75,82 @@ func (r *cachedRepository) PartialUpdate(ctx context.Context, value interface{})
 	return nil
 }
 
-func (r *cachedRepository) setValueInCaches(ctx context.Context, value interface{}) error {
-	for _, v := range r.caches {
-		err := v.Set(ctx, value)
-		if err != nil {
-			return err
+// Stops the background evictions of caches using WritePolicyDelayedDoubleDelete, performing the
+// pending evictions immediately, the replay of failed evictions of the RetryQueue and the read-repair
+// verifications
+func (r *cachedRepository) Shutdown(ctx context.Context) error {
+	err := r.delayedEvictor.Shutdown(ctx)
+	if rErr := r.readOnlyCachedRepository.Shutdown(ctx); rErr != nil {
+		err = rErr
+	}
+	if r.retryWorker != nil {
+		if wErr := r.retryWorker.Shutdown(ctx); wErr != nil {
+			err = wErr
+		}
+	}
+	return err
+}
+
+// updates or evicts the written value from each cache according to the cache's write policy.
+//
+// Every cache is maintained even if some of them fail, in which case a *CacheMaintenanceError is returned
+// and the entries of the failed caches are enqueued for eviction in the RetryQueue
+func (r *cachedRepository) applyWritePolicies(ctx context.Context, value interface{}) error {
+	var errs []error
+	retried := r.retryWorker != nil
+	for _, v := range r.cachesInWriteOrder() {
+		err := r.applyWritePolicy(ctx, v, value)
+		if err == nil {
+			continue
 		}
+		errs = append(errs, err)
+		if r.retryWorker != nil {
+			if qErr := r.retryWorker.enqueue(v, value); qErr != nil {
+				errs = append(errs, qErr)
+				retried = false
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return &CacheMaintenanceError{Errors: errs, Retried: retried}
 	}
 	return nil
 }
 
-func (r *cachedRepository) evictFromCaches(ctx context.Context, value interface{}) error {
+// returns the caches in the order write policies are applied. Caches that store the primary keys of the
+// values go first, so they can read the values being replaced from the cache of the primary keys
+func (r *cachedRepository) cachesInWriteOrder() []Cache {
+	caches := make([]Cache, 0, len(r.caches))
 	for _, v := range r.caches {
-		err := v.DeleteValue(ctx, value)
-		if err != nil {
+		if _, ok := v.Handler.(*indirectUniqueKeyCacheHandler); ok {
+			caches = append(caches, v)
+		}
+	}
+	for _, v := range r.caches {
+		if _, ok := v.Handler.(*indirectUniqueKeyCacheHandler); !ok {
+			caches = append(caches, v)
+		}
+	}
+	return caches
+}
+
+func (r *cachedRepository) applyWritePolicy(ctx context.Context, cache Cache, value interface{}) error {
+	switch cache.WritePolicy {
+	case WritePolicyUpdate:
+		return cache.Set(ctx, value)
+	case WritePolicyEvict:
+		return cache.DeleteValue(ctx, value)
+	case WritePolicyDelayedDoubleDelete:
+		if err := cache.DeleteValue(ctx, value); err != nil {
 			return err
 		}
+		r.delayedEvictor.evictAfter(cache, value, cache.DoubleDeleteDelay)
+		return nil
+	default:
+		return nil
 	}
-	return nil
+}
+
+func (r *cachedRepository) registeredWriter() DataWriter {
+	return r.writer
 }
@@ -17,7 +17,7 @@ type compositeCacheStore struct {
 // If a cache returns a result (key is found), then that result will be used and further caches will not be queried.
 //
 // Write operations will be propagates to all delegate caches
-func NewCompositeCacheStore(delegates ...datarepo.CacheStore) datarepo.CacheStore {
+func NewCompositeCacheStore(delegates ...datarepo.CacheStore) datarepo.MetadataCacheStore {
 	if len(delegates) == 0 {
 		panic("Can't create a composite cache store with no delegate caches")
 	}
@@ -34,18 +34,19 @@ func (c *compositeCacheStore) Set(ctx context.Context, key string, value interfa
 	}
 }
 
-func (c *compositeCacheStore) Get(ctx context.Context, key string, out interface{}) (bool, error) {
+func (c *compositeCacheStore) TrySet(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
 	var err error
 	for _, cache := range c.delegates {
-		found, cErr := cache.Get(ctx, key, out)
-		if found {
-			return true, cErr
-		}
-		if cErr != nil {
+		if cErr := datarepo.TrySet(ctx, cache, key, value, expiration); cErr != nil {
 			err = cErr
 		}
 	}
-	return false, err
+	return err
+}
+
+func (c *compositeCacheStore) Get(ctx context.Context, key string, out interface{}) (bool, error) {
+	found, _, err := c.GetWithMetadata(ctx, key, out)
+	return found, err
 }
 
 func (c *compositeCacheStore) Delete(ctx context.Context, key string) error {
@@ -59,7 +60,27 @@ func (c *compositeCacheStore) Delete(ctx context.Context, key string) error {
 }
 
 func (c *compositeCacheStore) GetMulti(ctx context.Context, keys []string, out interface{}) ([]bool, error) {
+	found, _, err := c.GetMultiWithMetadata(ctx, keys, out)
+	return found, err
+}
+
+func (c *compositeCacheStore) GetWithMetadata(ctx context.Context, key string, out interface{}) (bool, datarepo.EntryMetadata, error) {
+	var err error
+	for _, cache := range c.delegates {
+		found, metadata, cErr := datarepo.GetWithMetadata(ctx, cache, key, out)
+		if found {
+			return true, metadata, cErr
+		}
+		if cErr != nil {
+			err = cErr
+		}
+	}
+	return false, datarepo.EntryMetadata{}, err
+}
+
+func (c *compositeCacheStore) GetMultiWithMetadata(ctx context.Context, keys []string, out interface{}) ([]bool, []datarepo.EntryMetadata, error) {
 	found := make([]bool, len(keys))
+	metadata := make([]datarepo.EntryMetadata, len(keys))
 
 	// the out interface is expected to be of type: *[]*A assuming this cache stores elements of type A
 	sh := drreflect.NewReflectSlicePointerVHandler(out)
@@ -69,9 +90,33 @@ func (c *compositeCacheStore) GetMulti(ctx context.Context, keys []string, out i
 
 	for i, key := range keys {
 		value := th.NewPtrToElement()
-		found[i], _ = c.Get(ctx, key, value.Ptr())
+		found[i], metadata[i], _ = c.GetWithMetadata(ctx, key, value.Ptr())
 		sh.Append(value.Ptr())
 	}
 
-	return found, nil
+	return found, metadata, nil
+}
+
+// Flushes the keys that start with the prefix from every delegate cache. An error is returned if any
+// delegate cache can't flush keys, after flushing the rest of them
+func (c *compositeCacheStore) Flush(ctx context.Context, prefix string) error {
+	var err error
+	for _, cache := range c.delegates {
+		if cErr := datarepo.Flush(ctx, cache, prefix); cErr != nil {
+			err = cErr
+		}
+	}
+	return err
+}
+
+// Pings the delegate caches that support it, returning the first error found
+func (c *compositeCacheStore) Ping(ctx context.Context) error {
+	for _, cache := range c.delegates {
+		if p, ok := cache.(datarepo.Pinger); ok {
+			if err := p.Ping(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
@@ -1,24 +1,32 @@
 package memory
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
 	"github.com/coocood/freecache"
 	"github.com/merlinapp/datarepo-go"
 	"github.com/merlinapp/datarepo-go/drreflect"
+	"github.com/merlinapp/datarepo-go/internal/codec"
 	"log"
+	"sync"
 	"time"
 )
 
+// Source reported in the metadata of the entries read from the memory cache
+const Source = "memory"
+
 type memoryBasedCacheStore struct {
 	cache *freecache.Cache
+	// serializes the increments of counters
+	incrMutex sync.Mutex
 }
 
 // Creates a new CacheStore backed by a freecache Cache (github.com/coocood/freecache)
 //
 // Implementation Notes: Currently this implementation serializes the data to JSON
-// for storage in the memory cache
-func NewFreeCacheInMemoryStore(cacheSize int) datarepo.CacheStore {
+// for storage in the memory cache, together with the time the data was stored and the schema
+// fingerprint of its type. Entries stored with a different schema are treated as misses
+func NewFreeCacheInMemoryStore(cacheSize int) datarepo.MetadataCacheStore {
 	cache := freecache.NewCache(cacheSize)
 
 	store := memoryBasedCacheStore{
@@ -28,30 +36,22 @@ func NewFreeCacheInMemoryStore(cacheSize int) datarepo.CacheStore {
 }
 
 func (c *memoryBasedCacheStore) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) {
-	bytesToCache, err := cacheMarshal(value)
-	if err != nil {
-		log.Println("Error setting cache value for key: ", key, "-", err)
-		return
-	}
-
-	if err = c.cache.Set([]byte(key), bytesToCache, int(expiration.Seconds())); err != nil {
+	if err := c.TrySet(ctx, key, value, expiration); err != nil {
 		log.Println("Error setting cache value for key: ", key, "-", err)
 	}
 }
 
-func (c *memoryBasedCacheStore) Get(ctx context.Context, key string, out interface{}) (bool, error) {
-	cachedBytes, err := c.cache.Get([]byte(key))
+func (c *memoryBasedCacheStore) TrySet(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	bytesToCache, err := cacheMarshal(value)
 	if err != nil {
-		if err == freecache.ErrNotFound {
-			return false, nil
-		}
-		return false, err
+		return err
 	}
+	return c.cache.Set([]byte(key), bytesToCache, int(expiration.Seconds()))
+}
 
-	if err = cacheUnmarshal(cachedBytes, out); err != nil {
-		return false, err
-	}
-	return true, nil
+func (c *memoryBasedCacheStore) Get(ctx context.Context, key string, out interface{}) (bool, error) {
+	found, _, err := c.GetWithMetadata(ctx, key, out)
+	return found, err
 }
 
 func (c *memoryBasedCacheStore) Delete(ctx context.Context, key string) error {
@@ -60,7 +60,38 @@ func (c *memoryBasedCacheStore) Delete(ctx context.Context, key string) error {
 }
 
 func (c *memoryBasedCacheStore) GetMulti(ctx context.Context, keys []string, out interface{}) ([]bool, error) {
+	found, _, err := c.GetMultiWithMetadata(ctx, keys, out)
+	return found, err
+}
+
+func (c *memoryBasedCacheStore) GetWithMetadata(ctx context.Context, key string, out interface{}) (bool, datarepo.EntryMetadata, error) {
+	cachedBytes, expireAt, err := c.cache.GetWithExpiration([]byte(key))
+	if err != nil {
+		if err == freecache.ErrNotFound {
+			return false, datarepo.EntryMetadata{}, nil
+		}
+		return false, datarepo.EntryMetadata{}, err
+	}
+
+	storedAt, err := codec.Unmarshal(cachedBytes, out)
+	if err != nil {
+		// entries that can't be decoded are treated as misses so they're fetched and stored again
+		if err != codec.ErrSchemaMismatch {
+			log.Println("Evicting cache entry that can't be decoded for key: ", key, "-", err)
+			c.cache.Del([]byte(key))
+		}
+		return false, datarepo.EntryMetadata{}, nil
+	}
+	metadata := datarepo.EntryMetadata{StoredAt: storedAt, Source: Source}
+	if expireAt != 0 {
+		metadata.ExpiresAt = time.Unix(int64(expireAt), 0)
+	}
+	return true, metadata, nil
+}
+
+func (c *memoryBasedCacheStore) GetMultiWithMetadata(ctx context.Context, keys []string, out interface{}) ([]bool, []datarepo.EntryMetadata, error) {
 	found := make([]bool, len(keys))
+	metadata := make([]datarepo.EntryMetadata, len(keys))
 
 	// the out interface is expected to be of type: *[]*A assuming this cache stores elements of type A
 	sh := drreflect.NewReflectSlicePointerVHandler(out)
@@ -70,17 +101,43 @@ func (c *memoryBasedCacheStore) GetMulti(ctx context.Context, keys []string, out
 
 	for i, key := range keys {
 		value := th.NewPtrToElement()
-		found[i], _ = c.Get(ctx, key, value.Ptr())
+		found[i], metadata[i], _ = c.GetWithMetadata(ctx, key, value.Ptr())
 		sh.Append(value.Ptr())
 	}
 
-	return found, nil
+	return found, metadata, nil
 }
 
-func cacheMarshal(v interface{}) ([]byte, error) {
-	return json.Marshal(v)
+// Deletes the keys that start with the prefix, iterating over all the entries of the cache
+func (c *memoryBasedCacheStore) Flush(ctx context.Context, prefix string) error {
+	var keys [][]byte
+	it := c.cache.NewIterator()
+	for entry := it.Next(); entry != nil; entry = it.Next() {
+		if bytes.HasPrefix(entry.Key, []byte(prefix)) {
+			keys = append(keys, entry.Key)
+		}
+	}
+	for _, key := range keys {
+		c.cache.Del(key)
+	}
+	return nil
 }
 
-func cacheUnmarshal(b []byte, v interface{}) error {
-	return json.Unmarshal(b, v)
+func (c *memoryBasedCacheStore) Incr(ctx context.Context, key string) (int64, error) {
+	c.incrMutex.Lock()
+	defer c.incrMutex.Unlock()
+	var value int64
+	if _, _, err := c.GetWithMetadata(ctx, key, &value); err != nil {
+		return 0, err
+	}
+	value++
+	bytesToCache, err := cacheMarshal(value)
+	if err != nil {
+		return 0, err
+	}
+	return value, c.cache.Set([]byte(key), bytesToCache, 0)
+}
+
+func cacheMarshal(v interface{}) ([]byte, error) {
+	return codec.Marshal(v, time.Now())
 }
@@ -2,6 +2,7 @@ package nocache
 
 import (
 	"context"
+	"github.com/merlinapp/datarepo-go"
 	"time"
 )
 
@@ -24,3 +25,16 @@ func (c *Store) GetMulti(ctx context.Context, keys []string, out interface{}) ([
 	found := make([]bool, len(keys))
 	return found, nil
 }
+
+func (c *Store) GetWithMetadata(ctx context.Context, key string, out interface{}) (bool, datarepo.EntryMetadata, error) {
+	return false, datarepo.EntryMetadata{}, nil
+}
+
+func (c *Store) GetMultiWithMetadata(ctx context.Context, keys []string, out interface{}) ([]bool, []datarepo.EntryMetadata, error) {
+	found := make([]bool, len(keys))
+	return found, make([]datarepo.EntryMetadata, len(keys)), nil
+}
+
+func (c *Store) Flush(ctx context.Context, prefix string) error {
+	return nil
+}
@@ -2,15 +2,22 @@ package redis
 
 import (
 	"context"
-	"encoding/json"
 	redisCache "github.com/go-redis/cache"
 	"github.com/go-redis/redis"
 	"github.com/merlinapp/datarepo-go"
 	"github.com/merlinapp/datarepo-go/drreflect"
+	"github.com/merlinapp/datarepo-go/internal/codec"
 	"log"
+	"strings"
 	"time"
 )
 
+// Source reported in the metadata of the entries read from Redis
+const Source = "redis"
+
+// number of keys requested on every SCAN iteration when flushing keys
+const flushBatchSize = 1000
+
 type redisBasedCacheStore struct {
 	redisClient *redis.Client
 	cache       *redisCache.Codec
@@ -19,14 +26,15 @@ type redisBasedCacheStore struct {
 // Creates a new CacheStore backed by the provided redis client
 //
 // Implementation Notes: Currently this implementation serializes the data to JSON
-// for storage in Redis
-func NewRedisCacheStore(redisClient *redis.Client) datarepo.CacheStore {
+// for storage in Redis, together with the time the data was stored and the schema fingerprint of
+// its type. Entries stored with a different schema are treated as misses
+func NewRedisCacheStore(redisClient *redis.Client) datarepo.MetadataCacheStore {
 	_, err := redisClient.Ping().Result()
 	if err != nil {
 		panic(err)
 	}
 
-	codec := &redisCache.Codec{
+	redisCodec := &redisCache.Codec{
 		Redis:     redisClient,
 		Marshal:   cacheMarshal,
 		Unmarshal: cacheUnmarshal,
@@ -34,31 +42,35 @@ func NewRedisCacheStore(redisClient *redis.Client) datarepo.CacheStore {
 
 	store := redisBasedCacheStore{
 		redisClient: redisClient,
-		cache:       codec,
+		cache:       redisCodec,
 	}
 	return &store
 }
 
 func (c *redisBasedCacheStore) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) {
-	err := c.cache.Set(&redisCache.Item{
+	if err := c.TrySet(ctx, key, value, expiration); err != nil {
+		log.Println("Error setting cache value for key: ", key, "-", err)
+	}
+}
+
+func (c *redisBasedCacheStore) TrySet(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return c.cache.Set(&redisCache.Item{
 		Key:        key,
 		Object:     value,
 		Expiration: expiration,
 	})
-	if err != nil {
-		log.Println("Error setting cache value for key: ", key, "-", err)
-	}
 }
 
 func (c *redisBasedCacheStore) Get(ctx context.Context, key string, out interface{}) (bool, error) {
-	if err := c.cache.Get(key, out); err != nil {
-		if err == redisCache.ErrCacheMiss {
+	b, err := c.redisClient.Get(key).Bytes()
+	if err != nil {
+		if err == redis.Nil {
 			return false, nil
 		}
 		return false, err
 	}
-
-	return true, nil
+	found, _ := c.decode(key, b, out)
+	return found, nil
 }
 
 func (c *redisBasedCacheStore) Delete(ctx context.Context, key string) error {
@@ -71,11 +83,69 @@ func (c *redisBasedCacheStore) Delete(ctx context.Context, key string) error {
 }
 
 func (c *redisBasedCacheStore) GetMulti(ctx context.Context, keys []string, out interface{}) ([]bool, error) {
-	found := make([]bool, len(keys))
 	rawResults, err := c.redisClient.MGet(keys...).Result()
 	if err != nil {
-		return found, err
+		return make([]bool, len(keys)), err
 	}
+	found, _ := c.decodeMulti(keys, rawResults, nil, out)
+	return found, nil
+}
+
+// Same as Get but also returns the metadata of the entry, reading its time to live in the same round trip
+func (c *redisBasedCacheStore) GetWithMetadata(ctx context.Context, key string, out interface{}) (bool, datarepo.EntryMetadata, error) {
+	pipe := c.redisClient.Pipeline()
+	get := pipe.Get(key)
+	ttl := pipe.PTTL(key)
+	_, _ = pipe.Exec()
+
+	b, err := get.Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return false, datarepo.EntryMetadata{}, nil
+		}
+		return false, datarepo.EntryMetadata{}, err
+	}
+	found, metadata := c.decode(key, b, out)
+	if found {
+		metadata.ExpiresAt = expiresAt(ttl)
+	}
+	return found, metadata, nil
+}
+
+// Same as GetMulti but also returns the metadata of the entries, reading their time to live in the same
+// round trip
+func (c *redisBasedCacheStore) GetMultiWithMetadata(ctx context.Context, keys []string, out interface{}) ([]bool, []datarepo.EntryMetadata, error) {
+	pipe := c.redisClient.Pipeline()
+	mget := pipe.MGet(keys...)
+	ttls := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		ttls[i] = pipe.PTTL(key)
+	}
+	_, _ = pipe.Exec()
+
+	rawResults, err := mget.Result()
+	if err != nil {
+		return make([]bool, len(keys)), make([]datarepo.EntryMetadata, len(keys)), err
+	}
+	found, metadata := c.decodeMulti(keys, rawResults, ttls, out)
+	return found, metadata, nil
+}
+
+// decodes an entry, discarding it if it can't be decoded
+func (c *redisBasedCacheStore) decode(key string, b []byte, out interface{}) (bool, datarepo.EntryMetadata) {
+	storedAt, err := codec.Unmarshal(b, out)
+	if err != nil {
+		c.discard(key, err)
+		return false, datarepo.EntryMetadata{}
+	}
+	return true, datarepo.EntryMetadata{StoredAt: storedAt, Source: Source}
+}
+
+// decodes the entries returned by MGET into out, discarding the ones that can't be decoded. The expiration
+// of the entries is only set if their time to live was read
+func (c *redisBasedCacheStore) decodeMulti(keys []string, rawResults []interface{}, ttls []*redis.DurationCmd, out interface{}) ([]bool, []datarepo.EntryMetadata) {
+	found := make([]bool, len(keys))
+	metadata := make([]datarepo.EntryMetadata, len(keys))
 
 	// the out interface is expected to be of type: *[]*A assuming this cache stores elements of type A
 	sh := drreflect.NewReflectSlicePointerVHandler(out)
@@ -87,22 +157,81 @@ func (c *redisBasedCacheStore) GetMulti(ctx context.Context, keys []string, out
 		value := th.NewPtrToElement()
 		if rawResult != nil {
 			rawString := rawResult.(string)
-			err = cacheUnmarshal([]byte(rawString), value.Ptr())
-
-			if err == nil {
-				found[i] = true
+			found[i], metadata[i] = c.decode(keys[i], []byte(rawString), value.Ptr())
+			if !found[i] {
+				value = th.NewPtrToElement()
+			} else if ttls != nil {
+				metadata[i].ExpiresAt = expiresAt(ttls[i])
 			}
 		}
 		sh.Append(value.Ptr())
 	}
 
-	return found, nil
+	return found, metadata
+}
+
+// returns when an entry expires given the result of its PTTL command, zero if it doesn't expire or
+// its time to live couldn't be read
+func expiresAt(ttl *redis.DurationCmd) time.Time {
+	d, err := ttl.Result()
+	if err != nil || d <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(d)
+}
+
+// Entries that can't be decoded are treated as misses so they're fetched and stored again. Entries stored
+// with a different schema are overwritten when that happens, other entries are evicted
+func (c *redisBasedCacheStore) discard(key string, err error) {
+	if err == codec.ErrSchemaMismatch {
+		return
+	}
+	log.Println("Evicting cache entry that can't be decoded for key: ", key, "-", err)
+	if err := c.redisClient.Del(key).Err(); err != nil {
+		log.Println("Error evicting cache entry for key: ", key, "-", err)
+	}
+}
+
+// Deletes the keys that start with the prefix, finding them with SCAN and deleting them with UNLINK so
+// that Redis isn't blocked while the keys are flushed
+func (c *redisBasedCacheStore) Flush(ctx context.Context, prefix string) error {
+	var cursor uint64
+	for {
+		keys, next, err := c.redisClient.Scan(cursor, escapePattern(prefix)+"*", flushBatchSize).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err := c.redisClient.Unlink(keys...).Err(); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+func (c *redisBasedCacheStore) Incr(ctx context.Context, key string) (int64, error) {
+	return c.redisClient.Incr(key).Result()
+}
+
+func (c *redisBasedCacheStore) Ping(ctx context.Context) error {
+	return c.redisClient.Ping().Err()
+}
+
+// escapes the characters of a key prefix that have a special meaning in the patterns of SCAN
+func escapePattern(prefix string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+	return replacer.Replace(prefix)
 }
 
 func cacheMarshal(v interface{}) ([]byte, error) {
-	return json.Marshal(v)
+	return codec.Marshal(v, time.Now())
 }
 
 func cacheUnmarshal(b []byte, v interface{}) error {
-	return json.Unmarshal(b, v)
+	_, err := codec.Unmarshal(b, v)
+	return err
 }
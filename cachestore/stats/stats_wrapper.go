@@ -3,11 +3,12 @@ package stats
 import (
 	"context"
 	"github.com/merlinapp/datarepo-go"
+	"sync/atomic"
 	"time"
 )
 
 type StatsCacheStore interface {
-	datarepo.CacheStore
+	datarepo.MetadataCacheStore
 	ClearStats()
 	// number of Set operations performed in the CacheStore
 	Sets() int64
@@ -39,64 +40,95 @@ func (s *statsCacheStore) Delete(ctx context.Context, key string) error {
 	if err != nil {
 		return err
 	}
-	s.dels++
+	atomic.AddInt64(&s.dels, 1)
 	return nil
 }
 
 func (s *statsCacheStore) Get(ctx context.Context, key string, out interface{}) (bool, error) {
-	found, err := s.delegate.Get(ctx, key, out)
+	found, _, err := s.GetWithMetadata(ctx, key, out)
+	return found, err
+}
+
+func (s *statsCacheStore) GetMulti(ctx context.Context, keys []string, out interface{}) ([]bool, error) {
+	foundArr, _, err := s.GetMultiWithMetadata(ctx, keys, out)
+	return foundArr, err
+}
+
+func (s *statsCacheStore) GetWithMetadata(ctx context.Context, key string, out interface{}) (bool, datarepo.EntryMetadata, error) {
+	found, metadata, err := datarepo.GetWithMetadata(ctx, s.delegate, key, out)
 	if err != nil {
-		return found, err
+		return found, metadata, err
 	}
 
 	if found {
-		s.hits++
+		atomic.AddInt64(&s.hits, 1)
 	} else {
-		s.miss++
+		atomic.AddInt64(&s.miss, 1)
 	}
-	return found, err
+	return found, metadata, err
 }
 
-func (s *statsCacheStore) GetMulti(ctx context.Context, keys []string, out interface{}) ([]bool, error) {
-	foundArr, err := s.delegate.GetMulti(ctx, keys, out)
+func (s *statsCacheStore) GetMultiWithMetadata(ctx context.Context, keys []string, out interface{}) ([]bool, []datarepo.EntryMetadata, error) {
+	foundArr, metadata, err := datarepo.GetMultiWithMetadata(ctx, s.delegate, keys, out)
 	if err != nil {
-		return foundArr, err
+		return foundArr, metadata, err
 	}
 
 	for _, v := range foundArr {
 		if v {
-			s.hits++
+			atomic.AddInt64(&s.hits, 1)
 		} else {
-			s.miss++
+			atomic.AddInt64(&s.miss, 1)
 		}
 	}
-	return foundArr, err
+	return foundArr, metadata, err
 }
 
 func (s *statsCacheStore) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) {
 	s.delegate.Set(ctx, key, value, expiration)
-	s.sets++
+	atomic.AddInt64(&s.sets, 1)
+}
+
+func (s *statsCacheStore) TrySet(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	err := datarepo.TrySet(ctx, s.delegate, key, value, expiration)
+	atomic.AddInt64(&s.sets, 1)
+	return err
+}
+
+func (s *statsCacheStore) Flush(ctx context.Context, prefix string) error {
+	return datarepo.Flush(ctx, s.delegate, prefix)
+}
+
+func (s *statsCacheStore) Incr(ctx context.Context, key string) (int64, error) {
+	return datarepo.Incr(ctx, s.delegate, key)
 }
 
 func (s *statsCacheStore) ClearStats() {
-	s.hits = 0
-	s.miss = 0
-	s.dels = 0
-	s.sets = 0
+	atomic.StoreInt64(&s.hits, 0)
+	atomic.StoreInt64(&s.miss, 0)
+	atomic.StoreInt64(&s.dels, 0)
+	atomic.StoreInt64(&s.sets, 0)
 }
 
 func (s *statsCacheStore) Sets() int64 {
-	return s.sets
+	return atomic.LoadInt64(&s.sets)
 }
 
 func (s *statsCacheStore) Hits() int64 {
-	return s.hits
+	return atomic.LoadInt64(&s.hits)
 }
 
 func (s *statsCacheStore) Miss() int64 {
-	return s.miss
+	return atomic.LoadInt64(&s.miss)
 }
 
 func (s *statsCacheStore) Dels() int64 {
-	return s.dels
+	return atomic.LoadInt64(&s.dels)
+}
+
+func (s *statsCacheStore) Ping(ctx context.Context) error {
+	if p, ok := s.delegate.(datarepo.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
 }
@@ -0,0 +1,290 @@
+package datarepo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/merlinapp/datarepo-go/drreflect"
+	"log"
+	"reflect"
+	"strconv"
+)
+
+// Operation performed on a row of the repository
+type ChangeOperation string
+
+const (
+	OperationCreate ChangeOperation = "create"
+	OperationUpdate ChangeOperation = "update"
+	OperationDelete ChangeOperation = "delete"
+)
+
+// A change made to the data of a repository, usually outside of the CachedRepository, for example,
+// by a batch job or another service. The caches affected by the change are those holding the old or
+// the new values of the changed row.
+//
+// The affected caches can be described by the values of the row, by the values of the key fields or
+// by both
+type ChangeEvent struct {
+	// Identifier of the event in its source, if any, for example, to acknowledge it in an AckChangeEventSource
+	ID string
+	// Entity name of the repository, as registered in the Registry
+	Entity string
+	// Operation performed on the row
+	Operation ChangeOperation
+	// Value of the row before the change, nil if unknown or if the row was created. It's expected to be
+	// of the type handled by the repository or a pointer to it, or its JSON encoding as a json.RawMessage
+	OldValue interface{}
+	// Value of the row after the change, nil if unknown or if the row was deleted. It's expected to be
+	// of the type handled by the repository or a pointer to it, or its JSON encoding as a json.RawMessage
+	NewValue interface{}
+	// Values of the key fields affected by the change, by key field name. For example, when a book changes
+	// of author: {"ID": [bookId], "AuthorID": [oldAuthorId, newAuthorId]}
+	//
+	// Values decoded from JSON as json.Number are converted to the type of the key field, and nil values
+	// are ignored
+	Keys map[string][]interface{}
+}
+
+// A source of ChangeEvents, for example, a reader of the database binlog or of an outbox table
+type ChangeEventSource interface {
+	// Returns the next event, blocking until an event is available or the context is done.
+	//
+	// ErrSourceClosed is returned once the source has no more events
+	Next(ctx context.Context) (ChangeEvent, error)
+}
+
+// A ChangeEventSource that needs to be notified once an event has been applied, for example, to commit
+// the offset of the event
+type AckChangeEventSource interface {
+	ChangeEventSource
+	// Acknowledges that the event has been applied to the caches
+	Ack(ctx context.Context, event ChangeEvent) error
+}
+
+// Error returned by a ChangeEventSource once it has no more events
+var ErrSourceClosed = errors.New("the change event source is closed")
+
+// Defines how a ChangeEventConsumer updates the caches affected by an event
+type ChangeEventMode int
+
+const (
+	// Evicts the affected cache entries
+	ChangeEventInvalidate ChangeEventMode = iota
+	// Reloads the affected cache entries from the DataFetcher
+	ChangeEventRefresh
+)
+
+// Options of a ChangeEventConsumer
+type ChangeEventConsumerOptions struct {
+	// How the affected caches are updated, by default the affected entries are evicted
+	Mode ChangeEventMode
+	// Function invoked when an event can't be applied. By default the error is logged and the
+	// consumer continues with the next event
+	OnError func(event ChangeEvent, err error)
+}
+
+// A ChangeEventConsumer applies the ChangeEvents of a source to the caches of the repositories
+// registered in a Registry. Events of entities that aren't registered are ignored
+type ChangeEventConsumer struct {
+	registry *Registry
+	source   ChangeEventSource
+	options  ChangeEventConsumerOptions
+}
+
+type channelChangeEventSource struct {
+	events <-chan ChangeEvent
+}
+
+// Creates a ChangeEventSource that reads the events sent to the provided channel. The source is closed
+// when the channel is closed
+func NewChannelChangeEventSource(events <-chan ChangeEvent) ChangeEventSource {
+	return &channelChangeEventSource{events: events}
+}
+
+func (s *channelChangeEventSource) Next(ctx context.Context) (ChangeEvent, error) {
+	select {
+	case <-ctx.Done():
+		return ChangeEvent{}, ctx.Err()
+	case event, ok := <-s.events:
+		if !ok {
+			return ChangeEvent{}, ErrSourceClosed
+		}
+		return event, nil
+	}
+}
+
+// Creates a new ChangeEventConsumer that applies the events of the source to the repositories of the registry
+func NewChangeEventConsumer(registry *Registry, source ChangeEventSource, options ChangeEventConsumerOptions) *ChangeEventConsumer {
+	if options.OnError == nil {
+		options.OnError = func(event ChangeEvent, err error) {
+			log.Println("Error applying change event of entity: ", event.Entity, "-", err)
+		}
+	}
+	return &ChangeEventConsumer{
+		registry: registry,
+		source:   source,
+		options:  options,
+	}
+}
+
+// Consumes the events of the source until the context is done or the source is closed.
+//
+// Returns nil if the source was closed, or the error that stopped the consumer otherwise
+func (c *ChangeEventConsumer) Run(ctx context.Context) error {
+	for {
+		event, err := c.source.Next(ctx)
+		if err != nil {
+			if err == ErrSourceClosed {
+				return nil
+			}
+			return err
+		}
+		if err := c.Apply(ctx, event); err != nil {
+			c.options.OnError(event, err)
+			continue
+		}
+		if ackSource, ok := c.source.(AckChangeEventSource); ok {
+			if err := ackSource.Ack(ctx, event); err != nil {
+				c.options.OnError(event, err)
+			}
+		}
+	}
+}
+
+// Invalidates or refreshes the cache entries affected by the event
+func (c *ChangeEventConsumer) Apply(ctx context.Context, event ChangeEvent) error {
+	repo, ok := c.registry.Repository(event.Entity)
+	if !ok {
+		return nil
+	}
+	keys, err := affectedKeys(repo, event)
+	if err != nil {
+		return err
+	}
+	for keyFieldName, ids := range keys {
+		var err error
+		if c.options.Mode == ChangeEventRefresh {
+			_, err = repo.Refresh(ctx, keyFieldName, ids...)
+		} else {
+			err = repo.Invalidate(ctx, keyFieldName, ids...)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// returns the distinct keys affected by the event per key field name with a cache in the repository
+func affectedKeys(repo ReadOnlyCachedRepository, event ChangeEvent) (map[string][]interface{}, error) {
+	result := make(map[string][]interface{})
+	add := func(keyFieldName string, id interface{}) {
+		// ids aren't compared with == as they may not be comparable, like slices
+		for _, existent := range result[keyFieldName] {
+			if reflect.DeepEqual(existent, id) {
+				return
+			}
+		}
+		result[keyFieldName] = append(result[keyFieldName], id)
+	}
+
+	caches := make(map[string]Cache)
+	if rc, ok := repo.(repositoryComponents); ok {
+		caches = rc.registeredCaches()
+	}
+	var th drreflect.StructTypeHandler
+	if dataType := cachedDataType(caches); dataType != nil {
+		th = drreflect.NewReflectStructTypeHandler(dataType)
+	}
+	for keyFieldName, ids := range event.Keys {
+		if _, ok := caches[keyFieldName]; !ok && len(caches) > 0 {
+			continue
+		}
+		for _, id := range ids {
+			if id != nil {
+				add(keyFieldName, eventKey(th, keyFieldName, id))
+			}
+		}
+	}
+	for _, value := range []interface{}{event.OldValue, event.NewValue} {
+		value, err := eventValue(th, value)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			continue
+		}
+		for keyFieldName, cache := range caches {
+			if id, ok := cache.Handler.ValueKey(value); ok {
+				add(keyFieldName, id)
+			}
+		}
+	}
+	return result, nil
+}
+
+// returns the type of the values stored in the caches, nil if there are no caches
+func cachedDataType(caches map[string]Cache) reflect.Type {
+	for _, cache := range caches {
+		if cache.Handler.SingleResultPerKey() {
+			return cache.Handler.CachedType()
+		}
+		// non-unique key caches store slices of pointers to the values
+		return cache.Handler.CachedType().Elem().Elem()
+	}
+	return nil
+}
+
+// decodes a value of an event encoded as a json.RawMessage into the type of the values of the repository
+func eventValue(th drreflect.StructTypeHandler, value interface{}) (interface{}, error) {
+	raw, ok := value.(json.RawMessage)
+	if !ok || th == nil {
+		return value, nil
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	decoded := th.NewPtrToElement().Ptr()
+	if err := json.Unmarshal(raw, decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// converts a key decoded from JSON as a json.Number to the type of the key field, so that it matches the
+// keys of the values stored in the caches. Numbers of fields of unknown type are kept as integers if possible
+func eventKey(th drreflect.StructTypeHandler, keyFieldName string, id interface{}) interface{} {
+	number, ok := id.(json.Number)
+	if !ok {
+		return id
+	}
+	if th != nil {
+		if fieldType, ok := th.FieldType(keyFieldName); ok {
+			var converted interface{}
+			var err error
+			switch fieldType.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				converted, err = number.Int64()
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				converted, err = strconv.ParseUint(number.String(), 10, 64)
+			case reflect.Float32, reflect.Float64:
+				converted, err = number.Float64()
+			case reflect.String:
+				converted = number.String()
+			default:
+				err = errors.New("unsupported key type")
+			}
+			if err == nil {
+				return reflect.ValueOf(converted).Convert(fieldType).Interface()
+			}
+		}
+	}
+	if i, err := number.Int64(); err == nil {
+		return i
+	}
+	if f, err := number.Float64(); err == nil {
+		return f
+	}
+	return number.String()
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// A key field of the generated repository
+type keyField struct {
+	Name string
+	Type string
+}
+
+// A field of the struct read by the generated accessors
+type accessorField struct {
+	Name string
+	// whether the field is a pointer, which the accessor dereferences
+	Pointer bool
+}
+
+// A field of the struct serialized by the generated codec
+type codecField struct {
+	Name string
+	// name of the field in the JSON encoding
+	JSONName string
+	// Go literal of the JSON encoding of the name followed by a colon
+	Key       string
+	OmitEmpty bool
+	// whether the field is a pointer to a value of its Kind
+	Pointer bool
+	// suffix of the drreflect functions that encode and decode the field, like String or Int. It's empty
+	// if the field is serialized with encoding/json
+	Kind string
+	// type of the value of the field, dereferenced if it's a pointer
+	Type string
+}
+
+// The codec of the struct, generated when the JSON encoding of the struct can be reproduced without reflection
+type codec struct {
+	Fields []codecField
+	// whether the encoding of a field can fail
+	FallibleAppend bool
+}
+
+type templateData struct {
+	Package   string
+	Type      string
+	Var       string
+	Imports   []string
+	Fields    []accessorField
+	Codec     *codec
+	Unique    []keyField
+	NonUnique []keyField
+	Gorm      bool
+}
+
+// whether the context package is used, by the methods that read the caches
+func (d templateData) Context() bool {
+	return len(d.Unique) > 0 || len(d.NonUnique) > 0
+}
+
+// whether the encoding/json package is used, by the codec to decode fields
+func (d templateData) JSON() bool {
+	if d.Codec == nil {
+		return false
+	}
+	for _, f := range d.Codec.Fields {
+		if f.Kind == "" {
+			return true
+		}
+	}
+	return false
+}
+
+var fileTemplate = template.Must(template.New("file").Parse(`// Code generated by datarepo-gen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- if .Context}}
+	"context"
+{{- end}}
+{{- if .JSON}}
+	"encoding/json"
+{{- end}}
+{{range .Imports}}	{{.}}
+{{end}}
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/drreflect"
+{{- if .Gorm}}
+	"github.com/jinzhu/gorm"
+	repogorm "github.com/merlinapp/datarepo-go/repo/gorm"
+{{- end}}
+)
+
+// Accessors of the fields of {{.Type}}, used instead of reflection
+var {{.Var}}Fields = drreflect.FieldAccessors[{{.Type}}]{
+{{- range .Fields}}
+{{- if .Pointer}}
+	"{{.Name}}": func(v *{{$.Type}}) interface{} {
+		if v.{{.Name}} == nil {
+			return nil
+		}
+		return *v.{{.Name}}
+	},
+{{- else}}
+	"{{.Name}}": func(v *{{$.Type}}) interface{} { return v.{{.Name}} },
+{{- end}}
+{{- end}}
+}
+
+{{- with .Codec}}
+
+// Names of the fields of {{$.Type}} in its JSON encoding
+var {{$.Var}}JSONNames = []string{ {{- range $i, $f := .Fields}}{{if $i}}, {{end}}"{{$f.JSONName}}"{{end -}} }
+
+// Codec of {{$.Type}}, which produces and accepts the same JSON as encoding/json without reflection
+var {{$.Var}}Codec = drreflect.Codec[{{$.Type}}]{
+	Append: func(buf []byte, v *{{$.Type}}) ([]byte, error) {
+{{- if .FallibleAppend}}
+		var err error
+{{- end}}
+		buf = append(buf, '{')
+		start := len(buf)
+{{- range .Fields}}
+{{- if .Pointer}}
+{{- if .OmitEmpty}}
+		if v.{{.Name}} != nil {
+			buf = drreflect.AppendJSONKey(buf, start, {{.Key}})
+			{{template "append" .}}
+		}
+{{- else}}
+		buf = drreflect.AppendJSONKey(buf, start, {{.Key}})
+		if v.{{.Name}} == nil {
+			buf = append(buf, "null"...)
+		} else {
+			{{template "append" .}}
+		}
+{{- end}}
+{{- else if .OmitEmpty}}
+		if {{template "nonEmpty" .}} {
+			buf = drreflect.AppendJSONKey(buf, start, {{.Key}})
+			{{template "append" .}}
+		}
+{{- else}}
+		buf = drreflect.AppendJSONKey(buf, start, {{.Key}})
+		{{template "append" .}}
+{{- end}}
+{{- end}}
+		return append(buf, '}'), nil
+	},
+	Decode: func(data []byte, v *{{$.Type}}) error {
+		return drreflect.DecodeJSONObject(data, v, {{$.Var}}JSONNames, func(name string, value []byte) error {
+			switch name {
+{{- range .Fields}}
+			case "{{.JSONName}}":
+{{- if not .Kind}}
+				return json.Unmarshal(value, &v.{{.Name}})
+{{- else if .Pointer}}
+				return drreflect.DecodeJSONPtr(value, &v.{{.Name}}, drreflect.DecodeJSON{{.Kind}}[{{.Type}}])
+{{- else}}
+				return drreflect.DecodeJSON{{.Kind}}(value, &v.{{.Name}})
+{{- end}}
+{{- end}}
+			}
+			return nil
+		})
+	},
+}
+{{- end}}
+
+func init() {
+	drreflect.Register({{.Var}}Fields)
+{{- if .Codec}}
+	drreflect.RegisterCodec({{.Var}}Codec)
+{{- end}}
+}
+
+// Typed repository of {{.Type}} values
+type {{.Type}}Repository struct {
+	*datarepo.TypedRepository[{{.Type}}]
+}
+
+// Creates a {{.Type}}Repository for the provided repository
+func New{{.Type}}Repository(repo datarepo.CachedRepository) (*{{.Type}}Repository, error) {
+	typed, err := datarepo.NewTypedRepository[{{.Type}}](repo)
+	if err != nil {
+		return nil, err
+	}
+	return &{{.Type}}Repository{typed}, nil
+}
+{{range .Unique}}
+// Retrieves the {{$.Type}} with the provided {{.Name}}. datarepo.ErrNotFound is returned if there is none
+func (r *{{$.Type}}Repository) GetBy{{.Name}}(ctx context.Context, id {{.Type}}, options ...datarepo.ReadOption) (*{{$.Type}}, error) {
+	return r.Get(ctx, "{{.Name}}", id, options...)
+}
+
+// Retrieves the {{$.Type}} values with the provided {{.Name}} values, nil for the ones that don't exist
+func (r *{{$.Type}}Repository) GetManyBy{{.Name}}(ctx context.Context, ids []{{.Type}}, options ...datarepo.ReadOption) ([]*{{$.Type}}, error) {
+	return r.GetMany(ctx, "{{.Name}}", ids, options...)
+}
+{{end}}
+{{- range .NonUnique}}
+// Retrieves the {{$.Type}} values with the provided {{.Name}}
+func (r *{{$.Type}}Repository) GetListBy{{.Name}}(ctx context.Context, id {{.Type}}, options ...datarepo.ReadOption) ([]*{{$.Type}}, error) {
+	return r.GetList(ctx, "{{.Name}}", id, options...)
+}
+{{end}}
+{{- if .Gorm}}
+// Creates a new Builder for a GORM based cached repository of {{.Type}} values that uses typed data fetchers
+func New{{.Type}}GormRepositoryBuilder(db *gorm.DB) datarepo.Builder {
+	return repogorm.TypedCachedRepositoryBuilder(db, {{.Var}}Fields)
+}
+{{- end}}
+`))
+
+func init() {
+	template.Must(fileTemplate.New("append").Parse(
+		`{{if not .Kind}}if buf, err = drreflect.AppendJSON(buf, &v.{{.Name}}); err != nil {
+				return nil, err
+			}
+		{{- else if eq .Kind "Float"}}if buf, err = drreflect.AppendJSONFloat(buf, {{if .Pointer}}*{{end}}v.{{.Name}}); err != nil {
+				return nil, err
+			}
+		{{- else}}buf = drreflect.AppendJSON{{.Kind}}(buf, {{if .Pointer}}*{{end}}v.{{.Name}})
+		{{- end}}`))
+	template.Must(fileTemplate.New("nonEmpty").Parse(
+		`{{if not .Kind}}!drreflect.IsEmptyJSONValue(v.{{.Name}})
+		{{- else if eq .Kind "String"}}v.{{.Name}} != ""
+		{{- else if eq .Kind "Bool"}}v.{{.Name}}
+		{{- else}}v.{{.Name}} != 0
+		{{- end}}`))
+}
+
+// Generates the source of the typed repository of the entity
+func generate(e *entity, options options) ([]byte, error) {
+	if len(e.fields) == 0 {
+		return nil, errors.New(e.name + " has no exported fields")
+	}
+	data := templateData{
+		Package: e.packageName,
+		Type:    e.name,
+		Var:     lowerFirst(e.name),
+		Gorm:    options.gorm,
+	}
+	for _, f := range e.fields {
+		_, pointer := f.typeExpr.(*ast.StarExpr)
+		data.Fields = append(data.Fields, accessorField{Name: f.name, Pointer: pointer})
+	}
+	data.Codec = newCodec(e)
+
+	imports := make(map[string]bool)
+	keyFields := func(names []string) ([]keyField, error) {
+		var result []keyField
+		for _, name := range names {
+			f, ok := e.field(name)
+			if !ok {
+				return nil, errors.New(name + " isn't an exported field of " + e.name +
+					", paths to nested fields and promoted fields must be read with the methods of TypedRepository")
+			}
+			// keys are the values of the fields, dereferenced if they are pointers
+			keyType := f.typeExpr
+			if star, ok := keyType.(*ast.StarExpr); ok {
+				keyType = star.X
+			}
+			fieldType, err := typeString(keyType)
+			if err != nil {
+				return nil, err
+			}
+			for _, pkg := range referencedPackages(f.typeExpr) {
+				path, ok := e.imports[pkg]
+				if !ok {
+					return nil, errors.New("import of package " + pkg + " not found for field " + name)
+				}
+				spec := `"` + path + `"`
+				if pkg != filepath.Base(path) {
+					spec = pkg + " " + spec
+				}
+				imports[spec] = true
+			}
+			result = append(result, keyField{Name: name, Type: fieldType})
+		}
+		return result, nil
+	}
+	var err error
+	if data.Unique, err = keyFields(options.unique); err != nil {
+		return nil, err
+	}
+	if data.NonUnique, err = keyFields(options.nonUnique); err != nil {
+		return nil, err
+	}
+	for i := range imports {
+		data.Imports = append(data.Imports, i)
+	}
+	sort.Strings(data.Imports)
+
+	var buf bytes.Buffer
+	if err := fileTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+// Kinds of the fields of builtin types encoded without reflection, by type name
+var codecKinds = map[string]string{
+	"string": "String",
+	"bool":   "Bool",
+	"int":    "Int", "int8": "Int", "int16": "Int", "int32": "Int", "int64": "Int", "rune": "Int",
+	"uint": "Uint", "uint8": "Uint", "uint16": "Uint", "uint32": "Uint", "uint64": "Uint", "byte": "Uint",
+	"float32": "Float", "float64": "Float",
+}
+
+// returns the codec of the entity, nil if its JSON encoding can't be reproduced by a generated codec
+func newCodec(e *entity) *codec {
+	if e.embedded || e.customJSON {
+		return nil
+	}
+	c := &codec{}
+	names := make(map[string]bool)
+	for _, f := range e.fields {
+		name, options := parseJSONTag(reflect.StructTag(f.tag).Get("json"))
+		if name == "-" && len(options) == 0 {
+			continue
+		}
+		if !isValidJSONName(name) {
+			name = f.name
+		}
+		cf := codecField{Name: f.name, JSONName: name}
+		for _, option := range options {
+			switch option {
+			case "omitempty":
+				cf.OmitEmpty = true
+			case "string", "omitzero":
+				return nil
+			}
+		}
+		// fields with the same name are left out by encoding/json
+		if names[name] {
+			return nil
+		}
+		names[name] = true
+
+		encodedName, _ := json.Marshal(name)
+		cf.Key = string(encodedName) + ":"
+		if strings.Contains(cf.Key, "`") {
+			cf.Key = strconv.Quote(cf.Key)
+		} else {
+			cf.Key = "`" + cf.Key + "`"
+		}
+		fieldType := f.typeExpr
+		if star, ok := fieldType.(*ast.StarExpr); ok {
+			fieldType = star.X
+			cf.Pointer = true
+		}
+		if ident, ok := fieldType.(*ast.Ident); ok {
+			cf.Kind = codecKinds[ident.Name]
+			cf.Type = ident.Name
+		}
+		if cf.Kind == "" {
+			// fields serialized with encoding/json are read through a pointer, like the fields of an addressable struct
+			cf.Pointer = false
+			c.FallibleAppend = true
+		} else if cf.Kind == "Float" {
+			c.FallibleAppend = true
+		}
+		c.Fields = append(c.Fields, cf)
+	}
+	if len(c.Fields) == 0 {
+		return nil
+	}
+	return c
+}
+
+// returns the name and the options of a json struct tag
+func parseJSONTag(tag string) (string, []string) {
+	parts := strings.Split(tag, ",")
+	return parts[0], parts[1:]
+}
+
+// checks if a name defined in a json struct tag is used by encoding/json
+func isValidJSONName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+	return true
+}
+
+func typeString(expr ast.Expr) (string, error) {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, token.NewFileSet(), expr); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// returns the names of the packages referenced in a type expression
+func referencedPackages(expr ast.Expr) []string {
+	var packages []string
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if selector, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := selector.X.(*ast.Ident); ok {
+				packages = append(packages, ident.Name)
+			}
+			return false
+		}
+		return true
+	})
+	return packages
+}
+
+func lowerFirst(s string) string {
+	return strings.ToLower(s[:1]) + s[1:]
+}
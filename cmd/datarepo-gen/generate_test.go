@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "updates the generated files of the model package")
+
+// Package whose entities are generated by its go:generate directives
+const modelDir = "../../integration_tests/model"
+
+func TestGeneratedFilesAreUpToDate(t *testing.T) {
+	tests := []options{
+		{typeName: "BookCategory", unique: []string{"ID"}, gorm: true},
+		{typeName: "Review", unique: []string{"ID"}, nonUnique: []string{"BookID"}},
+	}
+	for _, test := range tests {
+		t.Run(test.typeName, func(t *testing.T) {
+			e, err := parseEntity(modelDir, test.typeName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			src, err := generate(e, test)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join(modelDir, strings.ToLower(test.typeName)+"_datarepo.go")
+			if *update {
+				if err := ioutil.WriteFile(golden, src, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			expected, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(src, expected) {
+				t.Errorf("%s is outdated, run go generate or go test with -update", golden)
+			}
+		})
+	}
+}
+
+func TestGeneratedFilesCompile(t *testing.T) {
+	output, err := exec.Command("go", "vet", modelDir).CombinedOutput()
+	if err != nil {
+		t.Fatalf("the model package doesn't compile: %v\n%s", err, output)
+	}
+}
+
+func TestGenerateWithoutKeysOmitsContextImport(t *testing.T) {
+	e, err := parseEntity(modelDir, "Author")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := generate(e, options{typeName: "Author"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(src, []byte(`"context"`)) {
+		t.Errorf("context is imported by a repository without key methods:\n%s", src)
+	}
+	if !bytes.Contains(src, []byte("drreflect.RegisterCodec")) {
+		t.Errorf("the codec of Author isn't registered:\n%s", src)
+	}
+}
+
+func TestGenerateSkipsCodecOfEntitiesWithCustomJSON(t *testing.T) {
+	e := &entity{packageName: "model", name: "Event", customJSON: true, fields: []field{{name: "ID"}}}
+	if c := newCodec(e); c != nil {
+		t.Errorf("a codec was generated for an entity with its own JSON encoding: %+v", c)
+	}
+}
@@ -0,0 +1,243 @@
+// Command datarepo-gen generates a typed repository for an entity struct, which accesses the fields and
+// values of the struct without reflection.
+//
+// It's meant to be run with go generate from the package of the struct:
+//
+//	//go:generate go run github.com/merlinapp/datarepo-go/cmd/datarepo-gen -type Book -unique ID -nonunique AuthorID -gorm
+//
+// Flags:
+//
+//	-type       name of the struct, required
+//	-unique     comma-separated key fields of the unique key caches of the repository
+//	-nonunique  comma-separated key fields of the non-unique key caches of the repository
+//	-gorm       also generates a builder of GORM based repositories using typed data fetchers
+//	-output     name of the generated file, <type>_datarepo.go by default
+//
+// The generated file registers the field accessors of the struct with drreflect.Register, and defines a
+// <Type>Repository with GetBy<Field>, GetManyBy<Field> and GetListBy<Field> methods taking keys of the type of
+// the key fields.
+//
+// A codec that serializes the cached values without reflection is registered with drreflect.RegisterCodec too,
+// unless the struct has embedded fields, defines its own JSON encoding or has fields with the string or
+// omitzero JSON options. Fields of types other than strings, booleans and numbers, or pointers to them, are
+// serialized with encoding/json.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	typeName := flag.String("type", "", "name of the struct")
+	unique := flag.String("unique", "", "comma-separated key fields of the unique key caches")
+	nonUnique := flag.String("nonunique", "", "comma-separated key fields of the non-unique key caches")
+	withGorm := flag.Bool("gorm", false, "generates a builder of GORM based repositories")
+	output := flag.String("output", "", "name of the generated file")
+	flag.Parse()
+	if *typeName == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	options := options{
+		typeName:  *typeName,
+		unique:    splitFields(*unique),
+		nonUnique: splitFields(*nonUnique),
+		gorm:      *withGorm,
+	}
+	if err := run(".", options, *output); err != nil {
+		fmt.Fprintln(os.Stderr, "datarepo-gen:", err)
+		os.Exit(1)
+	}
+}
+
+// What to generate
+type options struct {
+	typeName  string
+	unique    []string
+	nonUnique []string
+	gorm      bool
+}
+
+func run(dir string, options options, output string) error {
+	e, err := parseEntity(dir, options.typeName)
+	if err != nil {
+		return err
+	}
+	src, err := generate(e, options)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		output = strings.ToLower(options.typeName) + "_datarepo.go"
+	}
+	return ioutil.WriteFile(filepath.Join(dir, output), src, 0644)
+}
+
+// An entity struct found in the source files of a package
+type entity struct {
+	packageName string
+	name        string
+	fields      []field
+	// whether the struct has embedded fields, whose fields are promoted in its JSON encoding
+	embedded bool
+	// whether the struct defines its own JSON or text encoding
+	customJSON bool
+	// imports of the file declaring the struct, by name
+	imports map[string]string
+}
+
+// A field of an entity that can be read without reflection
+type field struct {
+	name string
+	// the type as written in the source file
+	typeExpr ast.Expr
+	// the tag of the field without quotes, empty if it has none
+	tag string
+}
+
+func (e *entity) field(name string) (field, bool) {
+	for _, f := range e.fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// Finds the struct with the given name in the non-test Go files of the directory
+func parseEntity(dir, typeName string) (*entity, error) {
+	fset := token.NewFileSet()
+	filter := func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}
+	packages, err := parser.ParseDir(fset, dir, filter, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pkg := range packages {
+		for _, file := range pkg.Files {
+			spec := findTypeSpec(file, typeName)
+			if spec == nil {
+				continue
+			}
+			structType, ok := spec.Type.(*ast.StructType)
+			if !ok {
+				return nil, errors.New(typeName + " isn't a struct")
+			}
+			if spec.TypeParams != nil {
+				return nil, errors.New(typeName + " must not be generic")
+			}
+			return &entity{
+				packageName: pkg.Name,
+				name:        typeName,
+				fields:      exportedFields(structType),
+				embedded:    hasEmbeddedFields(structType),
+				customJSON:  hasCustomJSON(pkg, typeName),
+				imports:     fileImports(file),
+			}, nil
+		}
+	}
+	return nil, errors.New("struct " + typeName + " not found in " + dir)
+}
+
+func findTypeSpec(file *ast.File, typeName string) *ast.TypeSpec {
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			if typeSpec := spec.(*ast.TypeSpec); typeSpec.Name.Name == typeName {
+				return typeSpec
+			}
+		}
+	}
+	return nil
+}
+
+// returns the exported fields declared in the struct. Embedded fields are left out, so their fields are
+// read with reflection
+func exportedFields(structType *ast.StructType) []field {
+	var fields []field
+	for _, f := range structType.Fields.List {
+		var tag string
+		if f.Tag != nil {
+			tag, _ = strconv.Unquote(f.Tag.Value)
+		}
+		for _, name := range f.Names {
+			if name.IsExported() {
+				fields = append(fields, field{name: name.Name, typeExpr: f.Type, tag: tag})
+			}
+		}
+	}
+	return fields
+}
+
+func hasEmbeddedFields(structType *ast.StructType) bool {
+	for _, f := range structType.Fields.List {
+		if len(f.Names) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checks if the type or its pointer declare methods that replace their JSON encoding
+func hasCustomJSON(pkg *ast.Package, typeName string) bool {
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			funcDecl, ok := decl.(*ast.FuncDecl)
+			if !ok || funcDecl.Recv == nil || len(funcDecl.Recv.List) == 0 {
+				continue
+			}
+			switch funcDecl.Name.Name {
+			case "MarshalJSON", "UnmarshalJSON", "MarshalText", "UnmarshalText":
+			default:
+				continue
+			}
+			recv := funcDecl.Recv.List[0].Type
+			if star, ok := recv.(*ast.StarExpr); ok {
+				recv = star.X
+			}
+			if ident, ok := recv.(*ast.Ident); ok && ident.Name == typeName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func fileImports(file *ast.File) map[string]string {
+	imports := make(map[string]string)
+	for _, spec := range file.Imports {
+		path := strings.Trim(spec.Path.Value, `"`)
+		name := filepath.Base(path)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[name] = path
+	}
+	return imports
+}
+
+func splitFields(list string) []string {
+	var fields []string
+	for _, f := range strings.Split(list, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
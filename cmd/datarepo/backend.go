@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/go-redis/redis"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Time to live reported for entries without an expiration
+const noExpiration = time.Duration(-1)
+
+// Storage of the cache entries inspected by the CLI
+type backend interface {
+	// Returns the raw value of the key and its time to live. The second return value is false if the key
+	// doesn't exist
+	get(key string) ([]byte, time.Duration, bool, error)
+	// Returns the keys that start with the prefix, in ascending order
+	keys(prefix string) ([]string, error)
+	// Deletes the keys
+	delete(keys []string) error
+}
+
+type redisBackend struct {
+	client *redis.Client
+}
+
+func newRedisBackend(c *config) (*redisBackend, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     c.Redis.Addr,
+		Password: c.Redis.Password,
+		DB:       c.Redis.DB,
+	})
+	if err := client.Ping().Err(); err != nil {
+		return nil, err
+	}
+	return &redisBackend{client: client}, nil
+}
+
+func (b *redisBackend) get(key string) ([]byte, time.Duration, bool, error) {
+	value, err := b.client.Get(key).Bytes()
+	if err == redis.Nil {
+		return nil, 0, false, nil
+	}
+	if err != nil {
+		return nil, 0, false, err
+	}
+	ttl, err := b.client.TTL(key).Result()
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if ttl < 0 {
+		ttl = noExpiration
+	}
+	return value, ttl, true, nil
+}
+
+func (b *redisBackend) keys(prefix string) ([]string, error) {
+	var result []string
+	var cursor uint64
+	for {
+		keys, next, err := b.client.Scan(cursor, escapePattern(prefix)+"*", 1000).Result()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, keys...)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func (b *redisBackend) delete(keys []string) error {
+	for start := 0; start < len(keys); start += 1000 {
+		end := start + 1000
+		if end > len(keys) {
+			end = len(keys)
+		}
+		if err := b.client.Del(keys[start:end]...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// escapes the characters of a key prefix that have a special meaning in the patterns of SCAN
+func escapePattern(prefix string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+	return replacer.Replace(prefix)
+}
+
+// An entry of a snapshot file
+type snapshotEntry struct {
+	Value json.RawMessage `json:"value"`
+	// Time when the entry expires, nil if it doesn't expire
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// A local JSON file holding cache entries by key, as written by the snapshot command
+type fileBackend struct {
+	path    string
+	entries map[string]snapshotEntry
+}
+
+func newFileBackend(path string) (*fileBackend, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]snapshotEntry)
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, err
+	}
+	return &fileBackend{path: path, entries: entries}, nil
+}
+
+func (b *fileBackend) get(key string) ([]byte, time.Duration, bool, error) {
+	entry, ok := b.entries[key]
+	if !ok {
+		return nil, 0, false, nil
+	}
+	if entry.ExpiresAt == nil {
+		return entry.Value, noExpiration, true, nil
+	}
+	return entry.Value, time.Until(*entry.ExpiresAt), true, nil
+}
+
+func (b *fileBackend) keys(prefix string) ([]string, error) {
+	var result []string
+	for key := range b.entries {
+		if strings.HasPrefix(key, prefix) {
+			result = append(result, key)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func (b *fileBackend) delete(keys []string) error {
+	for _, key := range keys {
+		delete(b.entries, key)
+	}
+	return writeSnapshot(b.path, b.entries)
+}
+
+// Copies the keys that start with any of the prefixes from the backend into a snapshot file
+func snapshot(from backend, prefixes []string, path string) (int, error) {
+	entries := make(map[string]snapshotEntry)
+	for _, prefix := range prefixes {
+		keys, err := from.keys(prefix)
+		if err != nil {
+			return 0, err
+		}
+		for _, key := range keys {
+			value, ttl, found, err := from.get(key)
+			if err != nil {
+				return 0, err
+			}
+			if !found {
+				continue
+			}
+			entry := snapshotEntry{Value: value}
+			if ttl != noExpiration {
+				expiresAt := time.Now().Add(ttl)
+				entry.ExpiresAt = &expiresAt
+			}
+			entries[key] = entry
+		}
+	}
+	return len(entries), writeSnapshot(path, entries)
+}
+
+func writeSnapshot(path string, entries map[string]snapshotEntry) error {
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, os.FileMode(0644))
+}
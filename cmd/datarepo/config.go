@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+)
+
+// Configuration of the CLI, read from a JSON file
+type config struct {
+	Redis struct {
+		Addr     string `json:"addr"`
+		Password string `json:"password"`
+		DB       int    `json:"db"`
+	} `json:"redis"`
+	// Database used by the diff command
+	Database struct {
+		// Name of the database/sql driver, only "mysql" is linked into the CLI
+		Driver string `json:"driver"`
+		DSN    string `json:"dsn"`
+	} `json:"database"`
+	Entities []entityConfig `json:"entities"`
+}
+
+// An entity whose data is cached by a repository
+type entityConfig struct {
+	// Entity name, as registered in the datarepo.Registry of the application
+	Name string `json:"name"`
+	// Table where the entity is stored
+	Table string `json:"table"`
+	// Column of each field of the cached JSON documents, by JSON field name. Fields that aren't listed are
+	// matched with the column of the same name, ignoring case and underscores
+	Columns map[string]string `json:"columns"`
+	Caches  []cacheConfig     `json:"caches"`
+}
+
+// A cache of an entity, matching a Unique or Non-Unique Key cache definition of the repository
+type cacheConfig struct {
+	// Key prefix of the cache
+	Prefix string `json:"prefix"`
+	// Name of the key field, as in the cache definition
+	KeyField string `json:"keyField"`
+	// Column of the key field
+	KeyColumn string `json:"keyColumn"`
+	// Indicates that the cache holds a single element per key
+	Unique bool `json:"unique"`
+	// JSON name of the sub key field of non-unique caches, used to match cached and stored elements
+	SubKey string `json:"subKey"`
+	// Indicates that the cache is generational, so the keys of its entries include its current generation
+	Generational bool `json:"generational"`
+	// Key field of the unique cache of the entity holding the values of caches that only store their keys:
+	// the SubKeyFieldName of normalized non-unique caches, or the PrimaryKeyFieldName of secondary unique caches
+	MembersKeyField string `json:"membersKeyField"`
+}
+
+func loadConfig(path string) (*config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var c config
+	if err := json.NewDecoder(f).Decode(&c); err != nil {
+		return nil, errors.New("invalid configuration file " + path + ": " + err.Error())
+	}
+	for _, e := range c.Entities {
+		if e.Name == "" {
+			return nil, errors.New("every entity of the configuration must have a name")
+		}
+		for _, cache := range e.Caches {
+			if cache.Prefix == "" {
+				return nil, errors.New("every cache of entity " + e.Name + " must have a prefix")
+			}
+			if cache.MembersKeyField == "" {
+				continue
+			}
+			members, err := e.cache(cache.MembersKeyField)
+			if err != nil {
+				return nil, err
+			}
+			if !members.Unique || members.MembersKeyField != "" {
+				return nil, errors.New("the cache of field " + cache.MembersKeyField + " of entity " + e.Name +
+					" must be a unique cache that stores values")
+			}
+		}
+	}
+	return &c, nil
+}
+
+// Retrieves the entity with the given name
+func (c *config) entity(name string) (*entityConfig, error) {
+	for i := range c.Entities {
+		if c.Entities[i].Name == name {
+			return &c.Entities[i], nil
+		}
+	}
+	return nil, errors.New("entity not defined in the configuration: " + name)
+}
+
+// Returns the key prefixes of an entity, or the argument itself if it isn't the name of an entity
+func (c *config) prefixes(entityOrPrefix string) []string {
+	e, err := c.entity(entityOrPrefix)
+	if err != nil {
+		return []string{entityOrPrefix}
+	}
+	prefixes := make([]string, len(e.Caches))
+	for i, cache := range e.Caches {
+		prefixes[i] = cache.Prefix
+	}
+	return prefixes
+}
+
+// Retrieves the cache of the entity defined for the key field, or its first cache if no key field is given
+func (e *entityConfig) cache(keyField string) (*cacheConfig, error) {
+	if len(e.Caches) == 0 {
+		return nil, errors.New("no caches defined for entity: " + e.Name)
+	}
+	if keyField == "" {
+		return &e.Caches[0], nil
+	}
+	for i := range e.Caches {
+		if e.Caches[i].KeyField == keyField {
+			return &e.Caches[i], nil
+		}
+	}
+	return nil, errors.New("no cache defined for field " + keyField + " of entity " + e.Name)
+}
+
+// Returns the column of a JSON field of the entity among the given columns
+func (e *entityConfig) column(field string, columns []string) (string, bool) {
+	if column, ok := e.Columns[field]; ok {
+		return column, true
+	}
+	for _, column := range columns {
+		if normalizeName(column) == normalizeName(field) {
+			return column, true
+		}
+	}
+	return "", false
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.Replace(name, "_", "", -1))
+}
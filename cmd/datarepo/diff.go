@@ -0,0 +1,139 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/spf13/cast"
+	"sort"
+)
+
+// A field whose cached value differs from the stored value
+type fieldDiff struct {
+	field  string
+	cached interface{}
+	stored interface{}
+}
+
+// Loads the rows stored for the key of a cache as maps of column name to value
+func loadRows(db *sql.DB, e *entityConfig, cache *cacheConfig, id string) ([]map[string]interface{}, []string, error) {
+	if e.Table == "" || cache.KeyColumn == "" {
+		return nil, nil, errors.New("the table and key column must be configured to diff entity: " + e.Name)
+	}
+	rows, err := db.Query("SELECT * FROM "+e.Table+" WHERE "+cache.KeyColumn+" = ?", id)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+	var result []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, nil, err
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			if b, ok := values[i].([]byte); ok {
+				row[column] = string(b)
+			} else {
+				row[column] = values[i]
+			}
+		}
+		result = append(result, row)
+	}
+	return result, columns, rows.Err()
+}
+
+// Compares a cached document with the rows stored for its key.
+//
+// Values are compared by their string representation, as JSON documents and database rows don't
+// keep the same types
+func diffDocument(e *entityConfig, cache *cacheConfig, cached interface{}, rows []map[string]interface{}, columns []string) []fieldDiff {
+	if cache.Unique {
+		var row map[string]interface{}
+		if len(rows) > 0 {
+			row = rows[0]
+		}
+		document, _ := cached.(map[string]interface{})
+		return diffRow("", e, document, row, columns)
+	}
+
+	cachedElements := make(map[string]map[string]interface{})
+	if elements, ok := cached.([]interface{}); ok {
+		for _, element := range elements {
+			if document, ok := element.(map[string]interface{}); ok {
+				cachedElements[cast.ToString(document[cache.SubKey])] = document
+			}
+		}
+	}
+	storedElements := make(map[string]map[string]interface{})
+	subKeyColumn, _ := e.column(cache.SubKey, columns)
+	for _, row := range rows {
+		storedElements[cast.ToString(row[subKeyColumn])] = row
+	}
+
+	subKeys := make([]string, 0, len(cachedElements)+len(storedElements))
+	for subKey := range cachedElements {
+		subKeys = append(subKeys, subKey)
+	}
+	for subKey := range storedElements {
+		if _, ok := cachedElements[subKey]; !ok {
+			subKeys = append(subKeys, subKey)
+		}
+	}
+	sort.Strings(subKeys)
+
+	var diffs []fieldDiff
+	for _, subKey := range subKeys {
+		diffs = append(diffs, diffRow("["+subKey+"].", e, cachedElements[subKey], storedElements[subKey], columns)...)
+	}
+	return diffs
+}
+
+func diffRow(prefix string, e *entityConfig, document, row map[string]interface{}, columns []string) []fieldDiff {
+	if document == nil || row == nil {
+		if document == nil && row == nil {
+			return nil
+		}
+		return []fieldDiff{{field: prefix + "*", cached: nilIfEmpty(document), stored: nilIfEmpty(row)}}
+	}
+
+	fields := make([]string, 0, len(document))
+	for field := range document {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var diffs []fieldDiff
+	for _, field := range fields {
+		column, ok := e.column(field, columns)
+		if !ok {
+			continue
+		}
+		cached, stored := document[field], row[column]
+		if cast.ToString(cached) != cast.ToString(stored) {
+			diffs = append(diffs, fieldDiff{field: prefix + field, cached: cached, stored: stored})
+		}
+	}
+	return diffs
+}
+
+func nilIfEmpty(m map[string]interface{}) interface{} {
+	if m == nil {
+		return nil
+	}
+	return m
+}
+
+func (d fieldDiff) String() string {
+	return fmt.Sprintf("%s: cached=%v stored=%v", d.field, d.cached, d.stored)
+}
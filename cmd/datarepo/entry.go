@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/internal/codec"
+	"github.com/spf13/cast"
+	"time"
+)
+
+// The cache entry of an id, with the values it refers to resolved for the caches that only store keys
+type cacheEntry struct {
+	// Key of the entry in the backend
+	key      string
+	found    bool
+	ttl      time.Duration
+	storedAt time.Time
+	// Decoded value of the entry, the keys of the values for the caches that only store keys
+	stored interface{}
+	// Value of the entry with its keys resolved through the members cache, the same as stored for the
+	// caches that store values. The elements of normalized caches whose values aren't cached only have
+	// their sub key, and the value of secondary unique caches is nil if it isn't cached
+	value interface{}
+	// Keys of the members cache whose values aren't cached
+	missing []string
+}
+
+// Reads the entry of the id in the cache, building its key like the cache handlers do, and resolves the keys
+// stored by normalized and secondary unique caches through the cache of their members
+func readCacheEntry(b backend, e *entityConfig, cache *cacheConfig, id string) (*cacheEntry, error) {
+	prefix, err := entryKeyPrefix(b, cache)
+	if err != nil {
+		return nil, err
+	}
+	entry := &cacheEntry{key: datarepo.EntryKey(prefix, id)}
+	entry.stored, entry.storedAt, entry.ttl, entry.found, err = readEntry(b, entry.key)
+	if err != nil || !entry.found {
+		return entry, err
+	}
+	entry.value = entry.stored
+	if cache.MembersKeyField == "" {
+		return entry, nil
+	}
+
+	members, err := e.cache(cache.MembersKeyField)
+	if err != nil {
+		return nil, err
+	}
+	membersPrefix, err := entryKeyPrefix(b, members)
+	if err != nil {
+		return nil, err
+	}
+	resolve := func(memberKey interface{}) (interface{}, bool, error) {
+		value, _, _, found, err := readEntry(b, datarepo.EntryKey(membersPrefix, memberKey))
+		if err == nil && !found {
+			entry.missing = append(entry.missing, cast.ToString(memberKey))
+		}
+		return value, found, err
+	}
+
+	if cache.Unique {
+		entry.value, _, err = resolve(entry.stored)
+		return entry, err
+	}
+	subKeys, _ := entry.stored.([]interface{})
+	values := make([]interface{}, 0, len(subKeys))
+	for _, subKey := range subKeys {
+		value, found, err := resolve(subKey)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			// only the membership of the value can be verified
+			value = map[string]interface{}{cache.SubKey: subKey}
+		}
+		values = append(values, value)
+	}
+	entry.value = values
+	return entry, nil
+}
+
+// Returns the prefix of the keys of the entries of the cache, which includes the current generation of
+// generational caches
+func entryKeyPrefix(b backend, cache *cacheConfig) (string, error) {
+	if !cache.Generational {
+		return cache.Prefix, nil
+	}
+	key := datarepo.GenerationKey(cache.Prefix)
+	raw, _, found, err := b.get(key)
+	if err != nil {
+		return "", err
+	}
+	var generation int64
+	if found {
+		if _, err := codec.Unmarshal(raw, &generation); err != nil {
+			return "", errors.New("the generation of " + key + " can't be decoded: " + err.Error())
+		}
+	}
+	return datarepo.GenerationalKeyPrefix(cache.Prefix, generation), nil
+}
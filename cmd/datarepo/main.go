@@ -0,0 +1,283 @@
+// Command datarepo inspects and purges the caches of the repositories described in a configuration file,
+// working against Redis or against a local snapshot file.
+//
+// Usage:
+//
+//	datarepo [-config datarepo.json] [-snapshot file] <command> [arguments]
+//
+// Commands:
+//
+//	get [-key field] <entity> <id>       prints the decoded cache entry of the id, its TTL and when it was stored
+//	keys <entity|prefix>                 lists the cached keys with their TTLs
+//	purge [-yes] <entity|prefix>         deletes the cached keys, only counting them unless -yes is provided
+//	diff [-key field] <entity> <id>      compares the cache entry of the id with the rows in the database
+//	snapshot <file>                      copies the keys of every configured entity into a snapshot file
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/merlinapp/datarepo-go/internal/codec"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+func main() {
+	configPath := flag.String("config", "datarepo.json", "path of the configuration file")
+	snapshotPath := flag.String("snapshot", "", "path of a snapshot file to use instead of Redis")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	if err := run(*configPath, *snapshotPath, flag.Arg(0), flag.Args()[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "datarepo:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: datarepo [-config file] [-snapshot file] get|keys|purge|diff|snapshot [arguments]")
+	flag.PrintDefaults()
+}
+
+func run(configPath, snapshotPath, command string, args []string, out io.Writer) error {
+	c, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	var b backend
+	if snapshotPath != "" {
+		b, err = newFileBackend(snapshotPath)
+	} else {
+		b, err = newRedisBackend(c)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "get":
+		return getCommand(c, b, args, out)
+	case "keys":
+		return keysCommand(c, b, args, out)
+	case "purge":
+		return purgeCommand(c, b, args, out)
+	case "diff":
+		return diffCommand(c, b, args, out)
+	case "snapshot":
+		return snapshotCommand(c, b, args, out)
+	default:
+		return errors.New("unknown command: " + command)
+	}
+}
+
+func getCommand(c *config, b backend, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("get", flag.ExitOnError)
+	keyField := flags.String("key", "", "key field of the cache, the first cache of the entity by default")
+	_ = flags.Parse(args)
+	if flags.NArg() != 2 {
+		return errors.New("usage: get [-key field] <entity> <id>")
+	}
+
+	e, cache, err := entityCache(c, flags.Arg(0), *keyField)
+	if err != nil {
+		return err
+	}
+	entry, err := readCacheEntry(b, e, cache, flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	if !entry.found {
+		fmt.Fprintln(out, entry.key, "is not cached")
+		return nil
+	}
+
+	fmt.Fprintln(out, "key:      ", entry.key)
+	fmt.Fprintln(out, "ttl:      ", formatTTL(entry.ttl))
+	if !entry.storedAt.IsZero() {
+		fmt.Fprintln(out, "stored at:", entry.storedAt.Format(time.RFC3339))
+	}
+	if cache.MembersKeyField != "" {
+		stored, err := json.Marshal(entry.stored)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "stored:   ", string(stored))
+		if len(entry.missing) > 0 {
+			fmt.Fprintln(out, "not cached in", cache.MembersKeyField+":", strings.Join(entry.missing, ", "))
+		}
+	}
+	pretty, err := json.MarshalIndent(entry.value, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, string(pretty))
+	return nil
+}
+
+func keysCommand(c *config, b backend, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: keys <entity|prefix>")
+	}
+	for _, prefix := range c.prefixes(args[0]) {
+		keys, err := b.keys(prefix)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			_, ttl, found, err := b.get(key)
+			if err != nil {
+				return err
+			}
+			if found {
+				fmt.Fprintf(out, "%s\t%s\n", key, formatTTL(ttl))
+			}
+		}
+	}
+	return nil
+}
+
+func purgeCommand(c *config, b backend, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("purge", flag.ExitOnError)
+	confirmed := flags.Bool("yes", false, "deletes the keys instead of only counting them")
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("usage: purge [-yes] <entity|prefix>")
+	}
+
+	var keys []string
+	for _, prefix := range c.prefixes(flags.Arg(0)) {
+		if prefix == "" {
+			return errors.New("refusing to purge every key of the cache store")
+		}
+		prefixKeys, err := b.keys(prefix)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, prefixKeys...)
+	}
+	if !*confirmed {
+		fmt.Fprintln(out, len(keys), "keys would be deleted, run again with -yes to delete them")
+		return nil
+	}
+	if err := b.delete(keys); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, len(keys), "keys deleted")
+	return nil
+}
+
+func diffCommand(c *config, b backend, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	keyField := flags.String("key", "", "key field of the cache, the first cache of the entity by default")
+	_ = flags.Parse(args)
+	if flags.NArg() != 2 {
+		return errors.New("usage: diff [-key field] <entity> <id>")
+	}
+	if c.Database.DSN == "" {
+		return errors.New("a database must be configured to diff entities")
+	}
+
+	e, cache, err := entityCache(c, flags.Arg(0), *keyField)
+	if err != nil {
+		return err
+	}
+	entry, err := readCacheEntry(b, e, cache, flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	if !entry.found {
+		fmt.Fprintln(out, entry.key, "is not cached")
+		return nil
+	}
+	if cache.Unique && len(entry.missing) > 0 {
+		fmt.Fprintln(out, entry.key, "refers to", entry.missing[0], "whose value is not cached in", cache.MembersKeyField)
+		return nil
+	}
+
+	driver := c.Database.Driver
+	if driver == "" {
+		driver = "mysql"
+	}
+	db, err := sql.Open(driver, c.Database.DSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	rows, columns, err := loadRows(db, e, cache, flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	diffs := diffDocument(e, cache, entry.value, rows, columns)
+	if len(diffs) == 0 {
+		fmt.Fprintln(out, entry.key, "matches the database")
+		return nil
+	}
+	fmt.Fprintln(out, entry.key, "differs from the database:")
+	for _, d := range diffs {
+		fmt.Fprintln(out, "  "+d.String())
+	}
+	return nil
+}
+
+func snapshotCommand(c *config, b backend, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: snapshot <file>")
+	}
+	var prefixes []string
+	for _, e := range c.Entities {
+		prefixes = append(prefixes, c.prefixes(e.Name)...)
+	}
+	count, err := snapshot(b, prefixes, args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, count, "keys written to", args[0])
+	return nil
+}
+
+func entityCache(c *config, entityName, keyField string) (*entityConfig, *cacheConfig, error) {
+	e, err := c.entity(entityName)
+	if err != nil {
+		return nil, nil, err
+	}
+	cache, err := e.cache(keyField)
+	if err != nil {
+		return nil, nil, err
+	}
+	return e, cache, nil
+}
+
+// reads and decodes a cache entry, returning its value, when it was stored and its time to live
+func readEntry(b backend, key string) (interface{}, time.Time, time.Duration, bool, error) {
+	raw, ttl, found, err := b.get(key)
+	if err != nil || !found {
+		return nil, time.Time{}, 0, false, err
+	}
+	var value interface{}
+	storedAt, err := codec.Unmarshal(raw, &value)
+	if err != nil {
+		return nil, time.Time{}, 0, false, errors.New("the entry of " + key + " can't be decoded: " + err.Error())
+	}
+	return value, storedAt, ttl, true, nil
+}
+
+func formatTTL(ttl time.Duration) string {
+	if ttl == noExpiration {
+		return "no expiration"
+	}
+	if ttl <= 0 {
+		return "expired"
+	}
+	return ttl.Truncate(time.Second).String()
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/internal/codec"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type book struct {
+	ID       string `json:"id"`
+	AuthorID string `json:"authorId"`
+	ISBN     string `json:"isbn"`
+	Title    string `json:"title"`
+}
+
+const testConfig = `{
+  "entities": [{
+    "name": "Book",
+    "table": "books",
+    "caches": [
+      {"prefix": "b:", "keyField": "ID", "keyColumn": "id", "unique": true, "generational": true},
+      {"prefix": "na:", "keyField": "AuthorID", "keyColumn": "author_id", "subKey": "id", "membersKeyField": "ID"},
+      {"prefix": "i:", "keyField": "ISBN", "keyColumn": "isbn", "unique": true, "membersKeyField": "ID"}
+    ]
+  }]
+}`
+
+var (
+	book1 = book{ID: "1", AuthorID: "a1", ISBN: "isbn-1", Title: "Book 1"}
+	book2 = book{ID: "2", AuthorID: "a1", ISBN: "isbn-2", Title: "Book 2"}
+)
+
+// Writes the configuration and a snapshot holding the entries, encoded like the cache stores do
+func setup(t *testing.T, entries map[string]interface{}) (string, string) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(configPath, []byte(testConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	snapshotEntries := make(map[string]snapshotEntry)
+	for key, value := range entries {
+		raw, err := codec.Marshal(value, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		snapshotEntries[key] = snapshotEntry{Value: raw}
+	}
+	snapshotPath := filepath.Join(dir, "snapshot.json")
+	if err := writeSnapshot(snapshotPath, snapshotEntries); err != nil {
+		t.Fatal(err)
+	}
+	return configPath, snapshotPath
+}
+
+func defaultEntries() map[string]interface{} {
+	return map[string]interface{}{
+		datarepo.GenerationKey("b:"): int64(3),
+		"b:g3:1":                     book1,
+		"b:g3:2":                     book2,
+		"b:g2:3":                     book{ID: "3", AuthorID: "a2", Title: "Book 3"},
+		"na:a1":                      []string{"1", "2"},
+		"i:isbn-1":                   "1",
+		"i:isbn-2":                   "2",
+	}
+}
+
+func readTestEntry(t *testing.T, entries map[string]interface{}, keyField, id string) (*entityConfig, *cacheConfig, *cacheEntry) {
+	configPath, snapshotPath := setup(t, entries)
+	c, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := newFileBackend(snapshotPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, cache, err := entityCache(c, "Book", keyField)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := readCacheEntry(b, e, cache, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e, cache, entry
+}
+
+// Returns a database row of the book, with its values as the database driver returns them
+func row(b book) map[string]interface{} {
+	return map[string]interface{}{"id": []byte(b.ID), "author_id": b.AuthorID, "isbn": b.ISBN, "title": b.Title}
+}
+
+var columns = []string{"id", "author_id", "isbn", "title"}
+
+func TestGetUsesCurrentGeneration(t *testing.T) {
+	configPath, snapshotPath := setup(t, defaultEntries())
+	var out bytes.Buffer
+	if err := run(configPath, snapshotPath, "get", []string{"Book", "1"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "b:g3:1") || !strings.Contains(out.String(), `"title": "Book 1"`) {
+		t.Errorf("the entry of the current generation isn't printed:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := run(configPath, snapshotPath, "get", []string{"Book", "3"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "b:g3:3 is not cached") {
+		t.Errorf("the entry of a previous generation is used:\n%s", out.String())
+	}
+}
+
+func TestGetWithoutGenerationUsesFirstGeneration(t *testing.T) {
+	entries := map[string]interface{}{"b:g0:1": book1}
+	_, _, entry := readTestEntry(t, entries, "ID", "1")
+	if !entry.found || entry.key != "b:g0:1" {
+		t.Errorf("the entry of generation 0 isn't read: %+v", entry)
+	}
+}
+
+func TestGetResolvesNormalizedEntries(t *testing.T) {
+	configPath, snapshotPath := setup(t, defaultEntries())
+	var out bytes.Buffer
+	if err := run(configPath, snapshotPath, "get", []string{"-key", "AuthorID", "Book", "a1"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`stored:    ["1","2"]`, `"title": "Book 1"`, `"title": "Book 2"`} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("%s isn't printed:\n%s", expected, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "not cached in") {
+		t.Errorf("cached members are reported as missing:\n%s", out.String())
+	}
+}
+
+func TestGetReportsMissingMembers(t *testing.T) {
+	entries := defaultEntries()
+	delete(entries, "b:g3:1")
+	configPath, snapshotPath := setup(t, entries)
+	var out bytes.Buffer
+	if err := run(configPath, snapshotPath, "get", []string{"-key", "AuthorID", "Book", "a1"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "not cached in ID: 1") {
+		t.Errorf("the missing member isn't reported:\n%s", out.String())
+	}
+}
+
+func TestDiffOfNormalizedEntryComparesResolvedValues(t *testing.T) {
+	e, cache, entry := readTestEntry(t, defaultEntries(), "AuthorID", "a1")
+	if diffs := diffDocument(e, cache, entry.value, []map[string]interface{}{row(book1), row(book2)}, columns); len(diffs) > 0 {
+		t.Errorf("unexpected diffs of an up to date entry: %v", diffs)
+	}
+
+	changed := book2
+	changed.Title = "Book 2, 2nd edition"
+	diffs := diffDocument(e, cache, entry.value, []map[string]interface{}{row(book1), row(changed)}, columns)
+	if len(diffs) != 1 || diffs[0].field != "[2].title" {
+		t.Errorf("the changed title isn't reported: %v", diffs)
+	}
+}
+
+func TestDiffOfNormalizedEntryWithMissingMemberComparesMembership(t *testing.T) {
+	entries := defaultEntries()
+	delete(entries, "b:g3:1")
+	e, cache, entry := readTestEntry(t, entries, "AuthorID", "a1")
+	if diffs := diffDocument(e, cache, entry.value, []map[string]interface{}{row(book1), row(book2)}, columns); len(diffs) > 0 {
+		t.Errorf("unexpected diffs of a member that isn't cached: %v", diffs)
+	}
+
+	diffs := diffDocument(e, cache, entry.value, []map[string]interface{}{row(book2)}, columns)
+	if len(diffs) != 1 || diffs[0].field != "[1].*" {
+		t.Errorf("the removed member isn't reported: %v", diffs)
+	}
+}
+
+func TestDiffOfIndirectEntryComparesResolvedValue(t *testing.T) {
+	e, cache, entry := readTestEntry(t, defaultEntries(), "ISBN", "isbn-1")
+	if len(entry.missing) > 0 {
+		t.Fatalf("the member of the entry isn't resolved: %+v", entry)
+	}
+	if diffs := diffDocument(e, cache, entry.value, []map[string]interface{}{row(book1)}, columns); len(diffs) > 0 {
+		t.Errorf("unexpected diffs of an up to date entry: %v", diffs)
+	}
+}
+
+func TestIndirectEntryWithMissingMember(t *testing.T) {
+	entries := defaultEntries()
+	delete(entries, "b:g3:2")
+	_, _, entry := readTestEntry(t, entries, "ISBN", "isbn-2")
+	if !entry.found || entry.value != nil || len(entry.missing) != 1 || entry.missing[0] != "2" {
+		t.Errorf("the missing member isn't reported: %+v", entry)
+	}
+}
+
+func TestConfigRejectsMembersCacheThatIsNotUnique(t *testing.T) {
+	var c config
+	if err := json.Unmarshal([]byte(testConfig), &c); err != nil {
+		t.Fatal(err)
+	}
+	c.Entities[0].Caches[2].MembersKeyField = "AuthorID"
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(path); err == nil {
+		t.Error("a configuration whose members cache isn't unique was loaded")
+	}
+}
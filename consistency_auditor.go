@@ -0,0 +1,323 @@
+package datarepo
+
+import (
+	"context"
+	"errors"
+	"github.com/spf13/cast"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Options of a consistency audit
+type AuditOptions struct {
+	// Keys to audit. If empty, SampleSize keys are chosen at random from the DataFetcher, which must
+	// implement the KeySampler interface
+	Keys []interface{}
+	// Number of keys sampled when no Keys are provided. Defaults to 100
+	SampleSize int
+	// Indicates that the cache entries that don't match the data in the repository must be refreshed
+	Repair bool
+}
+
+// Difference found between a cached value and the value stored in the repository
+type FieldDiff struct {
+	// Name of the field that differs. For caches that hold several elements per key, the field name is
+	// preceded by the sub key of the element, for example, "[42].Status".
+	//
+	// The name is empty, or only holds the sub key, if the whole value is missing on one side
+	Field string
+	// Value of the field in the cache, nil if missing
+	Cached interface{}
+	// Value of the field in the repository, nil if missing
+	Stored interface{}
+}
+
+// Differences found for a cached key
+type KeyDiff struct {
+	Key    interface{}
+	Fields []FieldDiff
+}
+
+// Result of auditing a cache of a repository
+type AuditReport struct {
+	Entity       string
+	KeyFieldName string
+	// Number of keys audited
+	Checked int
+	// Number of audited keys that weren't cached, which aren't considered drift
+	NotCached int
+	// Number of cached keys whose value doesn't match the data in the repository
+	Mismatches int
+	// Number of mismatched keys that were refreshed
+	Repaired int
+	// Differences found per mismatched key
+	Diffs []KeyDiff
+}
+
+// Drift counts accumulated by a ConsistencyAuditor for an entity
+type DriftStats struct {
+	// number of keys audited
+	Checked int64
+	// number of audited keys that weren't cached
+	NotCached int64
+	// number of cached keys that didn't match the data in the repository
+	Mismatches int64
+	// number of mismatched keys that were refreshed
+	Repaired int64
+}
+
+// A ConsistencyAuditor measures how stale the caches of the repositories registered in a Registry are,
+// reading a set of keys from the CacheStore and from the DataFetcher side by side and reporting the
+// fields that differ.
+//
+// A ConsistencyAuditor is safe for concurrent use
+type ConsistencyAuditor struct {
+	registry *Registry
+	mu       sync.Mutex
+	stats    map[string]DriftStats
+}
+
+// Creates a new ConsistencyAuditor for the repositories of the registry
+func NewConsistencyAuditor(registry *Registry) *ConsistencyAuditor {
+	return &ConsistencyAuditor{
+		registry: registry,
+		stats:    make(map[string]DriftStats),
+	}
+}
+
+// Audits the cache defined for the keyFieldName in the repository registered with the entity name
+func (a *ConsistencyAuditor) Audit(ctx context.Context, entityName, keyFieldName string, options AuditOptions) (AuditReport, error) {
+	report := AuditReport{Entity: entityName, KeyFieldName: keyFieldName}
+	repo, ok := a.registry.Repository(entityName)
+	if !ok {
+		return report, errors.New("no repository has been registered for: " + entityName)
+	}
+	rc, ok := repo.(repositoryComponents)
+	if !ok {
+		return report, errors.New("the caches of the repository can't be audited for: " + entityName)
+	}
+	cache, ok := rc.registeredCaches()[keyFieldName]
+	if !ok {
+		return report, unknownKeyFieldError(keyFieldName)
+	}
+
+	keys, err := auditKeys(ctx, cache, keyFieldName, options)
+	if err != nil {
+		return report, err
+	}
+	if len(keys) == 0 {
+		return report, nil
+	}
+	cached, err := cache.GetMulti(ctx, keys, ReadOptions{CacheOnly: true})
+	if err != nil {
+		return report, err
+	}
+	stored, err := cache.DataFetcher.FindByKeys(ctx, keyFieldName, keys)
+	if err != nil {
+		return report, err
+	}
+
+	var mismatched []interface{}
+	report.Checked = len(keys)
+	for i, key := range keys {
+		if cached[i].IsEmpty() {
+			report.NotCached++
+			continue
+		}
+		var storedValue interface{}
+		if !stored[i].IsEmpty() {
+			storedValue = stored[i].StoredValue()
+		}
+		diffs := diffEntries(cache.Handler, cached[i].StoredValue(), storedValue)
+		if len(diffs) > 0 {
+			report.Diffs = append(report.Diffs, KeyDiff{Key: key, Fields: diffs})
+			mismatched = append(mismatched, key)
+		}
+	}
+	report.Mismatches = len(mismatched)
+
+	if options.Repair && len(mismatched) > 0 {
+		_, err = cache.Refresh(ctx, mismatched)
+		if err == nil {
+			report.Repaired = len(mismatched)
+		}
+	}
+	a.addStats(entityName, report)
+	return report, err
+}
+
+// Audits every cache of every registered repository.
+//
+// Every cache is audited even if some of them fail, in which case a *RegistryError is returned with the
+// first error found per entity
+func (a *ConsistencyAuditor) AuditAll(ctx context.Context, options AuditOptions) ([]AuditReport, error) {
+	var reports []AuditReport
+	errs := make(map[string]error)
+	for _, entityName := range a.registry.EntityNames() {
+		repo, _ := a.registry.Repository(entityName)
+		rc, ok := repo.(repositoryComponents)
+		if !ok {
+			continue
+		}
+		keyFieldNames := make([]string, 0, len(rc.registeredCaches()))
+		for keyFieldName := range rc.registeredCaches() {
+			keyFieldNames = append(keyFieldNames, keyFieldName)
+		}
+		sort.Strings(keyFieldNames)
+		for _, keyFieldName := range keyFieldNames {
+			report, err := a.Audit(ctx, entityName, keyFieldName, options)
+			if err != nil && errs[entityName] == nil {
+				errs[entityName] = err
+			}
+			reports = append(reports, report)
+		}
+	}
+	if len(errs) > 0 {
+		return reports, &RegistryError{Errors: errs}
+	}
+	return reports, nil
+}
+
+// Returns the drift counts accumulated by the audits performed so far, by entity name
+func (a *ConsistencyAuditor) Stats() map[string]DriftStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	result := make(map[string]DriftStats, len(a.stats))
+	for name, s := range a.stats {
+		result[name] = s
+	}
+	return result
+}
+
+// Resets the drift counts accumulated so far
+func (a *ConsistencyAuditor) ClearStats() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stats = make(map[string]DriftStats)
+}
+
+func (a *ConsistencyAuditor) addStats(entityName string, report AuditReport) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s := a.stats[entityName]
+	s.Checked += int64(report.Checked)
+	s.NotCached += int64(report.NotCached)
+	s.Mismatches += int64(report.Mismatches)
+	s.Repaired += int64(report.Repaired)
+	a.stats[entityName] = s
+}
+
+// returns the keys provided in the options or a random sample of the keys of the repository
+func auditKeys(ctx context.Context, cache Cache, keyFieldName string, options AuditOptions) ([]interface{}, error) {
+	if len(options.Keys) > 0 {
+		return options.Keys, nil
+	}
+	sampler, ok := cache.DataFetcher.(KeySampler)
+	if !ok {
+		return nil, errors.New("the data fetcher can't sample keys for: " + keyFieldName)
+	}
+	sampleSize := options.SampleSize
+	if sampleSize <= 0 {
+		sampleSize = 100
+	}
+	return sampler.SampleKeys(ctx, keyFieldName, sampleSize)
+}
+
+// returns the differences between the cached and the stored values of a cache entry
+func diffEntries(handler Handler, cached, stored interface{}) []FieldDiff {
+	if handler.SingleResultPerKey() {
+		if stored == nil {
+			return []FieldDiff{{Cached: cached}}
+		}
+		return diffStructs("", cached, stored)
+	}
+
+	cachedElements := elementsBySubKey(handler, cached)
+	storedElements := elementsBySubKey(handler, stored)
+	subKeys := make([]string, 0, len(cachedElements)+len(storedElements))
+	for subKey := range cachedElements {
+		subKeys = append(subKeys, subKey)
+	}
+	for subKey := range storedElements {
+		if _, ok := cachedElements[subKey]; !ok {
+			subKeys = append(subKeys, subKey)
+		}
+	}
+	sort.Strings(subKeys)
+
+	var diffs []FieldDiff
+	for _, subKey := range subKeys {
+		prefix := "[" + subKey + "]"
+		c, inCache := cachedElements[subKey]
+		s, inStore := storedElements[subKey]
+		if inCache && inStore {
+			diffs = append(diffs, diffStructs(prefix+".", c, s)...)
+		} else {
+			diffs = append(diffs, FieldDiff{Field: prefix, Cached: c, Stored: s})
+		}
+	}
+	return diffs
+}
+
+// returns the elements of a slice, or of a pointer to a slice, by the string value of their sub key
+func elementsBySubKey(handler Handler, values interface{}) map[string]interface{} {
+	result := make(map[string]interface{})
+	v := reflect.ValueOf(values)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice {
+		return result
+	}
+	for i := 0; i < v.Len(); i++ {
+		element := v.Index(i).Interface()
+		subKey, _ := handler.ValueSubKey(element)
+		result[cast.ToString(subKey)] = element
+	}
+	return result
+}
+
+// returns the exported fields whose values differ between two structs, or pointers to structs, of the same type
+func diffStructs(prefix string, cached, stored interface{}) []FieldDiff {
+	cv, sv := reflect.Indirect(reflect.ValueOf(cached)), reflect.Indirect(reflect.ValueOf(stored))
+	if cv.Kind() != reflect.Struct || sv.Kind() != reflect.Struct || cv.Type() != sv.Type() {
+		if reflect.DeepEqual(cached, stored) {
+			return nil
+		}
+		return []FieldDiff{{Field: prefix, Cached: cached, Stored: stored}}
+	}
+
+	var diffs []FieldDiff
+	for i := 0; i < cv.NumField(); i++ {
+		field := cv.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		c, s := cv.Field(i).Interface(), sv.Field(i).Interface()
+		if !equalFieldValues(c, s) {
+			diffs = append(diffs, FieldDiff{Field: prefix + field.Name, Cached: c, Stored: s})
+		}
+	}
+	return diffs
+}
+
+// compares two field values, treating times that represent the same instant as equal, as cache stores
+// don't always keep the location of the times they store
+func equalFieldValues(a, b interface{}) bool {
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			return ta.Equal(tb)
+		}
+	}
+	if ta, ok := a.(*time.Time); ok {
+		if tb, ok := b.(*time.Time); ok {
+			if ta == nil || tb == nil {
+				return ta == tb
+			}
+			return ta.Equal(*tb)
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,66 @@
+package datarepo
+
+import (
+	"context"
+	"github.com/merlinapp/datarepo-go/drreflect"
+	"log"
+)
+
+// implemented by the cache handlers that notify the results they fill into their cache
+type fillingHandler interface {
+	setFillListener(listener fillListener)
+}
+
+// receives the non-empty results stored in a cache by read-through fills and refreshes
+type fillListener func(ctx context.Context, results []Result)
+
+func (c *baseCacheHandler) setFillListener(listener fillListener) {
+	c.fillListener = listener
+}
+
+// notifies the results filled into the cache, if there is a listener
+func (c *baseCacheHandler) filled(ctx context.Context, results []Result) {
+	if c.fillListener != nil && len(results) > 0 {
+		c.fillListener(ctx, results)
+	}
+}
+
+// implemented by the cache handlers that can store the values filled into another cache. Only the entries
+// that aren't cached are stored, so that values written since the fill read them aren't overwritten
+type missingEntryHandler interface {
+	setMissing(ctx context.Context, cacheStore CacheStore, value interface{}) error
+}
+
+// stores the values of the results filled into the cache defined for the source key field in the rest of the
+// caches of the repository. Unique key caches store the values whose entries aren't cached, while non-unique
+// key caches only add the values missing from the lists they already have cached.
+//
+// The cache the source cache reads its values from is skipped too, as the source cache already stored the
+// values in it
+func (r *readOnlyCachedRepository) crossPopulate(ctx context.Context, source string, members string, results []Result) {
+	sourceCache := r.caches[source]
+	var values []interface{}
+	for _, result := range results {
+		value := result.StoredValue()
+		if sourceCache.Handler.SingleResultPerKey() {
+			values = append(values, value)
+		} else {
+			values = append(values, drreflect.NewReflectSliceTypeHandlerFromValue(value).AsInterfaceSlice(value)...)
+		}
+	}
+
+	for keyFieldName, cache := range r.caches {
+		if keyFieldName == source || keyFieldName == members {
+			continue
+		}
+		h, ok := cache.Handler.(missingEntryHandler)
+		if !ok {
+			continue
+		}
+		for _, value := range values {
+			if err := h.setMissing(ctx, cache.Store, value); err != nil {
+				log.Println("Error cross-populating the cache for key field: ", keyFieldName, "-", err)
+			}
+		}
+	}
+}
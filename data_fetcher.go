@@ -8,3 +8,22 @@ type DataFetcher interface {
 	FindByKey(ctx context.Context, keyFieldName string, id interface{}) (Result, error)
 	FindByKeys(ctx context.Context, keyFieldName string, ids []interface{}) ([]Result, error)
 }
+
+// A KeyLister lists the distinct values stored in the repository for a given key field.
+//
+// DataFetchers can optionally implement this interface to allow caches to be warmed up with
+// all the data in the repository (see ReadOnlyCachedRepository.WarmAll)
+type KeyLister interface {
+	// Returns up to limit distinct values of the keyFieldName, in ascending order, that are greater than
+	// the after value. If after is nil, then the values are listed from the beginning.
+	ListKeys(ctx context.Context, keyFieldName string, after interface{}, limit int) ([]interface{}, error)
+}
+
+// A KeySampler returns a random sample of the values stored in the repository for a given key field.
+//
+// DataFetchers can optionally implement this interface to allow the consistency of caches to be audited
+// without providing the keys to check (see ConsistencyAuditor)
+type KeySampler interface {
+	// Returns up to n distinct values of the keyFieldName chosen at random
+	SampleKeys(ctx context.Context, keyFieldName string, n int) ([]interface{}, error)
+}
@@ -0,0 +1,131 @@
+package drreflect
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+)
+
+// Functions that encode a value of a struct type S to JSON and decode it from JSON without reflection. They
+// must produce and accept the same JSON as encoding/json, so that values cached with and without them can be
+// read interchangeably
+type Codec[S any] struct {
+	// Appends the JSON encoding of the value to the buffer
+	Append func(buf []byte, v *S) ([]byte, error)
+	// Decodes the JSON encoding of a value into v
+	Decode func(data []byte, v *S) error
+}
+
+var (
+	// functions encoding values of the types derived from the types with a registered codec, by type
+	typedEncoders sync.Map
+	// functions decoding into pointers of the types derived from the types with a registered codec, by type
+	typedDecoders sync.Map
+)
+
+// Registers the codec of the struct type S, which is then used by EncodeJSON and DecodeJSON for values of
+// type *S, []*S and *[]*S.
+//
+// The cache stores of this library serialize the cached values with EncodeJSON and DecodeJSON. RegisterCodec
+// is meant to be called from the init function of code generated by the datarepo-gen command
+func RegisterCodec[S any](codec Codec[S]) {
+	encodeSlice := func(values []*S) ([]byte, error) {
+		if values == nil {
+			return []byte("null"), nil
+		}
+		buf := append(make([]byte, 0, 64*len(values)+2), '[')
+		for i, value := range values {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			if value == nil {
+				buf = append(buf, "null"...)
+				continue
+			}
+			var err error
+			if buf, err = codec.Append(buf, value); err != nil {
+				return nil, err
+			}
+		}
+		return append(buf, ']'), nil
+	}
+
+	typedEncoders.Store(reflect.TypeOf((*S)(nil)), func(v interface{}) ([]byte, error) {
+		value := v.(*S)
+		if value == nil {
+			return []byte("null"), nil
+		}
+		return codec.Append(make([]byte, 0, 64), value)
+	})
+	typedEncoders.Store(reflect.TypeOf([]*S(nil)), func(v interface{}) ([]byte, error) {
+		return encodeSlice(v.([]*S))
+	})
+	typedEncoders.Store(reflect.TypeOf((*[]*S)(nil)), func(v interface{}) ([]byte, error) {
+		values := v.(*[]*S)
+		if values == nil {
+			return []byte("null"), nil
+		}
+		return encodeSlice(*values)
+	})
+
+	typedDecoders.Store(reflect.TypeOf((*S)(nil)), func(data []byte, v interface{}) error {
+		return codec.Decode(data, v.(*S))
+	})
+	typedDecoders.Store(reflect.TypeOf((*[]*S)(nil)), func(data []byte, v interface{}) error {
+		ptr := v.(*[]*S)
+		if !json.Valid(data) {
+			return json.Unmarshal(data, ptr)
+		}
+		data = data[skipJSONSpace(data, 0):]
+		if isJSONNull(data) {
+			*ptr = nil
+			return nil
+		}
+		if data[0] != '[' {
+			return json.Unmarshal(data, ptr)
+		}
+		values := make([]*S, 0)
+		err := eachJSONElement(data, func(element []byte) error {
+			if isJSONNull(element) {
+				values = append(values, nil)
+				return nil
+			}
+			value := new(S)
+			if err := codec.Decode(element, value); err != nil {
+				return err
+			}
+			values = append(values, value)
+			return nil
+		})
+		if err != nil {
+			return json.Unmarshal(data, ptr)
+		}
+		*ptr = values
+		return nil
+	})
+}
+
+// Encodes the value to JSON with the codec registered for its type, returning false if there's none.
+//
+// Values whose encoding fails are encoded with encoding/json, so that the same error is returned
+func EncodeJSON(v interface{}) ([]byte, bool, error) {
+	encode, ok := typedEncoders.Load(reflect.TypeOf(v))
+	if !ok {
+		return nil, false, nil
+	}
+	b, err := encode.(func(interface{}) ([]byte, error))(v)
+	if err != nil {
+		b, err = json.Marshal(v)
+	}
+	return b, true, err
+}
+
+// Decodes the JSON into v with the codec registered for its type, returning false if there's none. Nil
+// pointers aren't decoded, so that encoding/json reports them
+func DecodeJSON(data []byte, v interface{}) (bool, error) {
+	decode, ok := typedDecoders.Load(reflect.TypeOf(v))
+	if !ok || reflect.ValueOf(v).IsNil() {
+		return false, nil
+	}
+	return true, decode.(func([]byte, interface{}) error)(data, v)
+}
@@ -0,0 +1,387 @@
+package drreflect
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// Functions used by the codecs generated by the datarepo-gen command to encode and decode the fields of a
+// struct. They follow the behavior of encoding/json, which is used for the values they can't handle
+
+type jsonSigned interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64
+}
+
+type jsonUnsigned interface {
+	~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+type jsonFloat interface {
+	~float32 | ~float64
+}
+
+// Error returned when a float can't be encoded, like NaN, so that the value is encoded with encoding/json
+var errUnsupportedFloat = errors.New("unsupported float value")
+
+const hex = "0123456789abcdef"
+
+// Appends the key of an object member, preceded by a comma unless it's the first member appended after start
+func AppendJSONKey(buf []byte, start int, key string) []byte {
+	if len(buf) > start {
+		buf = append(buf, ',')
+	}
+	return append(buf, key...)
+}
+
+// Appends a string escaped like encoding/json does, including the HTML characters. Strings with invalid
+// UTF-8 are encoded with encoding/json, whose replacement of the invalid bytes depends on the Go version
+func AppendJSONString[T ~string](buf []byte, s T) []byte {
+	if !utf8.ValidString(string(s)) {
+		encoded, _ := json.Marshal(string(s))
+		return append(buf, encoded...)
+	}
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			buf = append(buf, s[start:i]...)
+			switch b {
+			case '\\', '"':
+				buf = append(buf, '\\', b)
+			case '\b':
+				buf = append(buf, '\\', 'b')
+			case '\f':
+				buf = append(buf, '\\', 'f')
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				buf = append(buf, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(string(s[i:]))
+		if c == '\u2028' || c == '\u2029' {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, '\\', 'u', '2', '0', '2', hex[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
+}
+
+func AppendJSONInt[T jsonSigned](buf []byte, v T) []byte {
+	return strconv.AppendInt(buf, int64(v), 10)
+}
+
+func AppendJSONUint[T jsonUnsigned](buf []byte, v T) []byte {
+	return strconv.AppendUint(buf, uint64(v), 10)
+}
+
+// Appends a float formatted like encoding/json does. An error is returned for NaN and infinite values
+func AppendJSONFloat[T jsonFloat](buf []byte, v T) ([]byte, error) {
+	bits := int(unsafe.Sizeof(v)) * 8
+	f := float64(v)
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return buf, errUnsupportedFloat
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	buf = strconv.AppendFloat(buf, f, format, -1, bits)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(buf)
+		if n >= 4 && buf[n-4] == 'e' && buf[n-3] == '-' && buf[n-2] == '0' {
+			buf[n-2] = buf[n-1]
+			buf = buf[:n-1]
+		}
+	}
+	return buf, nil
+}
+
+func AppendJSONBool[T ~bool](buf []byte, v T) []byte {
+	return strconv.AppendBool(buf, bool(v))
+}
+
+// Appends the value encoded with encoding/json. The value should be a pointer to the field, so that the
+// methods of the pointer are used as they are when the struct is encoded
+func AppendJSON(buf []byte, v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return buf, err
+	}
+	return append(buf, b...), nil
+}
+
+// Checks if a field with the omitempty option is left out by encoding/json
+func IsEmptyJSONValue(v interface{}) bool {
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return value.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Ptr:
+		return value.IsZero()
+	case reflect.Invalid:
+		return true
+	}
+	return false
+}
+
+// Decodes a JSON object into v, invoking field with the members whose key matches one of the names of the
+// fields, case-insensitively like encoding/json does.
+//
+// If the JSON isn't a valid object or field returns an error, then the object is decoded with encoding/json,
+// so that the same value and error are obtained
+func DecodeJSONObject[S any](data []byte, v *S, names []string, field func(name string, value []byte) error) error {
+	if !json.Valid(data) {
+		return json.Unmarshal(data, v)
+	}
+	object := data[skipJSONSpace(data, 0):]
+	if isJSONNull(object) {
+		return nil
+	}
+	if object[0] != '{' {
+		return json.Unmarshal(data, v)
+	}
+	err := eachJSONMember(object, func(key []byte, value []byte) error {
+		name, ok := matchJSONName(key, names)
+		if !ok {
+			return nil
+		}
+		return field(name, value)
+	})
+	if err != nil {
+		return json.Unmarshal(data, v)
+	}
+	return nil
+}
+
+func DecodeJSONString[T ~string](value []byte, v *T) error {
+	if isJSONNull(value) {
+		return nil
+	}
+	if len(value) >= 2 && value[0] == '"' {
+		s := value[1 : len(value)-1]
+		if bytes.IndexByte(s, '\\') < 0 && utf8.Valid(s) {
+			*v = T(s)
+			return nil
+		}
+	}
+	return json.Unmarshal(value, v)
+}
+
+func DecodeJSONInt[T jsonSigned](value []byte, v *T) error {
+	if isJSONNull(value) {
+		return nil
+	}
+	n, err := strconv.ParseInt(string(value), 10, int(unsafe.Sizeof(*v))*8)
+	if err != nil {
+		return json.Unmarshal(value, v)
+	}
+	*v = T(n)
+	return nil
+}
+
+func DecodeJSONUint[T jsonUnsigned](value []byte, v *T) error {
+	if isJSONNull(value) {
+		return nil
+	}
+	n, err := strconv.ParseUint(string(value), 10, int(unsafe.Sizeof(*v))*8)
+	if err != nil {
+		return json.Unmarshal(value, v)
+	}
+	*v = T(n)
+	return nil
+}
+
+func DecodeJSONFloat[T jsonFloat](value []byte, v *T) error {
+	if isJSONNull(value) {
+		return nil
+	}
+	if len(value) > 0 && value[0] != '"' {
+		if f, err := strconv.ParseFloat(string(value), int(unsafe.Sizeof(*v))*8); err == nil {
+			*v = T(f)
+			return nil
+		}
+	}
+	return json.Unmarshal(value, v)
+}
+
+func DecodeJSONBool[T ~bool](value []byte, v *T) error {
+	switch string(value) {
+	case "null":
+		return nil
+	case "true":
+		*v = true
+		return nil
+	case "false":
+		*v = false
+		return nil
+	}
+	return json.Unmarshal(value, v)
+}
+
+// Decodes a JSON value into a pointer field with the decoder of its element. null sets the pointer to nil,
+// and other values are decoded into the element the pointer already points to, if any
+func DecodeJSONPtr[T any](value []byte, v **T, decode func([]byte, *T) error) error {
+	if isJSONNull(value) {
+		*v = nil
+		return nil
+	}
+	if *v == nil {
+		*v = new(T)
+	}
+	return decode(value, *v)
+}
+
+// returns the name matching the key of an object member, the exact match first
+func matchJSONName(key []byte, names []string) (string, bool) {
+	var name string
+	if unquoted := key[1 : len(key)-1]; bytes.IndexByte(unquoted, '\\') < 0 && utf8.Valid(unquoted) {
+		name = string(unquoted)
+	} else if err := json.Unmarshal(key, &name); err != nil {
+		return "", false
+	}
+	for _, n := range names {
+		if n == name {
+			return n, true
+		}
+	}
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return n, true
+		}
+	}
+	return "", false
+}
+
+func isJSONNull(value []byte) bool {
+	return len(value) == 4 && string(value) == "null"
+}
+
+// invokes fn with the quoted key and the value of every member of a valid JSON object
+func eachJSONMember(data []byte, fn func(key []byte, value []byte) error) error {
+	i := skipJSONSpace(data, 1)
+	if data[i] == '}' {
+		return nil
+	}
+	for {
+		keyEnd := skipJSONString(data, i)
+		key := data[i:keyEnd]
+		// skips the colon
+		i = skipJSONSpace(data, skipJSONSpace(data, keyEnd)+1)
+		valueEnd := skipJSONValue(data, i)
+		if err := fn(key, data[i:valueEnd]); err != nil {
+			return err
+		}
+		i = skipJSONSpace(data, valueEnd)
+		if data[i] == '}' {
+			return nil
+		}
+		// skips the comma
+		i = skipJSONSpace(data, i+1)
+	}
+}
+
+// invokes fn with every element of a valid JSON array
+func eachJSONElement(data []byte, fn func(element []byte) error) error {
+	i := skipJSONSpace(data, 1)
+	if data[i] == ']' {
+		return nil
+	}
+	for {
+		end := skipJSONValue(data, i)
+		if err := fn(data[i:end]); err != nil {
+			return err
+		}
+		i = skipJSONSpace(data, end)
+		if data[i] == ']' {
+			return nil
+		}
+		i = skipJSONSpace(data, i+1)
+	}
+}
+
+// returns the index following the valid JSON value that starts at i
+func skipJSONValue(data []byte, i int) int {
+	switch data[i] {
+	case '"':
+		return skipJSONString(data, i)
+	case '{', '[':
+		depth := 0
+		for ; i < len(data); i++ {
+			switch data[i] {
+			case '"':
+				i = skipJSONString(data, i) - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				if depth--; depth == 0 {
+					return i + 1
+				}
+			}
+		}
+		return i
+	default:
+		for i < len(data) && !isJSONDelimiter(data[i]) {
+			i++
+		}
+		return i
+	}
+}
+
+// returns the index following the valid JSON string that starts at i
+func skipJSONString(data []byte, i int) int {
+	for i++; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return i
+}
+
+func skipJSONSpace(data []byte, i int) int {
+	for i < len(data) && isJSONSpace(data[i]) {
+		i++
+	}
+	return i
+}
+
+func isJSONSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+func isJSONDelimiter(b byte) bool {
+	return b == ',' || b == '}' || b == ']' || isJSONSpace(b)
+}
@@ -16,6 +16,9 @@ type PointerVHandler interface {
 	Element() interface{}
 	// changes the element to be the zero value for the respective ElementType
 	SetZeroElement()
-	// changes the element to be the provided value
+	// changes the element to be the provided value, which can be either of type ElementType() or Type().
+	// Panics if the value is of a different type
 	SetElement(value interface{})
+	// same as SetElement, but returns an error wrapping ErrTypeMismatch instead of panicking
+	SetElementE(value interface{}) error
 }
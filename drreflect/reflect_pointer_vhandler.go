@@ -1,29 +1,59 @@
 package drreflect
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
 )
 
+// Error returned instead of panicking when a value can't be handled because of its type
+var ErrTypeMismatch = errors.New("the provided value isn't of the expected type")
+
 type reflectPointerVHandler struct {
 	t     reflect.Type
 	ptr   reflect.Type
 	value reflect.Value
 }
 
+// Creates a PointerVHandler for the value, which must be a non-nil pointer. Pointers to types registered
+// with Register are handled without reflection
 func NewReflectPointerVHandler(value interface{}) PointerVHandler {
+	if h, ok := typedPointerHandler(value); ok {
+		return h
+	}
 	return newReflectPointerVHandler(reflect.ValueOf(value))
 }
 
+// Same as NewReflectPointerVHandler but returns an error wrapping ErrTypeMismatch instead of panicking
+// if the value isn't a non-nil pointer
+func NewReflectPointerVHandlerE(value interface{}) (PointerVHandler, error) {
+	if h, ok := typedPointerHandler(value); ok {
+		return h, nil
+	}
+	return newReflectPointerVHandlerE(reflect.ValueOf(value))
+}
+
 func newReflectPointerVHandler(value reflect.Value) *reflectPointerVHandler {
-	if value.Type().Kind() != reflect.Ptr {
-		panic("value is not a pointer")
+	h, err := newReflectPointerVHandlerE(value)
+	if err != nil {
+		panic(err.Error())
+	}
+	return h
+}
+
+func newReflectPointerVHandlerE(value reflect.Value) (*reflectPointerVHandler, error) {
+	if !value.IsValid() || value.Type().Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("%w: value is not a pointer", ErrTypeMismatch)
+	}
+	if value.IsNil() {
+		return nil, fmt.Errorf("%w: value is a nil pointer of type %s", ErrTypeMismatch, value.Type())
 	}
 
 	return &reflectPointerVHandler{
 		t:     value.Elem().Type(),
 		ptr:   value.Type(),
 		value: value,
-	}
+	}, nil
 }
 
 func (r *reflectPointerVHandler) Type() reflect.Type {
@@ -47,10 +77,25 @@ func (r *reflectPointerVHandler) SetZeroElement() {
 }
 
 func (r *reflectPointerVHandler) SetElement(value interface{}) {
-	t := reflect.TypeOf(value)
+	if err := r.SetElementE(value); err != nil {
+		panic(err.Error())
+	}
+}
+
+func (r *reflectPointerVHandler) SetElementE(value interface{}) error {
 	v := reflect.ValueOf(value)
-	if t == r.ptr {
+	if !v.IsValid() {
+		return fmt.Errorf("%w: a nil value can't be set into %s", ErrTypeMismatch, r.ptr)
+	}
+	if v.Type() == r.ptr {
+		if v.IsNil() {
+			return fmt.Errorf("%w: a nil value can't be set into %s", ErrTypeMismatch, r.ptr)
+		}
 		v = v.Elem()
 	}
+	if !v.Type().AssignableTo(r.t) {
+		return fmt.Errorf("%w: a value of type %s can't be set into %s", ErrTypeMismatch, v.Type(), r.ptr)
+	}
 	r.value.Elem().Set(v)
+	return nil
 }
@@ -1,6 +1,7 @@
 package drreflect
 
 import (
+	"fmt"
 	"reflect"
 )
 
@@ -8,16 +9,41 @@ type reflectSlicePointerVHandler struct {
 	*reflectPointerVHandler
 }
 
+// Creates a SlicePointerHandler for the value, which must be a non-nil pointer to a slice. Pointers to slices
+// of types registered with Register are handled without reflection
 func NewReflectSlicePointerVHandler(value interface{}) SlicePointerHandler {
+	if h, ok := typedSlicePointerHandler(value); ok {
+		return h
+	}
 	return newReflectSlicePointerVHandler(reflect.ValueOf(value))
 }
 
+// Same as NewReflectSlicePointerVHandler but returns an error wrapping ErrTypeMismatch instead of panicking
+// if the value isn't a non-nil pointer to a slice
+func NewReflectSlicePointerVHandlerE(value interface{}) (SlicePointerHandler, error) {
+	if h, ok := typedSlicePointerHandler(value); ok {
+		return h, nil
+	}
+	return newReflectSlicePointerVHandlerE(reflect.ValueOf(value))
+}
+
 func newReflectSlicePointerVHandler(value reflect.Value) *reflectSlicePointerVHandler {
-	ptrHandler := newReflectPointerVHandler(value)
+	h, err := newReflectSlicePointerVHandlerE(value)
+	if err != nil {
+		panic(err.Error())
+	}
+	return h
+}
+
+func newReflectSlicePointerVHandlerE(value reflect.Value) (*reflectSlicePointerVHandler, error) {
+	ptrHandler, err := newReflectPointerVHandlerE(value)
+	if err != nil {
+		return nil, err
+	}
 	if ptrHandler.ElementType().Kind() != reflect.Slice {
-		panic("The provided instance is not a Slice, got " + ptrHandler.Type().String())
+		return nil, fmt.Errorf("%w: the provided instance is not a pointer to a slice, got %s", ErrTypeMismatch, ptrHandler.Type())
 	}
-	return &reflectSlicePointerVHandler{ptrHandler}
+	return &reflectSlicePointerVHandler{ptrHandler}, nil
 }
 
 func (s *reflectSlicePointerVHandler) ElementTypeHandler() TypeHandler {
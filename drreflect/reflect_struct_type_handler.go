@@ -1,32 +1,48 @@
 package drreflect
 
 import (
+	"errors"
 	"reflect"
+	"strings"
+	"sync"
 )
 
 type reflectStructTypeHandler struct {
 	*reflectTypeHandler
+	// *fieldPath of the fields read with GetFieldValue, by field name
+	fieldPaths sync.Map
 }
 
+// StructTypeHandler of the struct types, by type
+var structTypeHandlers sync.Map
+
+// Creates a StructTypeHandler for the type of v, which must be a struct or a pointer to a struct. Types
+// registered with Register are handled without reflection
 func NewReflectStructTypeHandlerFromValue(v interface{}) StructTypeHandler {
+	if h, ok := typedStructHandler(reflect.TypeOf(v)); ok {
+		return h
+	}
 	return NewReflectStructTypeHandler(reflect.TypeOf(v))
 }
 
+// Creates a StructTypeHandler for t, which must be a struct or a pointer to a struct. Handlers are created
+// once per type and shared, so field paths are resolved only once
 func NewReflectStructTypeHandler(t reflect.Type) *reflectStructTypeHandler {
+	if err := ValidateStructType(t); err != nil {
+		panic(err.Error())
+	}
 	if t.Kind() == reflect.Ptr {
-		if t.Elem().Kind() != reflect.Struct {
-			panic("provided type was a pointer but not to a struct: " + t.String())
-		}
 		t = t.Elem()
 	}
-	if t.Kind() != reflect.Struct {
-		panic("provided type wasn't a struct or a pointer to a struct: " + t.String())
+	if sth, ok := structTypeHandlers.Load(t); ok {
+		return sth.(*reflectStructTypeHandler)
 	}
 
-	sth := reflectStructTypeHandler{
-		NewReflectTypeHandler(t),
+	sth := &reflectStructTypeHandler{
+		reflectTypeHandler: NewReflectTypeHandler(t),
 	}
-	return &sth
+	actual, _ := structTypeHandlers.LoadOrStore(t, sth)
+	return actual.(*reflectStructTypeHandler)
 }
 
 func (r *reflectStructTypeHandler) GetFieldValue(input interface{}, fieldName string) interface{} {
@@ -34,10 +50,100 @@ func (r *reflectStructTypeHandler) GetFieldValue(input interface{}, fieldName st
 	if r.t != t && r.ptr != t {
 		panic("unexpected value type " + t.String() + " for accessor of type " + r.t.String())
 	}
+	path, ok := r.fieldPath(fieldName)
+	if !ok {
+		panic("field " + fieldName + " is not defined in type " + r.t.String())
+	}
+
 	v := reflect.ValueOf(input)
-	if r.ptr == t {
+	for _, i := range path.index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
 		v = v.Elem()
 	}
-	fieldValue := v.FieldByName(fieldName)
-	return fieldValue.Interface()
+	return v.Interface()
+}
+
+func (r *reflectStructTypeHandler) FieldType(fieldName string) (reflect.Type, bool) {
+	path, ok := r.fieldPath(fieldName)
+	if !ok {
+		return nil, false
+	}
+	return path.fieldType, true
+}
+
+// The resolved location of a field in a struct
+type fieldPath struct {
+	// indexes of the fields to traverse from the struct, dereferencing pointers, to reach the field
+	index []int
+	// type of the field, dereferenced if it's a pointer
+	fieldType reflect.Type
+}
+
+// returns the location of the field, resolving it only the first time the field is read
+func (r *reflectStructTypeHandler) fieldPath(fieldName string) (*fieldPath, bool) {
+	if path, ok := r.fieldPaths.Load(fieldName); ok {
+		return path.(*fieldPath), true
+	}
+	path, ok := resolveFieldPath(r.t, fieldName)
+	if !ok {
+		return nil, false
+	}
+	r.fieldPaths.Store(fieldName, path)
+	return path, true
+}
+
+// resolves a field name or a dotted path of field names in the struct type t
+func resolveFieldPath(t reflect.Type, fieldName string) (*fieldPath, bool) {
+	path := &fieldPath{}
+	fieldType := t
+	for _, name := range strings.Split(fieldName, ".") {
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() != reflect.Struct {
+			return nil, false
+		}
+		field, ok := fieldType.FieldByName(name)
+		if !ok {
+			return nil, false
+		}
+		// fields promoted from embedded structs have one index per embedded struct, and embedded
+		// structs may be pointers
+		path.index = append(path.index, field.Index...)
+		fieldType = field.Type
+	}
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	path.fieldType = fieldType
+	return path, true
+}
+
+// Checks that the provided type is a struct or a pointer to a struct, which are the types
+// a StructTypeHandler can be created for
+func ValidateStructType(t reflect.Type) error {
+	if t == nil {
+		return errors.New("provided type must not be nil")
+	}
+	if t.Kind() == reflect.Ptr {
+		if t.Elem().Kind() != reflect.Struct {
+			return errors.New("provided type was a pointer but not to a struct: " + t.String())
+		}
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return errors.New("provided type wasn't a struct or a pointer to a struct: " + t.String())
+	}
+	return nil
 }
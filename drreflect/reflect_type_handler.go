@@ -2,6 +2,7 @@ package drreflect
 
 import (
 	"reflect"
+	"sync"
 )
 
 type reflectTypeHandler struct {
@@ -15,15 +16,23 @@ func NewReflectTypeHandlerFromValue(v interface{}) TypeHandler {
 	return NewReflectTypeHandler(reflect.TypeOf(v))
 }
 
+// TypeHandler of the types, by type
+var typeHandlers sync.Map
+
+// Creates a TypeHandler for t. Handlers are created once per type and shared
 func NewReflectTypeHandler(t reflect.Type) *reflectTypeHandler {
+	if th, ok := typeHandlers.Load(t); ok {
+		return th.(*reflectTypeHandler)
+	}
 	ptr := reflect.PtrTo(t)
-	th := reflectTypeHandler{
+	th := &reflectTypeHandler{
 		t:        t,
 		ptr:      ptr,
 		slice:    reflect.SliceOf(t),
 		slicePtr: reflect.SliceOf(ptr),
 	}
-	return &th
+	actual, _ := typeHandlers.LoadOrStore(t, th)
+	return actual.(*reflectTypeHandler)
 }
 
 func (r *reflectTypeHandler) Type() reflect.Type {
@@ -0,0 +1,81 @@
+package drreflect
+
+import (
+	"encoding"
+	"encoding/json"
+	"hash/fnv"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	fingerprints      sync.Map
+)
+
+// Returns a fingerprint of the JSON schema of the provided type, which changes whenever a field that is
+// serialized to JSON is added, removed, renamed or changes its type.
+//
+// Pointers are ignored, as they don't change the JSON representation of a value, so *A has the same
+// fingerprint as A, and *[]*A the same as []A
+func SchemaFingerprint(t reflect.Type) string {
+	if fingerprint, ok := fingerprints.Load(t); ok {
+		return fingerprint.(string)
+	}
+	var sb strings.Builder
+	describeType(&sb, t, make(map[reflect.Type]bool))
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(sb.String()))
+	fingerprint := strconv.FormatUint(h.Sum64(), 16)
+	fingerprints.Store(t, fingerprint)
+	return fingerprint
+}
+
+// writes a description of the JSON schema of the type
+func describeType(sb *strings.Builder, t reflect.Type, visiting map[reflect.Type]bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	// types with a custom serialization are described by their name only
+	if t.Name() != "" && (t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType) ||
+		t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType)) {
+		sb.WriteString(t.PkgPath() + "." + t.Name())
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		sb.WriteString("[]")
+		describeType(sb, t.Elem(), visiting)
+	case reflect.Map:
+		sb.WriteString("map[")
+		describeType(sb, t.Key(), visiting)
+		sb.WriteString("]")
+		describeType(sb, t.Elem(), visiting)
+	case reflect.Struct:
+		if visiting[t] {
+			// recursive types are described by their name the second time they're found
+			sb.WriteString(t.PkgPath() + "." + t.Name())
+			return
+		}
+		visiting[t] = true
+		sb.WriteString("{")
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("json")
+			if (field.PkgPath != "" && !field.Anonymous) || tag == "-" {
+				continue
+			}
+			sb.WriteString(field.Name + " " + tag + " ")
+			describeType(sb, field.Type, visiting)
+			sb.WriteString(";")
+		}
+		sb.WriteString("}")
+		delete(visiting, t)
+	default:
+		sb.WriteString(t.Kind().String())
+	}
+}
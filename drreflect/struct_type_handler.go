@@ -1,11 +1,21 @@
 package drreflect
 
+import "reflect"
+
 type StructTypeHandler interface {
 	TypeHandler
 	// Returns the value of the specified field
 	//
+	// The field name can be the name of a field of the struct, including the fields promoted from embedded
+	// structs, or a dotted path to a field of a nested struct, like "Meta.ExternalID". Pointer fields are
+	// dereferenced, so a *string field returns a string, and nil is returned if the field, or a pointer in the
+	// path to the field, is nil
+	//
 	// If the struct is of type A, the `input` to this function is expected to be of type *A or of type A
 	//
-	// This function panics if the input is not of the expected type
+	// This function panics if the input is not of the expected type or the field isn't defined
 	GetFieldValue(input interface{}, fieldName string) interface{}
+	// Returns the type of the values of the specified field, as returned by GetFieldValue, and true if the
+	// field exists in the struct, or nil and false if the struct doesn't define a field with the given name
+	FieldType(fieldName string) (reflect.Type, bool)
 }
@@ -0,0 +1,304 @@
+package drreflect
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Functions that return the value of the fields of a struct of type S by field name. They must return the
+// same values as GetFieldValue, that is, the value pointed by pointer fields, or nil if the pointer is nil
+type FieldAccessors[S any] map[string]func(*S) interface{}
+
+var (
+	// StructTypeHandler of the registered types, by type
+	typedStructHandlers sync.Map
+	// functions creating a PointerVHandler for values of the pointer types derived from the registered types
+	typedPointerHandlers sync.Map
+	// functions creating a SlicePointerHandler for values of the pointer to slice types derived from the
+	// registered types
+	typedSlicePointerHandlers sync.Map
+)
+
+// Registers the struct type S so that the handlers created for S, *S, []*S and *[]*S by this package work
+// without reflection, reading the fields of S with the provided accessors.
+//
+// Registered types behave exactly as unregistered types. Fields without an accessor and the less common
+// operations still use reflection. Register is meant to be called from the init function of code generated
+// by the datarepo-gen command
+func Register[S any](accessors FieldAccessors[S]) {
+	t := reflect.TypeOf((*S)(nil)).Elem()
+	if err := ValidateStructType(t); err != nil {
+		panic(err.Error())
+	}
+	if t.Kind() == reflect.Ptr {
+		panic("provided type must not be a pointer: " + t.String())
+	}
+
+	// handlers of S, *S, []*S and *[]*S
+	structHandler := &typedStructTypeHandler[S]{
+		typedTypeHandler: newTypedTypeHandler[S](),
+		accessors:        accessors,
+		fallback:         NewReflectStructTypeHandler(t),
+	}
+	ptrHandler := newTypedTypeHandler[*S]()
+	sliceHandler := newTypedTypeHandler[[]*S]()
+	slicePtrHandler := newTypedTypeHandler[*[]*S]()
+	structHandler.ptr, structHandler.slicePtr = ptrHandler, sliceHandler
+	ptrHandler.elem = structHandler
+	sliceHandler.elem, sliceHandler.ptr = ptrHandler, slicePtrHandler
+	slicePtrHandler.elem = sliceHandler
+
+	typedStructHandlers.Store(t, structHandler)
+	registerPointerHandler[S]()
+	registerPointerHandler[*S]()
+	registerSlicePointerHandler[*S](ptrHandler)
+	registerSlicePointerHandler[*[]*S](slicePtrHandler)
+}
+
+// registers the creation of handlers for values of type *E
+func registerPointerHandler[E any]() {
+	typedPointerHandlers.Store(reflect.TypeOf((*E)(nil)), func(value interface{}) (PointerVHandler, bool) {
+		ptr := value.(*E)
+		if ptr == nil {
+			return nil, false
+		}
+		return &typedPointerVHandler[E]{value: ptr}, true
+	})
+}
+
+// registers the creation of handlers for values of type *[]E, given the TypeHandler of E
+func registerSlicePointerHandler[E any](elem TypeHandler) {
+	create := func(value interface{}) (SlicePointerHandler, bool) {
+		ptr := value.(*[]E)
+		if ptr == nil {
+			return nil, false
+		}
+		return &typedSlicePointerVHandler[E]{typedPointerVHandler[[]E]{value: ptr}, elem}, true
+	}
+	t := reflect.TypeOf((*[]E)(nil))
+	typedSlicePointerHandlers.Store(t, create)
+	typedPointerHandlers.Store(t, func(value interface{}) (PointerVHandler, bool) {
+		return create(value)
+	})
+}
+
+// returns the handler of a registered struct type
+func typedStructHandler(t reflect.Type) (StructTypeHandler, bool) {
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	h, ok := typedStructHandlers.Load(t)
+	if !ok {
+		return nil, false
+	}
+	return h.(StructTypeHandler), true
+}
+
+// returns a handler for a non-nil pointer of a type derived from a registered type
+func typedPointerHandler(value interface{}) (PointerVHandler, bool) {
+	create, ok := typedPointerHandlers.Load(reflect.TypeOf(value))
+	if !ok {
+		return nil, false
+	}
+	return create.(func(interface{}) (PointerVHandler, bool))(value)
+}
+
+// returns a handler for a non-nil pointer to a slice of a type derived from a registered type
+func typedSlicePointerHandler(value interface{}) (SlicePointerHandler, bool) {
+	create, ok := typedSlicePointerHandlers.Load(reflect.TypeOf(value))
+	if !ok {
+		return nil, false
+	}
+	return create.(func(interface{}) (SlicePointerHandler, bool))(value)
+}
+
+// TypeHandler of type X that creates values without reflection. The handlers of the types derived from X are
+// set when X is registered, the operations on types that weren't registered use reflection
+type typedTypeHandler[X any] struct {
+	*reflectTypeHandler
+	// handler of the element of X, nil if it isn't registered
+	elem TypeHandler
+	// handler of *X, nil if it isn't registered
+	ptr TypeHandler
+	// handler of []*X, nil if it isn't registered
+	slicePtr TypeHandler
+}
+
+func newTypedTypeHandler[X any]() *typedTypeHandler[X] {
+	return &typedTypeHandler[X]{reflectTypeHandler: NewReflectTypeHandler(reflect.TypeOf((*X)(nil)).Elem())}
+}
+
+func (h *typedTypeHandler[X]) NewPtrToElement() PointerVHandler {
+	return &typedPointerVHandler[X]{value: new(X)}
+}
+
+func (h *typedTypeHandler[X]) NewPtrToSlice() SlicePointerHandler {
+	if h.ptr == nil {
+		return h.reflectTypeHandler.NewPtrToSlice()
+	}
+	return &typedSlicePointerVHandler[*X]{typedPointerVHandler[[]*X]{value: new([]*X)}, h.ptr}
+}
+
+func (h *typedTypeHandler[X]) ElementTypeHandler() TypeHandler {
+	if h.elem == nil {
+		return h.reflectTypeHandler.ElementTypeHandler()
+	}
+	return h.elem
+}
+
+func (h *typedTypeHandler[X]) SlicePtrTypeHandler() TypeHandler {
+	if h.slicePtr == nil {
+		return h.reflectTypeHandler.SlicePtrTypeHandler()
+	}
+	return h.slicePtr
+}
+
+func (h *typedTypeHandler[X]) IsOfType(input interface{}) bool {
+	_, ok := input.(X)
+	return ok
+}
+
+func (h *typedTypeHandler[X]) IsOfPtrType(input interface{}) bool {
+	_, ok := input.(*X)
+	return ok
+}
+
+// StructTypeHandler of a registered struct type S
+type typedStructTypeHandler[S any] struct {
+	*typedTypeHandler[S]
+	accessors FieldAccessors[S]
+	// handler used for the fields without an accessor
+	fallback *reflectStructTypeHandler
+}
+
+func (h *typedStructTypeHandler[S]) GetFieldValue(input interface{}, fieldName string) interface{} {
+	accessor, ok := h.accessors[fieldName]
+	if !ok {
+		return h.fallback.GetFieldValue(input, fieldName)
+	}
+	switch v := input.(type) {
+	case *S:
+		// like the fields read with reflection, the fields of a nil pointer are nil
+		if v == nil {
+			return nil
+		}
+		return accessor(v)
+	case S:
+		return accessor(&v)
+	default:
+		return h.fallback.GetFieldValue(input, fieldName)
+	}
+}
+
+func (h *typedStructTypeHandler[S]) FieldType(fieldName string) (reflect.Type, bool) {
+	return h.fallback.FieldType(fieldName)
+}
+
+// PointerVHandler of a value of type *E
+type typedPointerVHandler[E any] struct {
+	value *E
+}
+
+func (p *typedPointerVHandler[E]) Type() reflect.Type {
+	return reflect.TypeOf(p.value)
+}
+
+func (p *typedPointerVHandler[E]) ElementType() reflect.Type {
+	return reflect.TypeOf(p.value).Elem()
+}
+
+func (p *typedPointerVHandler[E]) Ptr() interface{} {
+	return p.value
+}
+
+func (p *typedPointerVHandler[E]) Element() interface{} {
+	return *p.value
+}
+
+func (p *typedPointerVHandler[E]) SetZeroElement() {
+	var zero E
+	*p.value = zero
+}
+
+func (p *typedPointerVHandler[E]) SetElement(value interface{}) {
+	if err := p.SetElementE(value); err != nil {
+		panic(err.Error())
+	}
+}
+
+func (p *typedPointerVHandler[E]) SetElementE(value interface{}) error {
+	switch v := value.(type) {
+	case E:
+		*p.value = v
+		return nil
+	case *E:
+		if v != nil {
+			*p.value = *v
+			return nil
+		}
+	}
+	if value == nil || reflect.TypeOf(value) == p.Type() {
+		return fmt.Errorf("%w: a nil value can't be set into %s", ErrTypeMismatch, p.Type())
+	}
+	return fmt.Errorf("%w: a value of type %T can't be set into %s", ErrTypeMismatch, value, p.Type())
+}
+
+// SlicePointerHandler of a value of type *[]E
+type typedSlicePointerVHandler[E any] struct {
+	typedPointerVHandler[[]E]
+	// handler of E
+	elem TypeHandler
+}
+
+func (s *typedSlicePointerVHandler[E]) ElementTypeHandler() TypeHandler {
+	return s.elem
+}
+
+func (s *typedSlicePointerVHandler[E]) Append(v interface{}) {
+	element, ok := v.(E)
+	if !ok {
+		panic(fmt.Sprintf("%s: a value of type %T can't be appended to %s", ErrTypeMismatch, v, s.Type()))
+	}
+	*s.value = append(*s.value, element)
+}
+
+func (s *typedSlicePointerVHandler[E]) MakeSlice(len, cap int) {
+	*s.value = make([]E, len, cap)
+}
+
+func (s *typedSlicePointerVHandler[E]) CopyFrom(arr interface{}, mapper ElementMapper) {
+	arrValue := reflect.ValueOf(arr)
+	if arrValue.Type().Kind() == reflect.Ptr {
+		arrValue = arrValue.Elem()
+	}
+	if arrValue.Type().Kind() != reflect.Slice {
+		panic("input isn't a Slice: " + arrValue.Type().String())
+	}
+
+	slice := make([]E, arrValue.Len())
+	*s.value = slice
+	for i := range slice {
+		mapper(i, &typedPointerVHandler[E]{value: &slice[i]}, arrValue.Index(i).Interface())
+	}
+}
+
+func (s *typedSlicePointerVHandler[E]) ForEach(procFunction ElementProcessor) {
+	slice := *s.value
+	for i := range slice {
+		procFunction(i, &typedPointerVHandler[E]{value: &slice[i]})
+	}
+}
+
+func (s *typedSlicePointerVHandler[E]) Len() int {
+	return len(*s.value)
+}
+
+func (s *typedSlicePointerVHandler[E]) AsInterfaceSlice() []interface{} {
+	slice := *s.value
+	result := make([]interface{}, len(slice))
+	for i, v := range slice {
+		result[i] = v
+	}
+	return result
+}
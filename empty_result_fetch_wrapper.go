@@ -2,12 +2,13 @@ package datarepo
 
 import (
 	"context"
+	"errors"
 	"github.com/merlinapp/datarepo-go/drreflect"
 )
 
 type emptyResultDataFetcherWrapper struct {
-	dataType interface{}
-	delegate DataFetcher
+	typeHandler drreflect.StructTypeHandler
+	delegate    DataFetcher
 }
 
 func (w *emptyResultDataFetcherWrapper) FindByKey(ctx context.Context, keyFieldName string, id interface{}) (Result, error) {
@@ -16,10 +17,7 @@ func (w *emptyResultDataFetcherWrapper) FindByKey(ctx context.Context, keyFieldN
 		return nil, err
 	}
 	if result.IsEmpty() {
-		th := drreflect.NewReflectStructTypeHandlerFromValue(w.dataType)
-		slice := th.NewPtrToSlice()
-		slice.MakeSlice(0, 1)
-		result = ValueResult{Value: slice.Ptr()}
+		result = w.emptySlice()
 	}
 	return result, err
 }
@@ -32,11 +30,31 @@ func (w *emptyResultDataFetcherWrapper) FindByKeys(ctx context.Context, keyField
 	arr := result
 	for i := 0; i < len(arr); i++ {
 		if arr[i].IsEmpty() {
-			th := drreflect.NewReflectStructTypeHandlerFromValue(w.dataType)
-			slice := th.NewPtrToSlice()
-			slice.MakeSlice(0, 1)
-			arr[i] = ValueResult{Value: slice.Ptr()}
+			arr[i] = w.emptySlice()
 		}
 	}
 	return result, err
 }
+
+// returns the result cached for keys without values, a pointer to an empty slice
+func (w *emptyResultDataFetcherWrapper) emptySlice() Result {
+	slice := w.typeHandler.NewPtrToSlice()
+	slice.MakeSlice(0, 1)
+	return ValueResult{Value: slice.Ptr()}
+}
+
+func (w *emptyResultDataFetcherWrapper) ListKeys(ctx context.Context, keyFieldName string, after interface{}, limit int) ([]interface{}, error) {
+	lister, ok := w.delegate.(KeyLister)
+	if !ok {
+		return nil, errors.New("the data fetcher can't list keys for: " + keyFieldName)
+	}
+	return lister.ListKeys(ctx, keyFieldName, after, limit)
+}
+
+func (w *emptyResultDataFetcherWrapper) SampleKeys(ctx context.Context, keyFieldName string, n int) ([]interface{}, error) {
+	sampler, ok := w.delegate.(KeySampler)
+	if !ok {
+		return nil, errors.New("the data fetcher can't sample keys for: " + keyFieldName)
+	}
+	return sampler.SampleKeys(ctx, keyFieldName, n)
+}
@@ -0,0 +1,41 @@
+package datarepo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/merlinapp/datarepo-go/drreflect"
+)
+
+// Error returned when injecting an empty result
+var ErrNotFound = errors.New("the result is empty")
+
+// Error returned when a key field name doesn't match any of the caches of a repository
+var ErrUnknownKeyField = errors.New("Undefined cache for")
+
+// Error returned when a value can't be injected into or written from a variable of a different type
+var ErrTypeMismatch = drreflect.ErrTypeMismatch
+
+// Error returned when reading data from a repository fails, recording the key field and the ids being read.
+//
+// The cause of the failure can be checked with errors.Is and errors.As, for example, to check if the key
+// field isn't defined: errors.Is(err, ErrUnknownKeyField)
+type FetchError struct {
+	// Name of the key field used to read the data
+	KeyFieldName string
+	// Ids being read
+	IDs []interface{}
+	// Cause of the failure
+	Err error
+}
+
+func (e *FetchError) Error() string {
+	return "Error fetching " + e.KeyFieldName + " " + fmt.Sprint(e.IDs) + ": " + e.Err.Error()
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+func unknownKeyFieldError(keyFieldName string) error {
+	return fmt.Errorf("%w: %s", ErrUnknownKeyField, keyFieldName)
+}
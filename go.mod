@@ -1,19 +1,28 @@
 module github.com/merlinapp/datarepo-go
 
-go 1.13
+go 1.18
 
 require (
 	github.com/DATA-DOG/go-txdb v0.1.3
-	github.com/cespare/xxhash v1.1.0 // indirect
 	github.com/coocood/freecache v1.1.0
 	github.com/go-redis/cache v6.4.0+incompatible
 	github.com/go-redis/redis v6.15.6+incompatible
 	github.com/go-sql-driver/mysql v1.4.1
 	github.com/jinzhu/gorm v1.9.10
-	github.com/kr/pretty v0.1.0 // indirect
 	github.com/satori/uuid v1.2.0
 	github.com/smartystreets/goconvey v1.6.4
 	github.com/spf13/cast v1.3.0
 	github.com/stretchr/testify v1.2.2
+)
+
+require (
+	github.com/cespare/xxhash v1.1.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/jtolds/gls v4.20.0+incompatible // indirect
+	github.com/kr/pretty v0.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/smartystreets/assertions v0.0.0-20180927180507-b2de0cb4f26d // indirect
+	github.com/stretchr/objx v0.1.1 // indirect
 	github.com/vmihailenco/msgpack v4.0.4+incompatible // indirect
 )
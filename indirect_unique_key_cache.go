@@ -0,0 +1,318 @@
+package datarepo
+
+import (
+	"context"
+	"errors"
+	"github.com/merlinapp/datarepo-go/drreflect"
+	"github.com/spf13/cast"
+	"log"
+	"reflect"
+)
+
+// Unique key cache that stores only the primary key of the value of each key. The values are read from the
+// unique key cache of the primary keys, so secondary keys don't hold a copy of the values
+type indirectUniqueKeyCacheHandler struct {
+	uniqueKeyCacheHandler
+	// handler of the primary keys stored in the cache entries
+	primaryKeyTypeHandler drreflect.TypeHandler
+	// unique key cache of the primary keys the values are read from
+	members *Cache
+}
+
+func newIndirectUniqueKeyCacheHandler(handler uniqueKeyCacheHandler, primaryKeyFieldName string) *indirectUniqueKeyCacheHandler {
+	// derived primary keys can be of any type, primary keys read from a field are stored with the type of the field
+	primaryKeyType := reflect.TypeOf((*interface{})(nil)).Elem()
+	if t, ok := handler.subTypeHandler.FieldType(primaryKeyFieldName); ok {
+		primaryKeyType = t
+	}
+	return &indirectUniqueKeyCacheHandler{
+		uniqueKeyCacheHandler: handler,
+		primaryKeyTypeHandler: drreflect.NewReflectTypeHandler(primaryKeyType),
+	}
+}
+
+func (c *indirectUniqueKeyCacheHandler) setMemberCache(cache *Cache) {
+	c.members = cache
+}
+
+func (c *indirectUniqueKeyCacheHandler) Get(ctx context.Context, cacheStore CacheStore, key interface{}, fetcher DataFetcher, options ReadOptions) (Result, error) {
+	results, err := c.GetMulti(ctx, cacheStore, []interface{}{key}, fetcher, options)
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+func (c *indirectUniqueKeyCacheHandler) GetMulti(ctx context.Context, cacheStore CacheStore, keys []interface{}, fetcher DataFetcher, options ReadOptions) ([]Result, error) {
+	if options.SkipCache {
+		return c.uniqueKeyCacheHandler.GetMulti(ctx, cacheStore, keys, fetcher, options)
+	}
+
+	strKeys := make([]string, len(keys))
+	for i, key := range keys {
+		strKeys[i] = c.cacheKey(key)
+	}
+	cached := c.primaryKeyTypeHandler.NewPtrToSlice()
+	cached.MakeSlice(0, len(keys))
+	found, metadata, err := c.getMultiFromStore(ctx, cacheStore, strKeys, cached.Ptr(), options)
+	if err != nil {
+		return nil, err
+	}
+	primaryKeys := make([]interface{}, 0, len(keys))
+	cached.ForEach(func(i int, handler drreflect.PointerVHandler) {
+		if found[i] {
+			primaryKeys = append(primaryKeys, drreflect.NewReflectPointerVHandler(handler.Element()).Element())
+		}
+	})
+	var members []Result
+	if len(primaryKeys) > 0 {
+		memberOptions := options
+		memberOptions.Metadata = false
+		if members, err = c.members.GetMulti(ctx, primaryKeys, memberOptions); err != nil {
+			return nil, err
+		}
+	}
+
+	missingKeyMap := make(map[interface{}]int)
+	missingKeys := make([]interface{}, 0, len(keys))
+	var hits []interface{}
+	results := make([]Result, len(keys))
+	member := 0
+	for i, key := range keys {
+		if found[i] {
+			result := members[member]
+			member++
+			if c.resolves(result, key) {
+				results[i] = cachedResult(result.StoredValue(), metadata[i], options)
+				hits = append(hits, key)
+				continue
+			}
+			// the value no longer has the key, so the entry is removed and the key is fetched again
+			if !options.CacheOnly {
+				if err := cacheStore.Delete(ctx, strKeys[i]); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if _, ok := missingKeyMap[key]; !ok {
+			missingKeyMap[key] = len(missingKeys)
+			missingKeys = append(missingKeys, key)
+		}
+		results[i] = EmptyResult{}
+	}
+	if !options.CacheOnly {
+		c.sampleReadRepair(cacheStore, fetcher, hits)
+	}
+
+	if len(missingKeys) > 0 && !options.CacheOnly {
+		missingResults, err := fetcher.FindByKeys(ctx, c.keyFieldName, missingKeys)
+		if err != nil {
+			return nil, err
+		}
+		var filled []Result
+		for i, key := range missingKeys {
+			if !missingResults[i].IsEmpty() {
+				if err := c.setEntry(ctx, cacheStore, c.cacheKey(key), missingResults[i].StoredValue()); err != nil {
+					log.Println("Error caching entry for key: ", key, "-", err)
+				}
+				filled = append(filled, missingResults[i])
+			}
+		}
+		for i, key := range keys {
+			if idx, ok := missingKeyMap[key]; ok {
+				results[i] = fetchedResult(missingResults[idx], options)
+			}
+		}
+		c.filled(ctx, filled)
+	}
+
+	return results, nil
+}
+
+func (c *indirectUniqueKeyCacheHandler) Refresh(ctx context.Context, cacheStore CacheStore, keys []interface{}, fetcher DataFetcher) ([]Result, error) {
+	results, err := fetcher.FindByKeys(ctx, c.keyFieldName, keys)
+	if err != nil {
+		return nil, err
+	}
+	var filled []Result
+	for i, key := range keys {
+		strKey := c.cacheKey(key)
+		if results[i].IsEmpty() {
+			if err := cacheStore.Delete(ctx, strKey); err != nil {
+				return nil, err
+			}
+		} else {
+			if err := c.setEntry(ctx, cacheStore, strKey, results[i].StoredValue()); err != nil {
+				log.Println("Error caching entry for key: ", strKey, "-", err)
+			}
+			filled = append(filled, results[i])
+		}
+	}
+	c.filled(ctx, filled)
+	return results, nil
+}
+
+// Stores the primary key of the value in the entry of its key, removing the entry of the previous key of the
+// value if it changed. The value itself is only stored in the unique key cache of the primary keys
+func (c *indirectUniqueKeyCacheHandler) Set(ctx context.Context, cacheStore CacheStore, value interface{}) error {
+	key, ok := c.ValueCacheKey(value)
+	if !ok {
+		return c.deletePreviousEntry(ctx, cacheStore, value, "")
+	}
+	primaryKey, ok := c.members.Handler.ValueKey(value)
+	if !ok {
+		return nil
+	}
+	if err := c.deletePreviousEntry(ctx, cacheStore, value, key); err != nil {
+		return err
+	}
+	return c.setWritten(ctx, cacheStore, key, primaryKey)
+}
+
+// stores the primary key of the value unless its entry is already cached
+func (c *indirectUniqueKeyCacheHandler) setMissing(ctx context.Context, cacheStore CacheStore, value interface{}) error {
+	key, ok := c.ValueCacheKey(value)
+	if !ok {
+		return nil
+	}
+	primaryKey, ok := c.members.Handler.ValueKey(value)
+	if !ok {
+		return nil
+	}
+	found, err := cacheStore.Get(ctx, key, c.primaryKeyTypeHandler.NewPtrToElement().Ptr())
+	if err != nil || found {
+		return err
+	}
+	return TrySet(ctx, cacheStore, key, primaryKey, c.expiration)
+}
+
+// Deletes the entry of the value, and the entry of the previous key of the value if it changed
+func (c *indirectUniqueKeyCacheHandler) DeleteValue(ctx context.Context, cacheStore CacheStore, value interface{}) error {
+	key, ok := c.ValueCacheKey(value)
+	if err := c.deletePreviousEntry(ctx, cacheStore, value, key); err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	return cacheStore.Delete(ctx, key)
+}
+
+// deletes the entry of the key of the value currently cached in the unique key cache of the primary keys, if
+// it isn't the provided key. Caches of the repository are maintained before the cache of the primary keys
+// when data is written, so the value read is the one being replaced. Entries whose value isn't cached anymore
+// are removed when they are read
+func (c *indirectUniqueKeyCacheHandler) deletePreviousEntry(ctx context.Context, cacheStore CacheStore, value interface{}, key string) error {
+	memberKey, ok := c.members.Handler.ValueCacheKey(value)
+	if !ok {
+		return nil
+	}
+	previous := c.typeHandler.NewPtrToElement()
+	found, err := c.members.Store.Get(ctx, memberKey, previous.Ptr())
+	if err != nil || !found {
+		return err
+	}
+	previousKey, ok := c.ValueCacheKey(previous.Ptr())
+	if !ok || previousKey == key {
+		return nil
+	}
+	return cacheStore.Delete(ctx, previousKey)
+}
+
+// stores the primary key of the value in the entry, and the value in the unique key cache of the primary keys
+func (c *indirectUniqueKeyCacheHandler) setEntry(ctx context.Context, cacheStore CacheStore, key string, value interface{}) error {
+	primaryKey, ok := c.members.Handler.ValueKey(value)
+	if !ok {
+		return errors.New("the value has no primary key for entry with key: " + key)
+	}
+	if err := c.members.Set(ctx, value); err != nil {
+		log.Println("Error caching value of entry for key: ", key, "-", err)
+	}
+	return TrySet(ctx, cacheStore, key, primaryKey, c.expiration)
+}
+
+// checks if the value read for the primary key of an entry still has the key of the entry
+func (c *indirectUniqueKeyCacheHandler) resolves(result Result, key interface{}) bool {
+	if result.IsEmpty() {
+		return false
+	}
+	valueKey, ok := c.ValueKey(result.StoredValue())
+	return ok && cast.ToString(valueKey) == cast.ToString(key)
+}
+
+// verifies a sample of the keys that were found in the cache, if read-repair is enabled
+func (c *indirectUniqueKeyCacheHandler) sampleReadRepair(cacheStore CacheStore, fetcher DataFetcher, hits []interface{}) {
+	if c.readRepair == nil {
+		return
+	}
+	keys := c.readRepair.sample(hits)
+	if len(keys) == 0 {
+		return
+	}
+	c.readRepair.run(func(ctx context.Context) {
+		c.repair(ctx, cacheStore, fetcher, keys)
+	})
+}
+
+// compares the cached primary keys of the keys with the values in the repository, overwriting the entries
+// that differ. The values themselves are verified by the read-repair of the unique key cache of the primary keys
+func (c *indirectUniqueKeyCacheHandler) repair(ctx context.Context, cacheStore CacheStore, fetcher DataFetcher, keys []interface{}) {
+	strKeys := make([]string, len(keys))
+	for i, key := range keys {
+		strKeys[i] = c.cacheKey(key)
+	}
+	// the cache is read before the repository so that values written meanwhile aren't replaced by older ones
+	cached := c.primaryKeyTypeHandler.NewPtrToSlice()
+	cached.MakeSlice(0, len(keys))
+	found, err := cacheStore.GetMulti(ctx, strKeys, cached.Ptr())
+	if err != nil {
+		log.Println("Error reading cache entries for read-repair with prefix: ", c.keyPrefix, "-", err)
+		return
+	}
+	results, err := fetcher.FindByKeys(ctx, c.keyFieldName, keys)
+	if err != nil {
+		log.Println("Error fetching data for read-repair of cache with prefix: ", c.keyPrefix, "-", err)
+		return
+	}
+
+	cachedEntries := make([]interface{}, len(keys))
+	cachedPrimaryKeys := make([]interface{}, len(keys))
+	cached.ForEach(func(i int, handler drreflect.PointerVHandler) {
+		if found[i] {
+			cachedEntries[i] = handler.Element()
+			cachedPrimaryKeys[i] = drreflect.NewReflectPointerVHandler(handler.Element()).Element()
+		}
+	})
+	for i, key := range keys {
+		if !found[i] {
+			continue
+		}
+		var stored interface{}
+		if !results[i].IsEmpty() {
+			stored = results[i].StoredValue()
+			primaryKey, ok := c.members.Handler.ValueKey(stored)
+			if ok && cast.ToString(primaryKey) == cast.ToString(cachedPrimaryKeys[i]) {
+				continue
+			}
+		}
+		if !unchangedEntry(ctx, cacheStore, strKeys[i], c.primaryKeyTypeHandler, cachedEntries[i]) {
+			continue
+		}
+		var repairErr error
+		if stored == nil {
+			repairErr = cacheStore.Delete(ctx, strKeys[i])
+		} else {
+			repairErr = c.setEntry(ctx, cacheStore, strKeys[i], stored)
+		}
+		if repairErr != nil {
+			log.Println("Error repairing cache entry for key: ", strKeys[i], "-", repairErr)
+			continue
+		}
+		c.readRepair.options.OnDrift(DriftEvent{
+			CacheKeyPrefix: c.keyPrefix,
+			Key:            key,
+			Cached:         cachedPrimaryKeys[i],
+			Stored:         stored,
+		})
+	}
+}
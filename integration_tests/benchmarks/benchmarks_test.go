@@ -0,0 +1,131 @@
+package benchmarks
+
+import (
+	"context"
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/cachestore/memory"
+	"github.com/merlinapp/datarepo-go/integration_tests/model"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const valuesPerBenchmark = 100
+
+var (
+	bookCache = datarepo.UniqueKeyCacheDefinition{
+		KeyPrefix:    "b:",
+		KeyFieldName: "ID",
+		Expiration:   5 * time.Minute,
+	}
+	authorCache = datarepo.NonUniqueKeyCacheDefinition{
+		KeyPrefix:       "a:",
+		KeyFieldName:    "AuthorID",
+		SubKeyFieldName: "ID",
+		Expiration:      5 * time.Minute,
+	}
+)
+
+// Reading the cached values of many ids, which decodes the entries returned by the GetMulti of the store
+// and matches them with the ids
+func BenchmarkFindByKeysCached(b *testing.B) {
+	ctx := context.Background()
+	books := newBooks(valuesPerBenchmark)
+	store := memory.NewFreeCacheInMemoryStore(10 * 1024 * 1024)
+	repo := datarepo.CachedRepositoryBuilder(&model.Book{}).
+		WithUniqueKeyDataFetcher(&bookFetcher{books: books}).
+		WithUniqueKeyCache(bookCache, store).
+		BuildROCachedRepository()
+
+	ids := make([]string, len(books))
+	for i, book := range books {
+		ids[i] = book.ID
+	}
+	if _, err := repo.FindByKeys(ctx, "ID", ids); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.FindByKeys(ctx, "ID", ids); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Updating a value in a cached list of a non-unique key cache, which reads the subkey of every element
+// of the list
+func BenchmarkNonUniqueKeySet(b *testing.B) {
+	ctx := context.Background()
+	books := newBooks(valuesPerBenchmark)
+	store := memory.NewFreeCacheInMemoryStore(10 * 1024 * 1024)
+	handler := datarepo.NonUniqueKeyCache(&model.Book{}, authorCache)
+	store.Set(ctx, "a:author", &books, authorCache.Expiration)
+
+	updated := *books[len(books)-1]
+	updated.Status = "updated"
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := handler.Set(ctx, store, &updated); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Injecting the results of many ids into a slice
+func BenchmarkInjectResults(b *testing.B) {
+	books := newBooks(valuesPerBenchmark)
+	results := make([]datarepo.Result, len(books))
+	for i, book := range books {
+		results[i] = datarepo.ValueResult{Value: book}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var out []*model.Book
+		datarepo.InjectResults(results, &out)
+	}
+}
+
+func newBooks(n int) []*model.Book {
+	books := make([]*model.Book, n)
+	for i := range books {
+		books[i] = &model.Book{
+			ID:         "book-" + strconv.Itoa(i),
+			AuthorID:   "author",
+			BookTypeID: "type",
+			Status:     "available",
+		}
+	}
+	return books
+}
+
+// DataFetcher of the books of a slice, so that the benchmarks measure the library and not a database
+type bookFetcher struct {
+	books []*model.Book
+}
+
+func (f *bookFetcher) FindByKey(ctx context.Context, keyFieldName string, id interface{}) (datarepo.Result, error) {
+	results, err := f.FindByKeys(ctx, keyFieldName, []interface{}{id})
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+func (f *bookFetcher) FindByKeys(ctx context.Context, keyFieldName string, ids []interface{}) ([]datarepo.Result, error) {
+	results := make([]datarepo.Result, len(ids))
+	for i, id := range ids {
+		results[i] = datarepo.EmptyResult{}
+		for _, book := range f.books {
+			if book.ID == id {
+				results[i] = datarepo.ValueResult{Value: book}
+				break
+			}
+		}
+	}
+	return results, nil
+}
@@ -1,11 +1,15 @@
 package book_gorm_redis
 
 import (
+	"github.com/jinzhu/gorm"
+	"github.com/merlinapp/datarepo-go"
 	"github.com/merlinapp/datarepo-go/integration_tests/book_gorm_redis/testdomain"
 	"github.com/merlinapp/datarepo-go/integration_tests/model"
+	repogorm "github.com/merlinapp/datarepo-go/repo/gorm"
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/stretchr/testify/suite"
 	"testing"
+	"time"
 )
 
 type GormRedisIntegrationNonUniqueKeyTestSuite struct {
@@ -254,6 +258,164 @@ func (s *GormRedisIntegrationNonUniqueKeyTestSuite) TestGetPartialUpdateAndGetTw
 	})
 }
 
+func (s *GormRedisIntegrationNonUniqueKeyTestSuite) TestGetBooksByDerivedKey() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Retrieve the books of an author with a status by a derived key", s.T(), func() {
+		Convey("Given a cache of books keyed by their author and status, "+
+			"And an author with a completed book and a book in progress", func() {
+			authorStatus := func(value interface{}) (interface{}, bool) {
+				book := value.(*model.Book)
+				return book.AuthorID + ":" + book.Status, true
+			}
+			repo := repogorm.CachedRepositoryBuilderWithDerivedKeys(s.system.DB, &model.Book{}, repogorm.DerivedKeys{
+				"AuthorStatus": {
+					Query: func(db *gorm.DB, keys []interface{}) *gorm.DB {
+						return db.Where("CONCAT(author_id, ':', status) IN (?)", keys)
+					},
+					Key: authorStatus,
+				},
+			}).
+				WithNonUniqueKeyCache(datarepo.NonUniqueKeyCacheDefinition{
+					KeyPrefix:    "as:",
+					KeyFieldName: "AuthorStatus",
+					KeyFunc:      authorStatus,
+					SubKeyFunc: func(value interface{}) (interface{}, bool) {
+						return value.(*model.Book).ID, true
+					},
+					Expiration: 5 * time.Minute,
+				}, s.system.BookCacheStore).
+				BuildCachedRepository()
+			author := testdomain.CreateAuthor(s.system)
+			completed, _ := author.CreateBook(ctx, CompletedStatus)
+			author.CreateBook(ctx, InProgressStatus)
+
+			Convey("When the completed books of the author are fetched", func() {
+				key := author.AuthorId + ":" + CompletedStatus
+				result, err := repo.FindByKey(ctx, "AuthorStatus", key)
+
+				Convey("Then only the completed book should be returned, "+
+					"And it should be cached by the derived key", func() {
+					So(err, ShouldBeNil)
+					var books []*model.Book
+					result.InjectResult(&books)
+					So(books, ShouldHaveLength, 1)
+					So(books[0].ID, ShouldEqual, completed.BookId)
+					var cached []*model.Book
+					found, _ := s.system.BookCacheStore.Get(ctx, "as:"+key, &cached)
+					So(found, ShouldBeTrue)
+					So(cached, ShouldHaveLength, 1)
+				})
+			})
+		})
+	})
+}
+
+func (s *GormRedisIntegrationNonUniqueKeyTestSuite) TestGetBooksByCompositeKey() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Retrieve the books of authors with a status by a composite key", s.T(), func() {
+		Convey("Given a cache of books keyed by their author and status, "+
+			"And an author with a completed book and a book in progress", func() {
+			repo := repogorm.CachedRepositoryBuilder(s.system.DB, &model.Book{}).
+				WithNonUniqueKeyCache(datarepo.NonUniqueKeyCacheDefinition{
+					KeyPrefix:       "ast:",
+					KeyFieldNames:   []string{"AuthorID", "Status"},
+					SubKeyFieldName: "ID",
+					Expiration:      5 * time.Minute,
+				}, s.system.BookCacheStore).
+				BuildCachedRepository()
+			author := testdomain.CreateAuthor(s.system)
+			completed, _ := author.CreateBook(ctx, CompletedStatus)
+			inProgress, _ := author.CreateBook(ctx, InProgressStatus)
+
+			Convey("When the completed books, the books in progress and the empty books of the author are fetched", func() {
+				keys := []datarepo.Tuple{
+					datarepo.NewTuple(author.AuthorId, CompletedStatus),
+					datarepo.NewTuple(author.AuthorId, InProgressStatus),
+					datarepo.NewTuple(author.AuthorId, EmptyStatus),
+				}
+				results, err := repo.FindByKeys(ctx, datarepo.CompositeKeyName("AuthorID", "Status"), keys)
+
+				Convey("Then each key should return its books, "+
+					"And the books should be cached by the encoded composite key", func() {
+					So(err, ShouldBeNil)
+					var books [][]*model.Book
+					datarepo.InjectResults(results, &books)
+					So(books[0], ShouldHaveLength, 1)
+					So(books[0][0].ID, ShouldEqual, completed.BookId)
+					So(books[1], ShouldHaveLength, 1)
+					So(books[1][0].ID, ShouldEqual, inProgress.BookId)
+					So(results[2].IsEmpty(), ShouldBeTrue)
+					var cached []*model.Book
+					found, _ := s.system.BookCacheStore.Get(ctx, "ast:"+keys[0].String(), &cached)
+					So(found, ShouldBeTrue)
+					So(cached, ShouldHaveLength, 1)
+				})
+			})
+		})
+	})
+}
+
+func (s *GormRedisIntegrationNonUniqueKeyTestSuite) TestGetBooksFromNormalizedCache() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Retrieve the books of an author from a normalized cache", s.T(), func() {
+		Convey("Given a normalized cache of books by author that reads the books from the cache by ID, "+
+			"And an author with two books", func() {
+			repo := repogorm.CachedRepositoryBuilder(s.system.DB, &model.Book{}).
+				WithUniqueKeyCache(datarepo.UniqueKeyCacheDefinition{
+					KeyPrefix:    "nb:",
+					KeyFieldName: "ID",
+					Expiration:   5 * time.Minute,
+				}, s.system.BookCacheStore).
+				WithNonUniqueKeyCache(datarepo.NonUniqueKeyCacheDefinition{
+					KeyPrefix:       "na:",
+					KeyFieldName:    "AuthorID",
+					SubKeyFieldName: "ID",
+					Expiration:      5 * time.Minute,
+					Normalized:      true,
+				}, s.system.BookCacheStore).
+				BuildCachedRepository()
+			author := testdomain.CreateAuthor(s.system)
+			book1, _ := author.CreateBook(ctx, EmptyStatus)
+			book2, _ := author.CreateBook(ctx, EmptyStatus)
+
+			Convey("When the books of the author are fetched, one of them is updated and they're fetched again", func() {
+				_, err := repo.FindByKey(ctx, "AuthorID", author.AuthorId)
+				So(err, ShouldBeNil)
+				updated := *book1.DBBook
+				updated.Status = CompletedStatus
+				So(repo.Update(ctx, &updated), ShouldBeNil)
+				result, err := repo.FindByKey(ctx, "AuthorID", author.AuthorId)
+
+				Convey("Then both books should be returned with the update, "+
+					"And the cache entry of the author should hold only the IDs of the books, "+
+					"And the books should be cached by ID", func() {
+					So(err, ShouldBeNil)
+					var books []*model.Book
+					result.InjectResult(&books)
+					So(books, ShouldHaveLength, 2)
+					for _, b := range books {
+						if b.ID == book1.BookId {
+							So(b.Status, ShouldEqual, CompletedStatus)
+						}
+					}
+					var ids []string
+					found, _ := s.system.BookCacheStore.Get(ctx, "na:"+author.AuthorId, &ids)
+					So(found, ShouldBeTrue)
+					So(ids, ShouldHaveLength, 2)
+					So(ids, ShouldContain, book1.BookId)
+					So(ids, ShouldContain, book2.BookId)
+					var cached model.Book
+					found, _ = s.system.BookCacheStore.Get(ctx, "nb:"+book2.BookId, &cached)
+					So(found, ShouldBeTrue)
+				})
+			})
+		})
+	})
+}
+
 func ContainsBooks(books []*model.Book, booksToFind ...*testdomain.Book) {
 	for _, b := range booksToFind {
 		So(books, ShouldContain, b.DBBook)
@@ -1,12 +1,19 @@
 package book_gorm_redis
 
 import (
+	"context"
+	"errors"
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/cachestore/redis"
+	"github.com/merlinapp/datarepo-go/drreflect"
 	"github.com/merlinapp/datarepo-go/integration_tests/book_gorm_redis/testdomain"
 	"github.com/merlinapp/datarepo-go/integration_tests/model"
+	"github.com/merlinapp/datarepo-go/repo/gorm"
 	"github.com/satori/uuid"
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/stretchr/testify/suite"
 	"testing"
+	"time"
 )
 
 type GormRedisIntegrationUniqueKeyTestSuite struct {
@@ -48,6 +55,98 @@ func (s *GormRedisIntegrationUniqueKeyTestSuite) TestGetNonExistentBook() {
 	})
 }
 
+func (s *GormRedisIntegrationUniqueKeyTestSuite) TestGetBooksWithTypedErrors() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Get books handling errors without panicking", s.T(), func() {
+		Convey("Given a book in the system", func() {
+			author := testdomain.CreateAuthor(s.system)
+			book, _ := author.CreateBook(ctx, EmptyStatus)
+
+			Convey("When the book is fetched with a key field that isn't cached", func() {
+				_, err := s.system.BookRepo.FindByKey(ctx, "Title", book.BookId)
+
+				Convey("Then a FetchError should be returned for the key field and id, "+
+					"And it should wrap ErrUnknownKeyField", func() {
+					var fetchErr *datarepo.FetchError
+					So(errors.As(err, &fetchErr), ShouldBeTrue)
+					So(fetchErr.KeyFieldName, ShouldEqual, "Title")
+					So(fetchErr.IDs, ShouldResemble, []interface{}{book.BookId})
+					So(errors.Is(err, datarepo.ErrUnknownKeyField), ShouldBeTrue)
+				})
+			})
+
+			Convey("When the book and a book that doesn't exist are injected", func() {
+				results, err := s.system.BookRepo.FindByKeys(ctx, "ID", []string{book.BookId, uuid.NewV4().String()})
+				So(err, ShouldBeNil)
+				var fetched *model.Book
+				var wrongType *model.Author
+				var books []*model.Book
+				var authors []*model.Author
+
+				Convey("Then the book should be injected, "+
+					"And injecting the missing book should return ErrNotFound, "+
+					"And injecting into variables of other types should return ErrTypeMismatch", func() {
+					So(results[0].Inject(&fetched), ShouldBeNil)
+					So(fetched.ID, ShouldEqual, book.BookId)
+					So(results[1].Inject(&fetched), ShouldEqual, datarepo.ErrNotFound)
+					So(errors.Is(results[0].Inject(&wrongType), datarepo.ErrTypeMismatch), ShouldBeTrue)
+					So(errors.Is(results[0].Inject(nil), datarepo.ErrTypeMismatch), ShouldBeTrue)
+					So(datarepo.InjectResultsE(results, &books), ShouldBeNil)
+					So(books, ShouldHaveLength, 2)
+					So(books[1], ShouldBeNil)
+					So(errors.Is(datarepo.InjectResultsE(results, &authors), datarepo.ErrTypeMismatch), ShouldBeTrue)
+				})
+			})
+		})
+	})
+}
+
+func (s *GormRedisIntegrationUniqueKeyTestSuite) TestTypedBookRepository() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Read and write books through a typed repository", s.T(), func() {
+		Convey("Given a typed repository of books", func() {
+			books, err := datarepo.NewTypedRepository[model.Book](s.system.BookRepo)
+			So(err, ShouldBeNil)
+			author := testdomain.CreateAuthor(s.system)
+			book := &model.Book{ID: uuid.NewV4().String(), AuthorID: author.AuthorId, Status: EmptyStatus}
+
+			Convey("When a book is created and updated, and then read by id and by author", func() {
+				So(books.Create(ctx, book), ShouldBeNil)
+				book.Status = CompletedStatus
+				So(books.Update(ctx, book), ShouldBeNil)
+				fetched, getErr := books.Get(ctx, "ID", book.ID)
+				many, manyErr := books.GetMany(ctx, "ID", []string{book.ID, uuid.NewV4().String()})
+				list, listErr := books.GetList(ctx, "AuthorID", author.AuthorId)
+				_, missingErr := books.Get(ctx, "ID", uuid.NewV4().String())
+
+				Convey("Then the updated book should be returned by every read, "+
+					"And reading a book that doesn't exist should return ErrNotFound", func() {
+					So(getErr, ShouldBeNil)
+					So(fetched.Status, ShouldEqual, CompletedStatus)
+					So(manyErr, ShouldBeNil)
+					So(many, ShouldHaveLength, 2)
+					So(many[0].ID, ShouldEqual, book.ID)
+					So(many[1], ShouldBeNil)
+					So(listErr, ShouldBeNil)
+					So(list, ShouldHaveLength, 1)
+					So(list[0].Status, ShouldEqual, CompletedStatus)
+					So(missingErr, ShouldEqual, datarepo.ErrNotFound)
+				})
+			})
+		})
+
+		Convey("When a typed repository is created for a type that isn't stored by the repository", func() {
+			_, err := datarepo.NewTypedRepository[model.Author](s.system.BookRepo)
+
+			Convey("Then an error should be returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
 func (s *GormRedisIntegrationUniqueKeyTestSuite) TestGetNonExistentBooks() {
 	ctx := s.system.Ctx
 
@@ -222,6 +321,577 @@ func (s *GormRedisIntegrationUniqueKeyTestSuite) TestGetExistentAndNonExistentBo
 	})
 }
 
+func (s *GormRedisIntegrationUniqueKeyTestSuite) TestWarmBooks() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Warm up the cache of books", s.T(), func() {
+		Convey("Given 3 books in the system that aren't cached", func() {
+			author := testdomain.CreateAuthor(s.system)
+			book1, _ := author.CreateBook(ctx, EmptyStatus)
+			book2, _ := author.CreateBook(ctx, CompletedStatus)
+			book3, _ := author.CreateBook(ctx, InProgressStatus)
+			book1.ClearCacheData(ctx)
+			book2.ClearCacheData(ctx)
+			book3.ClearCacheData(ctx)
+
+			Convey("When the cache is warmed up for the first book", func() {
+				err := s.system.BookRepo.Warm(ctx, "ID", []string{book1.BookId})
+
+				Convey("Then the first book should appear in the cache, "+
+					"And the other books shouldn't appear in the cache", func() {
+					So(err, ShouldBeNil)
+					So(book1.VerifyBookIsCached(ctx), ShouldBeTrue)
+					So(book2.VerifyBookIsCached(ctx), ShouldBeFalse)
+					So(book3.VerifyBookIsCached(ctx), ShouldBeFalse)
+				})
+			})
+
+			Convey("When the cache is warmed up for all the books with a page size of 2", func() {
+				pages := 0
+				progress, err := s.system.BookRepo.WarmAll(ctx, "ID", 2,
+					datarepo.WarmUpProgressCallback(func(datarepo.WarmUpProgress) { pages++ }))
+
+				Convey("Then the warm-up should complete after 2 pages, "+
+					"And all the books should appear in the cache", func() {
+					So(err, ShouldBeNil)
+					So(progress.Done, ShouldBeTrue)
+					So(progress.Warmed, ShouldEqual, 3)
+					So(pages, ShouldEqual, 2)
+					So(book1.VerifyBookIsCached(ctx), ShouldBeTrue)
+					So(book2.VerifyBookIsCached(ctx), ShouldBeTrue)
+					So(book3.VerifyBookIsCached(ctx), ShouldBeTrue)
+				})
+			})
+		})
+	})
+}
+
+func (s *GormRedisIntegrationUniqueKeyTestSuite) TestInvalidateBook() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Invalidate a cached book", s.T(), func() {
+		Convey("Given a book exists in the system and in the cache", func() {
+			author := testdomain.CreateAuthor(s.system)
+			book, _ := author.CreateBook(ctx, EmptyStatus)
+
+			Convey("When the book is invalidated", func() {
+				err := s.system.BookRepo.Invalidate(ctx, "ID", book.BookId)
+
+				Convey("Then the book should still appear in the database, "+
+					"And the book shouldn't appear in the cache", func() {
+					So(err, ShouldBeNil)
+					So(book.VerifyBookExists(ctx), ShouldBeTrue)
+					So(book.VerifyBookIsCached(ctx), ShouldBeFalse)
+				})
+			})
+		})
+	})
+}
+
+func (s *GormRedisIntegrationUniqueKeyTestSuite) TestRefreshStaleBook() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Refresh a stale book", s.T(), func() {
+		Convey("Given a book in the cache that was updated directly in the database", func() {
+			author := testdomain.CreateAuthor(s.system)
+			book, _ := author.CreateBook(ctx, EmptyStatus)
+			s.system.DB.Model(book.DBBook).Update("status", CompletedStatus)
+			book.DBBook.Status = CompletedStatus
+
+			Convey("When the book is refreshed", func() {
+				s.system.UniqueKeyDataFetcher.ClearStats()
+				results, err := s.system.BookRepo.Refresh(ctx, "ID", book.BookId)
+
+				Convey("Then the result should contain the updated book, "+
+					"And the database should've been queried, "+
+					"And the updated book should appear in the cache", func() {
+					So(err, ShouldBeNil)
+					var b model.Book
+					results[0].InjectResult(&b)
+					So(b.Status, ShouldEqual, CompletedStatus)
+					So(s.system.UniqueKeyDataFetcher.Reads(), ShouldEqual, 1)
+					So(book.VerifyBookIsCached(ctx), ShouldBeTrue)
+				})
+			})
+		})
+	})
+}
+
+func (s *GormRedisIntegrationUniqueKeyTestSuite) TestGetBookWithReadOptions() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Get a book with read options", s.T(), func() {
+		Convey("Given a book in the system that isn't cached", func() {
+			author := testdomain.CreateAuthor(s.system)
+			book, _ := author.CreateBook(ctx, EmptyStatus)
+			book.ClearCacheData(ctx)
+
+			Convey("When the book is fetched reading from the cache only", func() {
+				s.system.UniqueKeyDataFetcher.ClearStats()
+				result, err := s.system.BookRepo.FindByKey(ctx, "ID", book.BookId, datarepo.CacheOnly())
+
+				Convey("Then the result should be empty, "+
+					"And the database shouldn't have been queried", func() {
+					So(err, ShouldBeNil)
+					So(result.IsEmpty(), ShouldBeTrue)
+					So(s.system.UniqueKeyDataFetcher.Reads(), ShouldEqual, 0)
+				})
+			})
+
+			Convey("When the book is fetched skipping the cache", func() {
+				s.system.BookCacheStore.ClearStats()
+				s.system.UniqueKeyDataFetcher.ClearStats()
+				result, err := s.system.BookRepo.FindByKey(ctx, "ID", book.BookId, datarepo.SkipCache())
+
+				Convey("Then the result should contain the book, "+
+					"And the database should've been queried, "+
+					"And the cache shouldn't have been used", func() {
+					So(err, ShouldBeNil)
+					So(result.IsEmpty(), ShouldBeFalse)
+					So(s.system.UniqueKeyDataFetcher.Reads(), ShouldEqual, 1)
+					So(s.system.BookCacheStore.Miss(), ShouldEqual, 0)
+					So(book.VerifyBookIsCached(ctx), ShouldBeFalse)
+				})
+			})
+		})
+	})
+}
+
+func (s *GormRedisIntegrationUniqueKeyTestSuite) TestGetBooksWithMetadata() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Get books with the metadata of where they were read from", s.T(), func() {
+		Convey("Given a cached book and a book that isn't cached", func() {
+			author := testdomain.CreateAuthor(s.system)
+			cachedBook, _ := author.CreateBook(ctx, EmptyStatus)
+			uncachedBook, _ := author.CreateBook(ctx, EmptyStatus)
+			uncachedBook.ClearCacheData(ctx)
+
+			Convey("When the books are fetched with metadata", func() {
+				results, err := s.system.BookRepo.FindByKeys(ctx, "ID",
+					[]string{cachedBook.BookId, uncachedBook.BookId}, datarepo.WithMetadata())
+
+				Convey("Then the cached book should report that it was read from Redis, when it was stored and its TTL, "+
+					"And the book that wasn't cached should report that it was read from the database", func() {
+					So(err, ShouldBeNil)
+					So(results, ShouldHaveLength, 2)
+
+					metadata, ok := datarepo.GetResultMetadata(results[0])
+					So(ok, ShouldBeTrue)
+					So(metadata.Source, ShouldEqual, redis.Source)
+					So(metadata.StoredAt.IsZero(), ShouldBeFalse)
+					So(metadata.TTL, ShouldBeGreaterThan, 0)
+
+					metadata, ok = datarepo.GetResultMetadata(results[1])
+					So(ok, ShouldBeTrue)
+					So(metadata.Source, ShouldEqual, datarepo.DataFetcherSource)
+					So(metadata.StoredAt.IsZero(), ShouldBeTrue)
+				})
+			})
+
+			Convey("When the book is fetched without metadata", func() {
+				result, err := s.system.BookRepo.FindByKey(ctx, "ID", cachedBook.BookId)
+
+				Convey("Then the result shouldn't carry metadata", func() {
+					So(err, ShouldBeNil)
+					_, ok := datarepo.GetResultMetadata(result)
+					So(ok, ShouldBeFalse)
+				})
+			})
+		})
+	})
+}
+
+func (s *GormRedisIntegrationUniqueKeyTestSuite) TestConsumeBookChangeEvent() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Consume a change event of a book updated outside of the repository", s.T(), func() {
+		Convey("Given a book in the cache that was updated directly in the database", func() {
+			author := testdomain.CreateAuthor(s.system)
+			book, _ := author.CreateBook(ctx, EmptyStatus)
+			s.system.DB.Model(book.DBBook).Update("status", CompletedStatus)
+			book.DBBook.Status = CompletedStatus
+
+			Convey("When the change event of the book is consumed in refresh mode", func() {
+				registry := datarepo.NewRegistry()
+				_ = registry.Register("book", s.system.BookRepo)
+				events := make(chan datarepo.ChangeEvent, 1)
+				events <- datarepo.ChangeEvent{
+					Entity:    "book",
+					Operation: datarepo.OperationUpdate,
+					Keys:      map[string][]interface{}{"ID": {book.BookId}},
+				}
+				close(events)
+				consumer := datarepo.NewChangeEventConsumer(registry, datarepo.NewChannelChangeEventSource(events),
+					datarepo.ChangeEventConsumerOptions{Mode: datarepo.ChangeEventRefresh})
+				err := consumer.Run(ctx)
+
+				Convey("Then the consumer should stop once the source is closed, "+
+					"And the updated book should appear in the cache", func() {
+					So(err, ShouldBeNil)
+					So(book.VerifyBookIsCached(ctx), ShouldBeTrue)
+				})
+			})
+		})
+	})
+}
+
+func (s *GormRedisIntegrationUniqueKeyTestSuite) TestRelayBookOutboxRecord() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Relay the outbox record of a book updated with an outbox data writer", s.T(), func() {
+		Convey("Given a book in the cache that was updated with an outbox data writer", func() {
+			author := testdomain.CreateAuthor(s.system)
+			book, _ := author.CreateBook(ctx, EmptyStatus)
+			writer := gorm.NewOutboxDataWriter(s.system.DB, &model.Book{}, gorm.OutboxOptions{Entity: "book"})
+			updatedBook := &model.Book{ID: book.BookId, AuthorID: author.AuthorId, Status: CompletedStatus}
+			err := writer.Update(ctx, updatedBook)
+			So(err, ShouldBeNil)
+
+			Convey("When the outbox relay runs", func() {
+				registry := datarepo.NewRegistry()
+				_ = registry.Register("book", s.system.BookRepo)
+				relay := gorm.NewOutboxRelay(s.system.DB, registry, gorm.OutboxRelayOptions{
+					PollInterval: 10 * time.Millisecond,
+				})
+				runCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+				defer cancel()
+				_ = relay.Run(runCtx)
+
+				Convey("Then the book should be evicted from the cache, "+
+					"And the outbox record should be marked as processed", func() {
+					So(book.VerifyBookIsCached(ctx), ShouldBeFalse)
+					var pending int
+					s.system.DB.Table(gorm.DefaultOutboxTable).Where("processed_at IS NULL").Count(&pending)
+					So(pending, ShouldEqual, 0)
+				})
+			})
+		})
+	})
+}
+
+func (s *GormRedisIntegrationUniqueKeyTestSuite) TestAuditStaleBook() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Audit the consistency of a book updated outside of the repository", s.T(), func() {
+		Convey("Given a book in the cache that was updated directly in the database", func() {
+			author := testdomain.CreateAuthor(s.system)
+			book, _ := author.CreateBook(ctx, EmptyStatus)
+			s.system.DB.Model(book.DBBook).Update("status", CompletedStatus)
+			book.DBBook.Status = CompletedStatus
+
+			Convey("When the cache of the book is audited with repair", func() {
+				registry := datarepo.NewRegistry()
+				_ = registry.Register("book", s.system.BookRepo)
+				auditor := datarepo.NewConsistencyAuditor(registry)
+				report, err := auditor.Audit(ctx, "book", "ID", datarepo.AuditOptions{
+					Keys:   []interface{}{book.BookId},
+					Repair: true,
+				})
+
+				Convey("Then the status of the book should be reported as different, "+
+					"And the updated book should appear in the cache, "+
+					"And the drift counts should include the mismatch", func() {
+					So(err, ShouldBeNil)
+					So(report.Mismatches, ShouldEqual, 1)
+					So(report.Repaired, ShouldEqual, 1)
+					So(report.Diffs[0].Fields, ShouldResemble, []datarepo.FieldDiff{
+						{Field: "Status", Cached: EmptyStatus, Stored: CompletedStatus},
+					})
+					So(book.VerifyBookIsCached(ctx), ShouldBeTrue)
+					So(auditor.Stats()["book"].Mismatches, ShouldEqual, 1)
+				})
+			})
+		})
+	})
+}
+
+func (s *GormRedisIntegrationUniqueKeyTestSuite) TestReadRepairStaleBook() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Repair a stale book when it's read from the cache", s.T(), func() {
+		Convey("Given a repository that verifies every cache hit, "+
+			"And a book in the cache that was updated directly in the database", func() {
+			drifts := make(chan datarepo.DriftEvent, 1)
+			repo := datarepo.CachedRepositoryBuilder(&model.Book{}).
+				WithUniqueKeyDataFetcher(s.system.UniqueKeyDataFetcher).
+				WithNonUniqueKeyDataFetcher(s.system.NonUniqueKeyDataFetcher).
+				WithDataWriter(gorm.NewDataWriter(s.system.DB, &model.Book{})).
+				WithUniqueKeyCache(idCache, s.system.BookCacheStore).
+				WithReadRepair(datarepo.ReadRepairOptions{
+					SampleRate: 1,
+					OnDrift: func(event datarepo.DriftEvent) {
+						drifts <- event
+					},
+				}).
+				BuildCachedRepository()
+			author := testdomain.CreateAuthor(s.system)
+			book, _ := author.CreateBook(ctx, EmptyStatus)
+			s.system.DB.Model(book.DBBook).Update("status", CompletedStatus)
+			book.DBBook.Status = CompletedStatus
+
+			Convey("When the book is fetched from the cache", func() {
+				_, err := repo.FindByKey(ctx, "ID", book.BookId)
+				shutdownErr := repo.(datarepo.Shutdowner).Shutdown(ctx)
+
+				Convey("Then a drift event should be emitted for the book, "+
+					"And the updated book should appear in the cache", func() {
+					So(err, ShouldBeNil)
+					So(shutdownErr, ShouldBeNil)
+					So(len(drifts), ShouldEqual, 1)
+					So((<-drifts).Key, ShouldEqual, book.BookId)
+					So(book.VerifyBookIsCached(ctx), ShouldBeTrue)
+				})
+			})
+		})
+	})
+}
+
+func (s *GormRedisIntegrationUniqueKeyTestSuite) TestFlushBookCache() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Flush the ID cache of books", s.T(), func() {
+		Convey("Given two books in the cache", func() {
+			author := testdomain.CreateAuthor(s.system)
+			book1, _ := author.CreateBook(ctx, EmptyStatus)
+			book2, _ := author.CreateBook(ctx, CompletedStatus)
+
+			Convey("When the ID cache is flushed", func() {
+				err := s.system.BookRepo.Flush(ctx, "ID")
+
+				Convey("Then no book should appear in the cache", func() {
+					So(err, ShouldBeNil)
+					So(book1.VerifyBookIsCached(ctx), ShouldBeFalse)
+					So(book2.VerifyBookIsCached(ctx), ShouldBeFalse)
+				})
+			})
+		})
+	})
+}
+
+func (s *GormRedisIntegrationUniqueKeyTestSuite) TestGetBookCachedWithOldSchema() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Get a book cached with a previous version of the struct", s.T(), func() {
+		Convey("Given a book cached with fewer fields than the current struct", func() {
+			author := testdomain.CreateAuthor(s.system)
+			book, _ := author.CreateBook(ctx, CompletedStatus)
+			oldBook := &struct {
+				ID       string `json:"id"`
+				AuthorID string `json:"authorId"`
+			}{ID: book.BookId, AuthorID: author.AuthorId}
+			s.system.BookCacheStore.Set(ctx, "b:"+book.BookId, oldBook, time.Hour)
+
+			Convey("When the book is fetched", func() {
+				s.system.UniqueKeyDataFetcher.ClearStats()
+				result, err := s.system.BookRepo.FindByKey(ctx, "ID", book.BookId)
+
+				Convey("Then the cached entry should be treated as a miss, "+
+					"And the complete book should be fetched from the database and cached", func() {
+					So(err, ShouldBeNil)
+					var fetched *model.Book
+					result.InjectResult(&fetched)
+					So(fetched.Status, ShouldEqual, CompletedStatus)
+					So(s.system.UniqueKeyDataFetcher.Reads(), ShouldEqual, 1)
+					So(book.VerifyBookIsCached(ctx), ShouldBeTrue)
+				})
+			})
+		})
+	})
+}
+
+func (s *GormRedisIntegrationUniqueKeyTestSuite) TestGetBookByNullableKey() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Get books by a nullable key field", s.T(), func() {
+		Convey("Given a repository with a cache of books by ISBN, which is a pointer field", func() {
+			repo := gorm.CachedRepositoryBuilder(s.system.DB, &model.Book{}).
+				WithUniqueKeyCache(datarepo.UniqueKeyCacheDefinition{
+					KeyPrefix:    "isbn:",
+					KeyFieldName: "ISBN",
+					Expiration:   5 * time.Minute,
+				}, s.system.BookCacheStore).
+				BuildCachedRepository()
+			author := testdomain.CreateAuthor(s.system)
+			isbn := uuid.NewV4().String()
+			book := &model.Book{ID: uuid.NewV4().String(), AuthorID: author.AuthorId, ISBN: &isbn}
+			bookWithoutISBN := &model.Book{ID: uuid.NewV4().String(), AuthorID: author.AuthorId}
+
+			Convey("When a book with an ISBN and a book without one are created, and the first one is fetched by ISBN", func() {
+				So(repo.Create(ctx, book), ShouldBeNil)
+				So(repo.Create(ctx, bookWithoutISBN), ShouldBeNil)
+				result, err := repo.FindByKey(ctx, "ISBN", isbn)
+
+				Convey("Then the book should be returned, "+
+					"And it should be cached by the value of its ISBN, "+
+					"And the book without an ISBN shouldn't be cached", func() {
+					So(err, ShouldBeNil)
+					var fetched *model.Book
+					result.InjectResult(&fetched)
+					So(fetched.ID, ShouldEqual, book.ID)
+					var cached model.Book
+					found, _ := s.system.BookCacheStore.Get(ctx, "isbn:"+isbn, &cached)
+					So(found, ShouldBeTrue)
+					So(cached.ID, ShouldEqual, book.ID)
+					found, _ = s.system.BookCacheStore.Get(ctx, "isbn:", &cached)
+					So(found, ShouldBeFalse)
+				})
+			})
+		})
+	})
+}
+
+func (s *GormRedisIntegrationUniqueKeyTestSuite) TestCrossPopulateBookCaches() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Populate every cache of books when a book is fetched by ID", s.T(), func() {
+		Convey("Given a repository that cross-populates its caches of books by ID and by ISBN, "+
+			"And a book that was created directly in the database", func() {
+			repo := gorm.CachedRepositoryBuilder(s.system.DB, &model.Book{}).
+				WithUniqueKeyCache(idCache, s.system.BookCacheStore).
+				WithUniqueKeyCache(datarepo.UniqueKeyCacheDefinition{
+					KeyPrefix:    "isbn:",
+					KeyFieldName: "ISBN",
+					Expiration:   5 * time.Minute,
+				}, s.system.BookCacheStore).
+				CrossPopulateCaches(true).
+				BuildCachedRepository()
+			author := testdomain.CreateAuthor(s.system)
+			isbn := uuid.NewV4().String()
+			book := &model.Book{ID: uuid.NewV4().String(), AuthorID: author.AuthorId, ISBN: &isbn}
+			So(s.system.DB.Create(book).Error, ShouldBeNil)
+
+			Convey("When the book is fetched by ID", func() {
+				_, err := repo.FindByKey(ctx, "ID", book.ID)
+
+				Convey("Then the book should be cached by its ISBN too", func() {
+					So(err, ShouldBeNil)
+					var cached model.Book
+					found, _ := s.system.BookCacheStore.Get(ctx, "isbn:"+isbn, &cached)
+					So(found, ShouldBeTrue)
+					So(cached.ID, ShouldEqual, book.ID)
+				})
+			})
+		})
+	})
+}
+
+func (s *GormRedisIntegrationUniqueKeyTestSuite) TestGetBookBySecondaryKey() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Get books by a secondary key that resolves through the cache by ID", s.T(), func() {
+		Convey("Given a repository whose cache of books by ISBN stores only the IDs of the books, "+
+			"And a book with an ISBN", func() {
+			repo := gorm.CachedRepositoryBuilder(s.system.DB, &model.Book{}).
+				WithUniqueKeyCache(datarepo.UniqueKeyCacheDefinition{
+					KeyPrefix:    "ib:",
+					KeyFieldName: "ID",
+					Expiration:   5 * time.Minute,
+				}, s.system.BookCacheStore).
+				WithUniqueKeyCache(datarepo.UniqueKeyCacheDefinition{
+					KeyPrefix:           "ii:",
+					KeyFieldName:        "ISBN",
+					PrimaryKeyFieldName: "ID",
+					Expiration:          5 * time.Minute,
+				}, s.system.BookCacheStore).
+				BuildCachedRepository()
+			author := testdomain.CreateAuthor(s.system)
+			isbn := uuid.NewV4().String()
+			book := &model.Book{ID: uuid.NewV4().String(), AuthorID: author.AuthorId, ISBN: &isbn}
+			So(repo.Create(ctx, book), ShouldBeNil)
+
+			Convey("When the ISBN of the book is changed and the book is fetched by its new ISBN", func() {
+				newISBN := uuid.NewV4().String()
+				updated := *book
+				updated.ISBN = &newISBN
+				So(repo.Update(ctx, &updated), ShouldBeNil)
+				result, err := repo.FindByKey(ctx, "ISBN", newISBN)
+
+				Convey("Then the book should be returned, "+
+					"And the entry of the new ISBN should hold only the ID of the book, "+
+					"And the entry of the previous ISBN should be removed", func() {
+					So(err, ShouldBeNil)
+					var fetched *model.Book
+					result.InjectResult(&fetched)
+					So(fetched.ID, ShouldEqual, book.ID)
+					var id string
+					found, _ := s.system.BookCacheStore.Get(ctx, "ii:"+newISBN, &id)
+					So(found, ShouldBeTrue)
+					So(id, ShouldEqual, book.ID)
+					found, _ = s.system.BookCacheStore.Get(ctx, "ii:"+isbn, &id)
+					So(found, ShouldBeFalse)
+				})
+			})
+		})
+	})
+}
+
+func (s *GormRedisIntegrationUniqueKeyTestSuite) TestGetBooksByUniqueCompositeKey() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Retrieve the book of an author with a status by a unique composite key", s.T(), func() {
+		Convey("Given a unique cache of books keyed by their author and status, "+
+			"And an author with a completed book and a book in progress", func() {
+			repo := gorm.CachedRepositoryBuilder(s.system.DB, &model.Book{}).
+				WithUniqueKeyCache(datarepo.UniqueKeyCacheDefinition{
+					KeyPrefix:     "ust:",
+					KeyFieldNames: []string{"AuthorID", "Status"},
+					Expiration:    5 * time.Minute,
+				}, s.system.BookCacheStore).
+				BuildCachedRepository()
+			author := testdomain.CreateAuthor(s.system)
+			completed, _ := author.CreateBook(ctx, CompletedStatus)
+			inProgress, _ := author.CreateBook(ctx, InProgressStatus)
+
+			Convey("When the completed book, the book in progress and the empty book of the author are fetched", func() {
+				keys := []datarepo.Tuple{
+					datarepo.NewTuple(author.AuthorId, CompletedStatus),
+					datarepo.NewTuple(author.AuthorId, InProgressStatus),
+					datarepo.NewTuple(author.AuthorId, EmptyStatus),
+				}
+				results, err := repo.FindByKeys(ctx, datarepo.CompositeKeyName("AuthorID", "Status"), keys)
+
+				Convey("Then each key should return its book, "+
+					"And the books should be cached by the encoded composite key", func() {
+					So(err, ShouldBeNil)
+					So(results[0].StoredValue().(*model.Book).ID, ShouldEqual, completed.BookId)
+					So(results[1].StoredValue().(*model.Book).ID, ShouldEqual, inProgress.BookId)
+					So(results[2].IsEmpty(), ShouldBeTrue)
+					var cached model.Book
+					found, _ := s.system.BookCacheStore.Get(ctx, "ust:"+keys[0].String(), &cached)
+					So(found, ShouldBeTrue)
+					So(cached.ID, ShouldEqual, completed.BookId)
+				})
+			})
+		})
+	})
+}
+
+func (s *GormRedisIntegrationUniqueKeyTestSuite) TestFindNoCompositeKeys() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Fetch an empty list of composite keys", s.T(), func() {
+		Convey("Given the reflection based and the typed data fetchers of books", func() {
+			fetchers := []datarepo.DataFetcher{
+				gorm.NewUniqueKeyDataFetcher(s.system.DB, &model.Book{}),
+				gorm.NewNonUniqueKeyDataFetcher(s.system.DB, &model.Book{}),
+				gorm.NewTypedUniqueKeyDataFetcher(s.system.DB, drreflect.FieldAccessors[model.Book]{}),
+				gorm.NewTypedNonUniqueKeyDataFetcher(s.system.DB, drreflect.FieldAccessors[model.Book]{}),
+			}
+
+			Convey("When no composite keys are fetched", func() {
+				Convey("Then every fetcher should return an empty result without querying the database", func() {
+					for _, fetcher := range fetchers {
+						results, err := fetcher.FindByKeys(ctx, datarepo.CompositeKeyName("AuthorID", "Status"), []interface{}{})
+						So(err, ShouldBeNil)
+						So(results, ShouldBeEmpty)
+					}
+				})
+			})
+		})
+	})
+}
+
 func (s *GormRedisIntegrationUniqueKeyTestSuite) SetupTest() {
 	s.system = startSystemForIntegrationTests()
 	prepareTestDB()
@@ -0,0 +1,237 @@
+package book_memory
+
+import (
+	"errors"
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/integration_tests/model"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type MemoryBuilderTestSuite struct {
+	suite.Suite
+	system *testSystem
+}
+
+func TestMemoryBuilderTestSuite(t *testing.T) {
+	suite.Run(t, new(MemoryBuilderTestSuite))
+}
+
+// A book with a field that can't be used as a key
+type taggedBook struct {
+	ID   string   `json:"id"`
+	Tags []string `json:"tags"`
+}
+
+// Returns the messages of the errors of a *BuildError, or nil if the error isn't one
+func buildErrorMessages(err error) []string {
+	var buildErr *datarepo.BuildError
+	if !errors.As(err, &buildErr) {
+		return nil
+	}
+	messages := make([]string, len(buildErr.Errors))
+	for i, e := range buildErr.Errors {
+		messages[i] = e.Error()
+	}
+	return messages
+}
+
+func (s *MemoryBuilderTestSuite) TestBuildValidConfiguration() {
+	Convey("Scenario: Build a repository with a valid configuration", s.T(), func() {
+		Convey("Given a builder with a data fetcher, a data writer and caches in the same store with distinct prefixes", func() {
+			builder := s.system.builder().
+				WithUniqueKeyCache(idCache, s.system.CacheStore).
+				WithNonUniqueKeyCache(authorIdCache, s.system.CacheStore)
+
+			Convey("When the repository is built", func() {
+				repo, err := builder.Build()
+
+				Convey("Then the repository should be created without errors", func() {
+					So(err, ShouldBeNil)
+					So(repo, ShouldNotBeNil)
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryBuilderTestSuite) TestBuildWithoutDataWriter() {
+	Convey("Scenario: Build repositories without a data writer", s.T(), func() {
+		Convey("Given a builder with a data fetcher and a cache but no data writer", func() {
+			builder := datarepo.CachedRepositoryBuilder(&model.Book{}).
+				WithUniqueKeyDataFetcher(&uniqueBookFetcher{s.system.Books}).
+				WithUniqueKeyCache(idCache, s.system.CacheStore)
+
+			Convey("When a read-write repository is built", func() {
+				_, err := builder.Build()
+
+				Convey("Then the missing data writer should be reported in a BuildError", func() {
+					So(buildErrorMessages(err), ShouldResemble, []string{
+						"a DataWriter needs to be provided when building a new read-write cached repository",
+					})
+				})
+			})
+
+			Convey("When a read-only repository is built", func() {
+				repo, err := builder.BuildRO()
+
+				Convey("Then the repository should be created without errors", func() {
+					So(err, ShouldBeNil)
+					So(repo, ShouldNotBeNil)
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryBuilderTestSuite) TestBuildAggregatesErrors() {
+	Convey("Scenario: Build a repository with several configuration problems", s.T(), func() {
+		Convey("Given a builder without data fetchers, "+
+			"And a cache without a store, "+
+			"And a cache for a field that doesn't exist, "+
+			"And two caches in the same store whose prefixes collide, "+
+			"And an invalid write policy and read-repair sample rate", func() {
+			noStoreCache := idCache
+			unknownFieldCache := datarepo.UniqueKeyCacheDefinition{KeyPrefix: "x:", KeyFieldName: "Title"}
+			collidingCache := authorIdCache
+			collidingCache.KeyPrefix = "x:a:"
+			collidingCache.WritePolicy = datarepo.WritePolicy(99)
+			builder := datarepo.CachedRepositoryBuilder(&model.Book{}).
+				WithDataWriter(s.system.Books).
+				WithUniqueKeyCache(noStoreCache, nil).
+				WithUniqueKeyCache(unknownFieldCache, s.system.CacheStore).
+				WithNonUniqueKeyCache(collidingCache, s.system.CacheStore).
+				WithReadRepair(datarepo.ReadRepairOptions{SampleRate: 2})
+
+			Convey("When the repository is built", func() {
+				repo, err := builder.Build()
+
+				Convey("Then every problem should be reported in a single BuildError", func() {
+					So(repo, ShouldBeNil)
+					messages := buildErrorMessages(err)
+					So(messages, ShouldResemble, []string{
+						"a UniqueKeyDataFetcher needs to be provided when building a new cached repository with unique key caches defined",
+						"a NonUniqueKeyDataFetcher needs to be provided when building a new cached repository with non-unique key caches defined",
+						"the read-repair sample rate must be between 0 and 1",
+						"a CacheStore needs to be provided for the cache with prefix: b:",
+						"invalid write policy WritePolicy(99) for the cache with prefix: x:a:",
+						"field Title is not defined in type model.Book",
+						"key prefixes 'x:' and 'x:a:' collide in the same cache store",
+					})
+					So(err.Error(), ShouldStartWith, "invalid repository configuration: ")
+					for _, message := range messages {
+						So(err.Error(), ShouldContainSubstring, message)
+					}
+				})
+			})
+
+			Convey("When the repository is built with BuildCachedRepository", func() {
+				var recovered interface{}
+				func() {
+					defer func() { recovered = recover() }()
+					builder.BuildCachedRepository()
+				}()
+
+				Convey("Then it should panic with the BuildError", func() {
+					err, _ := recovered.(error)
+					So(buildErrorMessages(err), ShouldHaveLength, 7)
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryBuilderTestSuite) TestBuildReportsErrorsOfBuilderMethods() {
+	Convey("Scenario: Build a repository whose caches were defined with errors", s.T(), func() {
+		Convey("Given two caches defined for the same field, "+
+			"And a cache that defines both a KeyFieldName and KeyFieldNames", func() {
+			duplicateCache := authorIdCache
+			duplicateCache.KeyPrefix = "d:"
+			duplicateCache.KeyFieldName = "ID"
+			ambiguousCache := datarepo.UniqueKeyCacheDefinition{
+				KeyPrefix:     "s:",
+				KeyFieldName:  "Status",
+				KeyFieldNames: []string{"AuthorID", "Status"},
+			}
+			builder := s.system.builder().
+				WithUniqueKeyCache(idCache, s.system.CacheStore).
+				WithNonUniqueKeyCache(duplicateCache, s.system.CacheStore).
+				WithUniqueKeyCache(ambiguousCache, s.system.CacheStore)
+
+			Convey("When the repository is built", func() {
+				_, err := builder.Build()
+
+				Convey("Then the errors found while defining the caches should be reported", func() {
+					So(buildErrorMessages(err), ShouldResemble, []string{
+						"a cache has already been defined with the same field name: ID",
+						"a cache can't define both a KeyFieldName and KeyFieldNames: Status",
+					})
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryBuilderTestSuite) TestBuildValidatesKeyFields() {
+	Convey("Scenario: Build repositories whose caches use fields that can't be keys", s.T(), func() {
+		Convey("Given a cache keyed by a slice field", func() {
+			builder := datarepo.CachedRepositoryBuilder(&taggedBook{}).
+				WithUniqueKeyDataFetcher(&uniqueBookFetcher{s.system.Books}).
+				WithUniqueKeyCache(datarepo.UniqueKeyCacheDefinition{KeyPrefix: "t:", KeyFieldName: "Tags"}, s.system.CacheStore)
+
+			Convey("When the repository is built", func() {
+				_, err := builder.BuildRO()
+
+				Convey("Then the field should be rejected as it isn't comparable", func() {
+					So(buildErrorMessages(err), ShouldResemble, []string{
+						"field Tags of type []string can't be used as a key as it isn't comparable",
+					})
+				})
+			})
+		})
+
+		Convey("Given a cache whose key is derived with a KeyFunc", func() {
+			derivedCache := datarepo.UniqueKeyCacheDefinition{
+				KeyPrefix:    "t:",
+				KeyFieldName: "FirstTag",
+				KeyFunc: func(value interface{}) (interface{}, bool) {
+					tags := value.(*taggedBook).Tags
+					if len(tags) == 0 {
+						return nil, false
+					}
+					return tags[0], true
+				},
+			}
+			builder := datarepo.CachedRepositoryBuilder(&taggedBook{}).
+				WithUniqueKeyDataFetcher(&uniqueBookFetcher{s.system.Books}).
+				WithUniqueKeyCache(derivedCache, s.system.CacheStore)
+
+			Convey("When the repository is built", func() {
+				_, err := builder.BuildRO()
+
+				Convey("Then its name shouldn't need to be a field of the struct", func() {
+					So(err, ShouldBeNil)
+				})
+			})
+		})
+
+		Convey("Given a data type that isn't a struct", func() {
+			builder := datarepo.CachedRepositoryBuilder("book").
+				WithUniqueKeyDataFetcher(&uniqueBookFetcher{s.system.Books}).
+				WithUniqueKeyCache(idCache, s.system.CacheStore)
+
+			Convey("When the repository is built", func() {
+				_, err := builder.BuildRO()
+
+				Convey("Then the data type should be rejected", func() {
+					So(buildErrorMessages(err), ShouldHaveLength, 1)
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryBuilderTestSuite) SetupTest() {
+	s.system = startSystemForTests()
+}
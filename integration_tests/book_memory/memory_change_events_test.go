@@ -0,0 +1,126 @@
+package book_memory
+
+import (
+	"encoding/json"
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/integration_tests/model"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type MemoryChangeEventsTestSuite struct {
+	suite.Suite
+	system *testSystem
+}
+
+func TestMemoryChangeEventsTestSuite(t *testing.T) {
+	suite.Run(t, new(MemoryChangeEventsTestSuite))
+}
+
+var authorStatusCache = datarepo.UniqueKeyCacheDefinition{
+	KeyPrefix:     "as:",
+	KeyFieldNames: []string{"AuthorID", "Status"},
+	Expiration:    5 * time.Minute,
+}
+
+func (s *MemoryChangeEventsTestSuite) TestApplyEventWithEncodedValues() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Apply a change event whose values are JSON encoded", s.T(), func() {
+		Convey("Given a registered repository of books with a cache by ID and a composite cache by author and status, "+
+			"And a book cached in both caches", func() {
+			repo := s.system.builder().
+				WithUniqueKeyCache(idCache, s.system.CacheStore).
+				WithUniqueKeyCache(authorStatusCache, s.system.CacheStore).
+				BuildCachedRepository()
+			registry := datarepo.NewRegistry()
+			So(registry.Register("book", repo), ShouldBeNil)
+			book := &model.Book{ID: "7", AuthorID: "author-1", Status: "draft"}
+			So(repo.Create(ctx, book), ShouldBeNil)
+			consumer := datarepo.NewChangeEventConsumer(registry, nil, datarepo.ChangeEventConsumerOptions{})
+			compositeKeyName := datarepo.CompositeKeyName("AuthorID", "Status")
+			compositeKey := datarepo.NewTuple("author-1", "draft")
+
+			Convey("When an event with only the encoded new value of the book is applied", func() {
+				newValue, _ := json.Marshal(&model.Book{ID: "7", AuthorID: "author-1", Status: "draft"})
+				err := consumer.Apply(ctx, datarepo.ChangeEvent{
+					Entity:    "book",
+					Operation: datarepo.OperationUpdate,
+					NewValue:  json.RawMessage(newValue),
+				})
+
+				Convey("Then the entries of the composite cache should be derived from the value and evicted", func() {
+					So(err, ShouldBeNil)
+					result, err := repo.FindByKey(ctx, compositeKeyName, compositeKey, datarepo.CacheOnly())
+					So(err, ShouldBeNil)
+					So(result.IsEmpty(), ShouldBeTrue)
+					result, err = repo.FindByKey(ctx, "ID", "7", datarepo.CacheOnly())
+					So(err, ShouldBeNil)
+					So(result.IsEmpty(), ShouldBeTrue)
+				})
+			})
+
+			Convey("When an event with keys decoded from JSON as numbers and nil keys is applied", func() {
+				err := consumer.Apply(ctx, datarepo.ChangeEvent{
+					Entity:    "book",
+					Operation: datarepo.OperationUpdate,
+					Keys:      map[string][]interface{}{"ID": {nil, json.Number("7")}},
+				})
+
+				Convey("Then the number should be converted to the type of the key field, "+
+					"And the nil key should be ignored", func() {
+					So(err, ShouldBeNil)
+					result, err := repo.FindByKey(ctx, "ID", "7", datarepo.CacheOnly())
+					So(err, ShouldBeNil)
+					So(result.IsEmpty(), ShouldBeTrue)
+					result, err = repo.FindByKey(ctx, compositeKeyName, compositeKey, datarepo.CacheOnly())
+					So(err, ShouldBeNil)
+					So(result.IsEmpty(), ShouldBeFalse)
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryChangeEventsTestSuite) TestApplyEventWithRepeatedNonComparableKeys() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Apply a change event whose keys can't be compared with ==", s.T(), func() {
+		Convey("Given a registered repository of books with a cache by ID, "+
+			"And a cached book", func() {
+			repo := s.system.builder().
+				WithUniqueKeyCache(idCache, s.system.CacheStore).
+				BuildCachedRepository()
+			registry := datarepo.NewRegistry()
+			So(registry.Register("book", repo), ShouldBeNil)
+			So(repo.Create(ctx, &model.Book{ID: "7", AuthorID: "author-1", Status: "draft"}), ShouldBeNil)
+			consumer := datarepo.NewChangeEventConsumer(registry, nil, datarepo.ChangeEventConsumerOptions{})
+
+			Convey("When an event with the ID repeated as byte slices is applied", func() {
+				var err error
+				apply := func() {
+					err = consumer.Apply(ctx, datarepo.ChangeEvent{
+						Entity:    "book",
+						Operation: datarepo.OperationUpdate,
+						Keys:      map[string][]interface{}{"ID": {[]byte("7"), []byte("7")}},
+					})
+				}
+
+				Convey("Then the event should be applied without panicking, "+
+					"And the book should be evicted", func() {
+					So(apply, ShouldNotPanic)
+					So(err, ShouldBeNil)
+					result, err := repo.FindByKey(ctx, "ID", "7", datarepo.CacheOnly())
+					So(err, ShouldBeNil)
+					So(result.IsEmpty(), ShouldBeTrue)
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryChangeEventsTestSuite) SetupTest() {
+	s.system = startSystemForTests()
+}
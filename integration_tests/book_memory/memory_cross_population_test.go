@@ -0,0 +1,146 @@
+package book_memory
+
+import (
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/cachestore/memory"
+	"github.com/merlinapp/datarepo-go/cachestore/stats"
+	"github.com/merlinapp/datarepo-go/integration_tests/model"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type MemoryCrossPopulationTestSuite struct {
+	suite.Suite
+	system *testSystem
+}
+
+func TestMemoryCrossPopulationTestSuite(t *testing.T) {
+	suite.Run(t, new(MemoryCrossPopulationTestSuite))
+}
+
+var plainIsbnCache = datarepo.UniqueKeyCacheDefinition{
+	KeyPrefix:    "pi:",
+	KeyFieldName: "ISBN",
+	Expiration:   5 * time.Minute,
+}
+
+func (s *MemoryCrossPopulationTestSuite) TestCrossPopulateUniqueKeyCaches() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Populate the cache of books by ISBN when a book is fetched by ID", s.T(), func() {
+		Convey("Given a repository that cross-populates its caches of books by ID and by ISBN, "+
+			"And two books that aren't cached by ID", func() {
+			repo := s.system.builder().
+				WithUniqueKeyCache(idCache, s.system.CacheStore).
+				WithUniqueKeyCache(plainIsbnCache, s.system.CacheStore).
+				CrossPopulateCaches(true).
+				BuildCachedRepository()
+			isbn1, isbn2 := "isbn-1", "isbn-2"
+			So(s.system.Books.store(&model.Book{ID: "book-1", AuthorID: "author-1", ISBN: &isbn1}), ShouldBeNil)
+			So(s.system.Books.store(&model.Book{ID: "book-2", AuthorID: "author-1", ISBN: &isbn2, Status: "draft"}), ShouldBeNil)
+
+			Convey("When a book that isn't cached by ISBN is fetched by ID", func() {
+				_, err := repo.FindByKey(ctx, "ID", "book-1")
+
+				Convey("Then the book should be cached by its ISBN before the read returns", func() {
+					So(err, ShouldBeNil)
+					var cached model.Book
+					found, _ := s.system.CacheStore.Get(ctx, "pi:isbn-1", &cached)
+					So(found, ShouldBeTrue)
+					So(cached.ID, ShouldEqual, "book-1")
+				})
+			})
+
+			Convey("When a book whose entry by ISBN holds a value written after the fetch is fetched by ID", func() {
+				s.system.CacheStore.Set(ctx, "pi:isbn-2",
+					&model.Book{ID: "book-2", AuthorID: "author-1", ISBN: &isbn2, Status: "published"}, time.Minute)
+				_, err := repo.FindByKey(ctx, "ID", "book-2")
+
+				Convey("Then the entry by ISBN should keep the value written after the fetch", func() {
+					So(err, ShouldBeNil)
+					var cached model.Book
+					found, _ := s.system.CacheStore.Get(ctx, "pi:isbn-2", &cached)
+					So(found, ShouldBeTrue)
+					So(cached.Status, ShouldEqual, "published")
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryCrossPopulationTestSuite) TestCrossPopulateNonUniqueKeyCaches() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Populate the cached lists of books by author when books are fetched by ID", s.T(), func() {
+		Convey("Given a repository that cross-populates its caches of books by ID and by author, "+
+			"And a cached list of books of an author holding a value written after the fetch, "+
+			"And another book of the author that isn't in the list", func() {
+			repo := s.system.builder().
+				WithUniqueKeyCache(idCache, s.system.CacheStore).
+				WithNonUniqueKeyCache(authorIdCache, s.system.CacheStore).
+				CrossPopulateCaches(true).
+				BuildCachedRepository()
+			So(s.system.Books.store(&model.Book{ID: "book-1", AuthorID: "author-1", Status: "draft"}), ShouldBeNil)
+			So(s.system.Books.store(&model.Book{ID: "book-2", AuthorID: "author-1", Status: "draft"}), ShouldBeNil)
+			s.system.CacheStore.Set(ctx, "a:author-1",
+				[]model.Book{{ID: "book-1", AuthorID: "author-1", Status: "published"}}, time.Minute)
+
+			Convey("When both books are fetched by ID", func() {
+				_, err := repo.FindByKeys(ctx, "ID", []string{"book-1", "book-2"})
+
+				Convey("Then the book in the list should keep the value written after the fetch, "+
+					"And the other book should be added to the list", func() {
+					So(err, ShouldBeNil)
+					var books []model.Book
+					found, _ := s.system.CacheStore.Get(ctx, "a:author-1", &books)
+					So(found, ShouldBeTrue)
+					So(books, ShouldHaveLength, 2)
+					So(books[0].Status, ShouldEqual, "published")
+					So(books[1].ID, ShouldEqual, "book-2")
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryCrossPopulationTestSuite) TestCrossPopulateFromNormalizedCache() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Populate the caches of books when the books of an author are fetched from a normalized cache", s.T(), func() {
+		Convey("Given a repository that cross-populates a normalized cache by author, a cache by ID in its own store "+
+			"and a cache by ISBN, "+
+			"And an author with two books that aren't cached", func() {
+			idStore := stats.NewStatsCacheStore(memory.NewFreeCacheInMemoryStore(1024 * 1024))
+			repo := s.system.builder().
+				WithUniqueKeyCache(idCache, idStore).
+				WithUniqueKeyCache(plainIsbnCache, s.system.CacheStore).
+				WithNonUniqueKeyCache(normalizedAuthorIdCache, s.system.CacheStore).
+				CrossPopulateCaches(true).
+				BuildCachedRepository()
+			isbn1, isbn2 := "isbn-1", "isbn-2"
+			So(s.system.Books.store(&model.Book{ID: "book-1", AuthorID: "author-1", ISBN: &isbn1}), ShouldBeNil)
+			So(s.system.Books.store(&model.Book{ID: "book-2", AuthorID: "author-1", ISBN: &isbn2}), ShouldBeNil)
+
+			Convey("When the books of the author are fetched", func() {
+				_, err := repo.FindByKey(ctx, "AuthorID", "author-1")
+
+				Convey("Then the books should be stored once in the cache by ID, by the normalized cache, "+
+					"And the books should be cached by ISBN", func() {
+					So(err, ShouldBeNil)
+					So(idStore.Sets(), ShouldEqual, 2)
+					var cached model.Book
+					found, _ := s.system.CacheStore.Get(ctx, "pi:isbn-1", &cached)
+					So(found, ShouldBeTrue)
+					found, _ = s.system.CacheStore.Get(ctx, "pi:isbn-2", &cached)
+					So(found, ShouldBeTrue)
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryCrossPopulationTestSuite) SetupTest() {
+	s.system = startSystemForTests()
+}
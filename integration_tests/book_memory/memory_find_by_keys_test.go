@@ -0,0 +1,68 @@
+package book_memory
+
+import (
+	"errors"
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/integration_tests/model"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type MemoryFindByKeysTestSuite struct {
+	suite.Suite
+	system *testSystem
+}
+
+func TestMemoryFindByKeysTestSuite(t *testing.T) {
+	suite.Run(t, new(MemoryFindByKeysTestSuite))
+}
+
+func (s *MemoryFindByKeysTestSuite) TestFindByKeysWithIdsThatAreNotSlices() {
+	ctx := s.system.Ctx
+	var nilIds *[]string
+
+	Convey("Scenario: Retrieve books with ids that aren't a slice", s.T(), func() {
+		Convey("Given a repository of books with a cache by ID", func() {
+			repo := s.system.builder().
+				WithUniqueKeyCache(idCache, s.system.CacheStore).
+				BuildCachedRepository()
+
+			for name, ids := range map[string]interface{}{"a single id": "book-1", "nil": nil, "a nil pointer to a slice": nilIds} {
+				Convey("When the books are fetched with "+name+" as ids", func() {
+					var results []datarepo.Result
+					var err error
+					find := func() {
+						results, err = repo.FindByKeys(ctx, "ID", ids)
+					}
+
+					Convey("Then a FetchError wrapping ErrTypeMismatch should be returned without panicking", func() {
+						So(find, ShouldNotPanic)
+						So(results, ShouldBeNil)
+						var fetchErr *datarepo.FetchError
+						So(errors.As(err, &fetchErr), ShouldBeTrue)
+						So(fetchErr.KeyFieldName, ShouldEqual, "ID")
+						So(errors.Is(err, datarepo.ErrTypeMismatch), ShouldBeTrue)
+					})
+				})
+			}
+
+			Convey("When the books are fetched with a pointer to a slice of ids", func() {
+				So(repo.Create(ctx, &model.Book{ID: "book-1", AuthorID: "author-1"}), ShouldBeNil)
+				ids := []string{"book-1", "book-2"}
+				results, err := repo.FindByKeys(ctx, "ID", &ids)
+
+				Convey("Then a result should be returned for each id", func() {
+					So(err, ShouldBeNil)
+					So(results, ShouldHaveLength, 2)
+					So(results[0].StoredValue().(*model.Book).ID, ShouldEqual, "book-1")
+					So(results[1].IsEmpty(), ShouldBeTrue)
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryFindByKeysTestSuite) SetupTest() {
+	s.system = startSystemForTests()
+}
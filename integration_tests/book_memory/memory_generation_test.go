@@ -0,0 +1,82 @@
+package book_memory
+
+import (
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/integration_tests/model"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type MemoryGenerationTestSuite struct {
+	suite.Suite
+	system *testSystem
+}
+
+func TestMemoryGenerationTestSuite(t *testing.T) {
+	suite.Run(t, new(MemoryGenerationTestSuite))
+}
+
+func (s *MemoryGenerationTestSuite) TestFlushAndBumpGeneration() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Flush a generational cache and bump its generation", s.T(), func() {
+		Convey("Given a generational cache of books by ID whose generation was bumped twice, "+
+			"And a book cached in the current generation", func() {
+			generationalCache := idCache
+			generationalCache.Generational = true
+			repo := s.system.builder().
+				WithUniqueKeyCache(generationalCache, s.system.CacheStore).
+				BuildCachedRepository()
+			_, err := repo.BumpGeneration(ctx, "ID")
+			So(err, ShouldBeNil)
+			_, err = repo.BumpGeneration(ctx, "ID")
+			So(err, ShouldBeNil)
+			So(repo.Create(ctx, &model.Book{ID: "book-1", AuthorID: "author-1"}), ShouldBeNil)
+
+			Convey("When the cache is flushed and its generation is bumped", func() {
+				flushErr := repo.Flush(ctx, "ID")
+				generation, bumpErr := repo.BumpGeneration(ctx, "ID")
+
+				Convey("Then the generation should keep increasing after the flush, "+
+					"And the generation should be stored outside the key prefix of the cache", func() {
+					So(flushErr, ShouldBeNil)
+					So(bumpErr, ShouldBeNil)
+					So(generation, ShouldEqual, 3)
+					var stored int64
+					found, _ := s.system.CacheStore.Get(ctx, datarepo.GenerationKey(idCache.KeyPrefix), &stored)
+					So(found, ShouldBeTrue)
+					So(stored, ShouldEqual, 3)
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryGenerationTestSuite) TestGenerationKeyUnderCachePrefix() {
+	Convey("Scenario: Build a repository where the generation of a cache is stored under the prefix of another cache", s.T(), func() {
+		Convey("Given a generational cache, "+
+			"And a cache in the same store whose prefix is the prefix of the keys of generations", func() {
+			generationalCache := idCache
+			generationalCache.Generational = true
+			authorCache := authorIdCache
+			authorCache.KeyPrefix = "datarepo:"
+
+			Convey("When the repository is built", func() {
+				_, err := s.system.builder().
+					WithUniqueKeyCache(generationalCache, s.system.CacheStore).
+					WithNonUniqueKeyCache(authorCache, s.system.CacheStore).
+					Build()
+
+				Convey("Then the configuration should be rejected", func() {
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldContainSubstring, "generation of the cache with prefix 'b:'")
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryGenerationTestSuite) SetupTest() {
+	s.system = startSystemForTests()
+}
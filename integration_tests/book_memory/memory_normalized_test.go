@@ -0,0 +1,291 @@
+package book_memory
+
+import (
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/integration_tests/model"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type MemoryNormalizedTestSuite struct {
+	suite.Suite
+	system *testSystem
+	repo   datarepo.CachedRepository
+}
+
+func TestMemoryNormalizedTestSuite(t *testing.T) {
+	suite.Run(t, new(MemoryNormalizedTestSuite))
+}
+
+// returns the ids of the books of the result
+func bookIds(result datarepo.Result) []string {
+	var books []*model.Book
+	result.InjectResult(&books)
+	ids := make([]string, len(books))
+	for i, b := range books {
+		ids[i] = b.ID
+	}
+	return ids
+}
+
+// returns the subkeys stored in the normalized entry of the author
+func (s *MemoryNormalizedTestSuite) cachedSubKeys(authorId string) ([]string, bool) {
+	var ids []string
+	found, _ := s.system.CacheStore.Get(s.system.Ctx, normalizedAuthorIdCache.KeyPrefix+authorId, &ids)
+	return ids, found
+}
+
+func (s *MemoryNormalizedTestSuite) TestFillNormalizedEntry() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Read the books of an author that aren't cached", s.T(), func() {
+		Convey("Given an author with two books that aren't cached", func() {
+			So(s.system.Books.store(&model.Book{ID: "book-2", AuthorID: "author-1"}), ShouldBeNil)
+			So(s.system.Books.store(&model.Book{ID: "book-1", AuthorID: "author-1"}), ShouldBeNil)
+
+			Convey("When the books of the author are read twice", func() {
+				readsBefore := s.system.Books.Reads()
+				first, firstErr := s.repo.FindByKey(ctx, "AuthorID", "author-1")
+				second, secondErr := s.repo.FindByKey(ctx, "AuthorID", "author-1")
+
+				Convey("Then the entry of the author should hold the ids in the order they were fetched, "+
+					"And every book should be cached by ID, "+
+					"And the second read should be served from the cache", func() {
+					So(firstErr, ShouldBeNil)
+					So(secondErr, ShouldBeNil)
+					So(bookIds(first), ShouldResemble, []string{"book-2", "book-1"})
+					So(bookIds(second), ShouldResemble, []string{"book-2", "book-1"})
+					ids, found := s.cachedSubKeys("author-1")
+					So(found, ShouldBeTrue)
+					So(ids, ShouldResemble, []string{"book-2", "book-1"})
+					for _, id := range ids {
+						var cached model.Book
+						found, _ := s.system.CacheStore.Get(ctx, "b:"+id, &cached)
+						So(found, ShouldBeTrue)
+					}
+					So(s.system.Books.Reads(), ShouldEqual, readsBefore+1)
+				})
+			})
+
+			Convey("When the books of the author are read several times in the same call", func() {
+				So(s.system.CacheStore.Delete(ctx, normalizedAuthorIdCache.KeyPrefix+"author-1"), ShouldBeNil)
+				readsBefore := s.system.Books.Reads()
+				results, err := s.repo.FindByKeys(ctx, "AuthorID", []string{"author-1", "author-2", "author-1"})
+
+				Convey("Then the author should be fetched once, "+
+					"And every occurrence of the author should have its books", func() {
+					So(err, ShouldBeNil)
+					So(bookIds(results[0]), ShouldResemble, []string{"book-2", "book-1"})
+					So(results[1].IsEmpty(), ShouldBeTrue)
+					So(bookIds(results[2]), ShouldResemble, []string{"book-2", "book-1"})
+					So(s.system.Books.Reads(), ShouldEqual, readsBefore+1)
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryNormalizedTestSuite) TestReadMissingMembers() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Read the books of an author whose books aren't all cached by ID", s.T(), func() {
+		Convey("Given an author with two books cached in the entry of the author", func() {
+			So(s.system.Books.store(&model.Book{ID: "book-1", AuthorID: "author-1"}), ShouldBeNil)
+			So(s.system.Books.store(&model.Book{ID: "book-2", AuthorID: "author-1"}), ShouldBeNil)
+			_, err := s.repo.FindByKey(ctx, "AuthorID", "author-1")
+			So(err, ShouldBeNil)
+
+			Convey("When a book is evicted from the cache by ID and the books of the author are read", func() {
+				So(s.system.CacheStore.Delete(ctx, "b:book-2"), ShouldBeNil)
+				readsBefore := s.system.Books.Reads()
+				result, err := s.repo.FindByKey(ctx, "AuthorID", "author-1")
+
+				Convey("Then the evicted book should be fetched by ID and cached again, "+
+					"And the entry of the author should be kept", func() {
+					So(err, ShouldBeNil)
+					So(bookIds(result), ShouldResemble, []string{"book-1", "book-2"})
+					So(s.system.Books.Reads(), ShouldEqual, readsBefore+1)
+					var cached model.Book
+					found, _ := s.system.CacheStore.Get(ctx, "b:book-2", &cached)
+					So(found, ShouldBeTrue)
+					ids, _ := s.cachedSubKeys("author-1")
+					So(ids, ShouldResemble, []string{"book-1", "book-2"})
+				})
+			})
+
+			Convey("When a book is deleted from the data source and evicted from the cache by ID, "+
+				"And the books of the author are read", func() {
+				s.system.Books.Delete("book-1")
+				So(s.system.CacheStore.Delete(ctx, "b:book-1"), ShouldBeNil)
+				result, err := s.repo.FindByKey(ctx, "AuthorID", "author-1")
+
+				Convey("Then the deleted book should be left out of the books of the author", func() {
+					So(err, ShouldBeNil)
+					So(bookIds(result), ShouldResemble, []string{"book-2"})
+				})
+			})
+
+			Convey("When a book is evicted from the cache by ID and the books of the author are read from the cache only", func() {
+				So(s.system.CacheStore.Delete(ctx, "b:book-2"), ShouldBeNil)
+				readsBefore := s.system.Books.Reads()
+				result, err := s.repo.FindByKey(ctx, "AuthorID", "author-1", datarepo.CacheOnly())
+
+				Convey("Then no books should be returned instead of an incomplete list, "+
+					"And nothing should be fetched", func() {
+					So(err, ShouldBeNil)
+					So(result.IsEmpty(), ShouldBeTrue)
+					So(s.system.Books.Reads(), ShouldEqual, readsBefore)
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryNormalizedTestSuite) TestWriteNormalizedEntry() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Write the books of an author cached in a normalized entry", s.T(), func() {
+		Convey("Given an author with a book cached in the entry of the author", func() {
+			So(s.system.Books.store(&model.Book{ID: "book-1", AuthorID: "author-1"}), ShouldBeNil)
+			_, err := s.repo.FindByKey(ctx, "AuthorID", "author-1")
+			So(err, ShouldBeNil)
+
+			Convey("When another book of the author is created", func() {
+				createErr := s.repo.Create(ctx, &model.Book{ID: "book-2", AuthorID: "author-1"})
+				readsBefore := s.system.Books.Reads()
+				result, err := s.repo.FindByKey(ctx, "AuthorID", "author-1")
+
+				Convey("Then its id should be appended to the entry of the author, "+
+					"And both books should be read from the cache", func() {
+					So(createErr, ShouldBeNil)
+					So(err, ShouldBeNil)
+					ids, _ := s.cachedSubKeys("author-1")
+					So(ids, ShouldResemble, []string{"book-1", "book-2"})
+					So(bookIds(result), ShouldResemble, []string{"book-1", "book-2"})
+					So(s.system.Books.Reads(), ShouldEqual, readsBefore)
+				})
+			})
+
+			Convey("When the book is updated", func() {
+				idsBefore, _ := s.cachedSubKeys("author-1")
+				updateErr := s.repo.Update(ctx, &model.Book{ID: "book-1", AuthorID: "author-1", Status: "published"})
+				result, err := s.repo.FindByKey(ctx, "AuthorID", "author-1")
+
+				Convey("Then the entry of the author should be left as it was, "+
+					"And the book of the author should have the update of the cache by ID", func() {
+					So(updateErr, ShouldBeNil)
+					So(err, ShouldBeNil)
+					ids, _ := s.cachedSubKeys("author-1")
+					So(ids, ShouldResemble, idsBefore)
+					var books []*model.Book
+					result.InjectResult(&books)
+					So(books[0].ID, ShouldEqual, "book-1")
+					So(books[0].Status, ShouldEqual, "published")
+				})
+			})
+
+			Convey("When a book of an author without a cached entry is created", func() {
+				createErr := s.repo.Create(ctx, &model.Book{ID: "book-3", AuthorID: "author-2"})
+
+				Convey("Then no entry should be created for that author, as it would be incomplete", func() {
+					So(createErr, ShouldBeNil)
+					_, found := s.cachedSubKeys("author-2")
+					So(found, ShouldBeFalse)
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryNormalizedTestSuite) TestChangeKeyOfMember() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Move a book cached in a normalized entry to another author", s.T(), func() {
+		Convey("Given two authors with their books cached in their entries", func() {
+			So(s.system.Books.store(&model.Book{ID: "book-1", AuthorID: "author-1"}), ShouldBeNil)
+			So(s.system.Books.store(&model.Book{ID: "book-2", AuthorID: "author-1"}), ShouldBeNil)
+			So(s.system.Books.store(&model.Book{ID: "book-3", AuthorID: "author-2"}), ShouldBeNil)
+			_, err := s.repo.FindByKeys(ctx, "AuthorID", []string{"author-1", "author-2"})
+			So(err, ShouldBeNil)
+
+			Convey("When a book of the first author is updated to belong to the second author", func() {
+				updateErr := s.repo.Update(ctx, &model.Book{ID: "book-1", AuthorID: "author-2"})
+				results, err := s.repo.FindByKeys(ctx, "AuthorID", []string{"author-1", "author-2"})
+				cacheOnly, cacheOnlyErr := s.repo.FindByKey(ctx, "AuthorID", "author-1", datarepo.CacheOnly())
+
+				Convey("Then the book should no longer be returned for the first author, "+
+					"And it should be returned for the second author", func() {
+					So(updateErr, ShouldBeNil)
+					So(err, ShouldBeNil)
+					So(cacheOnlyErr, ShouldBeNil)
+					So(bookIds(results[0]), ShouldResemble, []string{"book-2"})
+					So(bookIds(results[1]), ShouldResemble, []string{"book-3", "book-1"})
+					So(bookIds(cacheOnly), ShouldResemble, []string{"book-2"})
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryNormalizedTestSuite) TestRefreshNormalizedEntry() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Refresh the normalized entries of authors", s.T(), func() {
+		Convey("Given two authors with a book each cached in their entries", func() {
+			So(s.system.Books.store(&model.Book{ID: "book-1", AuthorID: "author-1"}), ShouldBeNil)
+			So(s.system.Books.store(&model.Book{ID: "book-2", AuthorID: "author-2"}), ShouldBeNil)
+			_, err := s.repo.FindByKeys(ctx, "AuthorID", []string{"author-1", "author-2"})
+			So(err, ShouldBeNil)
+
+			Convey("When a book is added to the first author and the book of the second author is deleted "+
+				"outside of the repository, And both authors are refreshed", func() {
+				So(s.system.Books.store(&model.Book{ID: "book-3", AuthorID: "author-1"}), ShouldBeNil)
+				s.system.Books.Delete("book-2")
+				results, err := s.repo.Refresh(ctx, "AuthorID", "author-1", "author-2")
+
+				Convey("Then the entry of the first author should hold both ids, "+
+					"And the added book should be cached by ID, "+
+					"And the entry of the second author should be removed", func() {
+					So(err, ShouldBeNil)
+					So(bookIds(results[0]), ShouldResemble, []string{"book-1", "book-3"})
+					So(results[1].IsEmpty(), ShouldBeTrue)
+					ids, _ := s.cachedSubKeys("author-1")
+					So(ids, ShouldResemble, []string{"book-1", "book-3"})
+					var cached model.Book
+					found, _ := s.system.CacheStore.Get(ctx, "b:book-3", &cached)
+					So(found, ShouldBeTrue)
+					_, found = s.cachedSubKeys("author-2")
+					So(found, ShouldBeFalse)
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryNormalizedTestSuite) TestBuildWithoutMembersCache() {
+	Convey("Scenario: Build a repository with a normalized cache without a cache for its subkeys", s.T(), func() {
+		Convey("Given a normalized cache of books by author without a cache of books by ID", func() {
+			builder := s.system.builder().
+				WithNonUniqueKeyCache(normalizedAuthorIdCache, s.system.CacheStore)
+
+			Convey("When the repository is built", func() {
+				_, err := builder.Build()
+
+				Convey("Then the configuration should be rejected", func() {
+					So(buildErrorMessages(err), ShouldResemble, []string{
+						"a normalized non-unique key cache needs a unique key cache for its SubKeyFieldName: AuthorID",
+					})
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryNormalizedTestSuite) SetupTest() {
+	s.system = startSystemForTests()
+	s.repo = s.system.builder().
+		WithUniqueKeyCache(idCache, s.system.CacheStore).
+		WithNonUniqueKeyCache(normalizedAuthorIdCache, s.system.CacheStore).
+		BuildCachedRepository()
+}
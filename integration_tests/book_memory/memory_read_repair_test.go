@@ -0,0 +1,217 @@
+package book_memory
+
+import (
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/integration_tests/model"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type MemoryReadRepairTestSuite struct {
+	suite.Suite
+	system *testSystem
+	store  *failingCacheStore
+}
+
+func TestMemoryReadRepairTestSuite(t *testing.T) {
+	suite.Run(t, new(MemoryReadRepairTestSuite))
+}
+
+var (
+	normalizedAuthorIdCache = datarepo.NonUniqueKeyCacheDefinition{
+		KeyPrefix:       "na:",
+		KeyFieldName:    "AuthorID",
+		SubKeyFieldName: "ID",
+		Normalized:      true,
+		Expiration:      5 * time.Minute,
+	}
+	isbnCache = datarepo.UniqueKeyCacheDefinition{
+		KeyPrefix:           "i:",
+		KeyFieldName:        "ISBN",
+		PrimaryKeyFieldName: "ID",
+		Expiration:          5 * time.Minute,
+	}
+)
+
+// read-repair options that verify every cache hit, sending the drift events to the channel
+func repairEveryHit(drifts chan datarepo.DriftEvent) datarepo.ReadRepairOptions {
+	return datarepo.ReadRepairOptions{
+		SampleRate: 1,
+		OnDrift: func(event datarepo.DriftEvent) {
+			drifts <- event
+		},
+	}
+}
+
+func (s *MemoryReadRepairTestSuite) TestRepairStaleEntry() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Repair a cached book that was updated outside of the repository", s.T(), func() {
+		Convey("Given a repository that verifies every hit of its cache by ID, "+
+			"And a cached book that was updated in the data source", func() {
+			drifts := make(chan datarepo.DriftEvent, 10)
+			repo := s.system.builder().
+				WithUniqueKeyCache(idCache, s.store).
+				WithReadRepair(repairEveryHit(drifts)).
+				BuildCachedRepository()
+			So(repo.Create(ctx, &model.Book{ID: "book-1", AuthorID: "author-1", Status: "draft"}), ShouldBeNil)
+			_, err := repo.FindByKey(ctx, "ID", "book-1")
+			So(err, ShouldBeNil)
+			So(repo.(datarepo.Shutdowner).Shutdown(ctx), ShouldBeNil)
+			So(s.system.Books.store(&model.Book{ID: "book-1", AuthorID: "author-1", Status: "published"}), ShouldBeNil)
+
+			Convey("When the book is read from the cache", func() {
+				_, err := repo.FindByKey(ctx, "ID", "book-1")
+				shutdownErr := repo.(datarepo.Shutdowner).Shutdown(ctx)
+
+				Convey("Then a drift event should be emitted, "+
+					"And the updated book should be cached", func() {
+					So(err, ShouldBeNil)
+					So(shutdownErr, ShouldBeNil)
+					So(drifts, ShouldHaveLength, 1)
+					So((<-drifts).Key, ShouldEqual, "book-1")
+					result, err := repo.FindByKey(ctx, "ID", "book-1", datarepo.CacheOnly())
+					So(err, ShouldBeNil)
+					So(result.StoredValue().(*model.Book).Status, ShouldEqual, "published")
+				})
+			})
+
+			Convey("When the book is read from the cache while the store fails to store values", func() {
+				s.store.fail(true, false)
+				_, err := repo.FindByKey(ctx, "ID", "book-1")
+				shutdownErr := repo.(datarepo.Shutdowner).Shutdown(ctx)
+				s.store.fail(false, false)
+
+				Convey("Then no drift event should be emitted, "+
+					"And the stale book should remain cached", func() {
+					So(err, ShouldBeNil)
+					So(shutdownErr, ShouldBeNil)
+					So(drifts, ShouldBeEmpty)
+					result, err := repo.FindByKey(ctx, "ID", "book-1", datarepo.CacheOnly())
+					So(err, ShouldBeNil)
+					So(result.StoredValue().(*model.Book).Status, ShouldEqual, "draft")
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryReadRepairTestSuite) TestKeepEntryWrittenDuringVerification() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Verify a cached book that is written while it's compared with the data source", s.T(), func() {
+		Convey("Given a repository that verifies every hit of its cache by ID, "+
+			"And a comparison that writes a newer book to the cache entry, "+
+			"And a cached book that was updated in the data source", func() {
+			drifts := make(chan datarepo.DriftEvent, 10)
+			options := repairEveryHit(drifts)
+			writeDuringComparison := false
+			options.Equal = func(cached, stored interface{}) bool {
+				if !writeDuringComparison {
+					return true
+				}
+				s.store.Set(ctx, "b:book-1", &model.Book{ID: "book-1", AuthorID: "author-1", Status: "archived"}, time.Minute)
+				return false
+			}
+			repo := s.system.builder().
+				WithUniqueKeyCache(idCache, s.store).
+				WithReadRepair(options).
+				BuildCachedRepository()
+			So(repo.Create(ctx, &model.Book{ID: "book-1", AuthorID: "author-1", Status: "draft"}), ShouldBeNil)
+			_, err := repo.FindByKey(ctx, "ID", "book-1")
+			So(err, ShouldBeNil)
+			So(repo.(datarepo.Shutdowner).Shutdown(ctx), ShouldBeNil)
+			So(s.system.Books.store(&model.Book{ID: "book-1", AuthorID: "author-1", Status: "published"}), ShouldBeNil)
+
+			Convey("When the book is read from the cache", func() {
+				writeDuringComparison = true
+				_, err := repo.FindByKey(ctx, "ID", "book-1")
+				shutdownErr := repo.(datarepo.Shutdowner).Shutdown(ctx)
+
+				Convey("Then no drift event should be emitted, "+
+					"And the book written during the comparison should remain cached", func() {
+					So(err, ShouldBeNil)
+					So(shutdownErr, ShouldBeNil)
+					So(drifts, ShouldBeEmpty)
+					result, err := repo.FindByKey(ctx, "ID", "book-1", datarepo.CacheOnly())
+					So(err, ShouldBeNil)
+					So(result.StoredValue().(*model.Book).Status, ShouldEqual, "archived")
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryReadRepairTestSuite) TestRepairEntriesOfCachesStoringKeys() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Repair the entries of normalized and secondary key caches", s.T(), func() {
+		Convey("Given a repository that verifies every cache hit, with a cache by ID, "+
+			"a normalized cache by author and a cache by ISBN that stores the IDs of the books, "+
+			"And a cached book whose ISBN moved to another book in the data source, "+
+			"And another book of its author created in the data source", func() {
+			drifts := make(chan datarepo.DriftEvent, 10)
+			repo := s.system.builder().
+				WithUniqueKeyCache(idCache, s.store).
+				WithNonUniqueKeyCache(normalizedAuthorIdCache, s.store).
+				WithUniqueKeyCache(isbnCache, s.store).
+				WithReadRepair(repairEveryHit(drifts)).
+				BuildCachedRepository()
+			isbn, otherIsbn := "isbn-1", "isbn-2"
+			So(repo.Create(ctx, &model.Book{ID: "book-1", AuthorID: "author-1", ISBN: &isbn}), ShouldBeNil)
+			_, err := repo.FindByKey(ctx, "AuthorID", "author-1")
+			So(err, ShouldBeNil)
+			_, err = repo.FindByKey(ctx, "ISBN", "isbn-1")
+			So(err, ShouldBeNil)
+			So(repo.(datarepo.Shutdowner).Shutdown(ctx), ShouldBeNil)
+			So(s.system.Books.store(&model.Book{ID: "book-1", AuthorID: "author-1", ISBN: &otherIsbn}), ShouldBeNil)
+			So(s.system.Books.store(&model.Book{ID: "book-2", AuthorID: "author-1", ISBN: &isbn}), ShouldBeNil)
+
+			Convey("When the entries of the author and the ISBN are read from the cache", func() {
+				_, authorErr := repo.FindByKey(ctx, "AuthorID", "author-1")
+				_, isbnErr := repo.FindByKey(ctx, "ISBN", "isbn-1")
+				shutdownErr := repo.(datarepo.Shutdowner).Shutdown(ctx)
+
+				Convey("Then a drift event should be emitted for the entries of the author and the ISBN", func() {
+					So(authorErr, ShouldBeNil)
+					So(isbnErr, ShouldBeNil)
+					So(shutdownErr, ShouldBeNil)
+					prefixes := driftPrefixes(drifts)
+					So(prefixes, ShouldContain, normalizedAuthorIdCache.KeyPrefix)
+					So(prefixes, ShouldContain, isbnCache.KeyPrefix)
+				})
+			})
+
+			Convey("When the entries of the author and the ISBN are read from the cache while the store fails to store values", func() {
+				s.store.fail(true, false)
+				_, authorErr := repo.FindByKey(ctx, "AuthorID", "author-1")
+				_, isbnErr := repo.FindByKey(ctx, "ISBN", "isbn-1")
+				shutdownErr := repo.(datarepo.Shutdowner).Shutdown(ctx)
+				s.store.fail(false, false)
+
+				Convey("Then no drift event should be emitted", func() {
+					So(authorErr, ShouldBeNil)
+					So(isbnErr, ShouldBeNil)
+					So(shutdownErr, ShouldBeNil)
+					So(drifts, ShouldBeEmpty)
+				})
+			})
+		})
+	})
+}
+
+// returns the key prefixes of the caches of the drift events sent to the channel
+func driftPrefixes(drifts chan datarepo.DriftEvent) []string {
+	var prefixes []string
+	for len(drifts) > 0 {
+		prefixes = append(prefixes, (<-drifts).CacheKeyPrefix)
+	}
+	return prefixes
+}
+
+func (s *MemoryReadRepairTestSuite) SetupTest() {
+	s.system = startSystemForTests()
+	s.store = &failingCacheStore{MetadataCacheStore: s.system.CacheStore}
+}
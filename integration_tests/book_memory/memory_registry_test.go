@@ -0,0 +1,308 @@
+package book_memory
+
+import (
+	"context"
+	"errors"
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/cachestore/memory"
+	"github.com/merlinapp/datarepo-go/cachestore/stats"
+	"github.com/merlinapp/datarepo-go/integration_tests/model"
+	repostats "github.com/merlinapp/datarepo-go/repo/stats"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/suite"
+	"sync"
+	"testing"
+)
+
+type MemoryRegistryTestSuite struct {
+	suite.Suite
+	system *testSystem
+}
+
+func TestMemoryRegistryTestSuite(t *testing.T) {
+	suite.Run(t, new(MemoryRegistryTestSuite))
+}
+
+// Error returned by the unhealthy components of the tests
+var errUnhealthy = errors.New("the component is unhealthy")
+
+// A CacheStore whose health is reported with the error it holds
+type pingingCacheStore struct {
+	datarepo.CacheStore
+	err error
+}
+
+func (s *pingingCacheStore) Ping(ctx context.Context) error {
+	return s.err
+}
+
+// A DataWriter of books with a background worker that fails to shut down with the error it holds
+type shutdownBookWriter struct {
+	*bookSource
+	err       error
+	shutdowns int
+}
+
+func (w *shutdownBookWriter) Shutdown(ctx context.Context) error {
+	w.shutdowns++
+	return w.err
+}
+
+func (s *MemoryRegistryTestSuite) TestRegisterRepositories() {
+	Convey("Scenario: Register repositories of several entities", s.T(), func() {
+		Convey("Given a read-write repository of books, "+
+			"And a read-only repository of authors' books with its own prefix in the same store", func() {
+			registry := datarepo.NewRegistry()
+			books := s.system.builder().
+				WithUniqueKeyCache(idCache, s.system.CacheStore).
+				BuildCachedRepository()
+			authorBooks := s.system.builder().
+				WithNonUniqueKeyCache(authorIdCache, s.system.CacheStore).
+				BuildROCachedRepository()
+
+			Convey("When both repositories are registered", func() {
+				booksErr := registry.Register("book", books)
+				authorBooksErr := registry.Register("authorBook", authorBooks)
+
+				Convey("Then they should be registered by entity name, "+
+					"And only the read-write repository should be returned as a CachedRepository", func() {
+					So(booksErr, ShouldBeNil)
+					So(authorBooksErr, ShouldBeNil)
+					So(registry.EntityNames(), ShouldResemble, []string{"authorBook", "book"})
+					repo, ok := registry.Repository("authorBook")
+					So(ok, ShouldBeTrue)
+					So(repo, ShouldEqual, authorBooks)
+					rwRepo, ok := registry.CachedRepository("book")
+					So(ok, ShouldBeTrue)
+					So(rwRepo, ShouldEqual, books)
+					_, ok = registry.CachedRepository("authorBook")
+					So(ok, ShouldBeFalse)
+					_, ok = registry.Repository("author")
+					So(ok, ShouldBeFalse)
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryRegistryTestSuite) TestRegisterCollisions() {
+	Convey("Scenario: Register repositories that collide", s.T(), func() {
+		Convey("Given a registered repository of books cached with prefix 'b:'", func() {
+			registry := datarepo.NewRegistry()
+			books := s.system.builder().
+				WithUniqueKeyCache(idCache, s.system.CacheStore).
+				BuildCachedRepository()
+			So(registry.Register("book", books), ShouldBeNil)
+
+			Convey("When another repository is registered with the same entity name", func() {
+				other := s.system.builder().
+					WithNonUniqueKeyCache(authorIdCache, s.system.CacheStore).
+					BuildCachedRepository()
+				err := registry.Register("book", other)
+
+				Convey("Then the registration should fail", func() {
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual, "a repository has already been registered for: book")
+				})
+			})
+
+			Convey("When a repository whose prefix starts with 'b:' is registered in the same store", func() {
+				bookTypeCache := datarepo.UniqueKeyCacheDefinition{KeyPrefix: "b:t:", KeyFieldName: "ID"}
+				bookTypes := s.system.builder().
+					WithUniqueKeyCache(bookTypeCache, s.system.CacheStore).
+					BuildCachedRepository()
+				err := registry.Register("bookType", bookTypes)
+
+				Convey("Then the registration should fail with the colliding prefixes, "+
+					"And the repository shouldn't be registered", func() {
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual, "key prefix 'b:t:' of bookType collides with key prefix 'b:' of book in the same cache store")
+					_, ok := registry.Repository("bookType")
+					So(ok, ShouldBeFalse)
+				})
+			})
+
+			Convey("When a repository with the same prefix is registered in another store", func() {
+				otherStore := memory.NewFreeCacheInMemoryStore(1024 * 1024)
+				otherBooks := s.system.builder().
+					WithUniqueKeyCache(idCache, otherStore).
+					BuildCachedRepository()
+				err := registry.Register("archivedBook", otherBooks)
+
+				Convey("Then the repository should be registered", func() {
+					So(err, ShouldBeNil)
+					So(registry.EntityNames(), ShouldResemble, []string{"archivedBook", "book"})
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryRegistryTestSuite) TestHealthCheck() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Check the health of the registered repositories", s.T(), func() {
+		Convey("Given a repository of books whose cache store is healthy, "+
+			"And a repository of authors' books whose cache store can become unhealthy", func() {
+			healthyStore := &pingingCacheStore{CacheStore: s.system.CacheStore}
+			authorStore := &pingingCacheStore{CacheStore: memory.NewFreeCacheInMemoryStore(1024 * 1024)}
+			registry := datarepo.NewRegistry()
+			So(registry.Register("book", s.system.builder().
+				WithUniqueKeyCache(idCache, stats.NewStatsCacheStore(healthyStore)).
+				BuildCachedRepository()), ShouldBeNil)
+			So(registry.Register("authorBook", s.system.builder().
+				WithNonUniqueKeyCache(authorIdCache, authorStore).
+				BuildCachedRepository()), ShouldBeNil)
+
+			Convey("When the health is checked while every store is healthy", func() {
+				err := registry.HealthCheck(ctx)
+
+				Convey("Then no error should be returned", func() {
+					So(err, ShouldBeNil)
+				})
+			})
+
+			Convey("When the health is checked while the store of authors' books is unhealthy", func() {
+				authorStore.err = errUnhealthy
+				err := registry.HealthCheck(ctx)
+
+				Convey("Then a RegistryError should report the error of that repository only", func() {
+					var registryErr *datarepo.RegistryError
+					So(errors.As(err, &registryErr), ShouldBeTrue)
+					So(registryErr.Errors, ShouldResemble, map[string]error{"authorBook": errUnhealthy})
+					So(err.Error(), ShouldEqual, "authorBook: "+errUnhealthy.Error())
+				})
+			})
+
+			Convey("When the health is checked while the store of books, wrapped with stats, is unhealthy", func() {
+				healthyStore.err = errUnhealthy
+				err := registry.HealthCheck(ctx)
+
+				Convey("Then the ping should go through the stats wrapper", func() {
+					var registryErr *datarepo.RegistryError
+					So(errors.As(err, &registryErr), ShouldBeTrue)
+					So(registryErr.Errors, ShouldContainKey, "book")
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryRegistryTestSuite) TestStats() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Aggregate the stats of the registered repositories", s.T(), func() {
+		Convey("Given a repository of books whose store, fetcher and writer keep stats, "+
+			"And a repository without stats, "+
+			"And a book that was created", func() {
+			statsStore := stats.NewStatsCacheStore(s.system.CacheStore)
+			books := datarepo.CachedRepositoryBuilder(&model.Book{}).
+				WithUniqueKeyDataFetcher(repostats.NewStatsDataFetcher(&uniqueBookFetcher{s.system.Books})).
+				WithDataWriter(repostats.NewStatsDataWriter(s.system.Books)).
+				WithUniqueKeyCache(idCache, statsStore).
+				BuildCachedRepository()
+			authorBooks := s.system.builder().
+				WithNonUniqueKeyCache(authorIdCache, memory.NewFreeCacheInMemoryStore(1024*1024)).
+				BuildCachedRepository()
+			registry := datarepo.NewRegistry()
+			So(registry.Register("book", books), ShouldBeNil)
+			So(registry.Register("authorBook", authorBooks), ShouldBeNil)
+			So(books.Create(ctx, &model.Book{ID: "book-1", AuthorID: "author-1"}), ShouldBeNil)
+
+			Convey("When the book is read twice and a missing book is read", func() {
+				_, err := books.FindByKey(ctx, "ID", "book-1")
+				So(err, ShouldBeNil)
+				_, err = books.FindByKey(ctx, "ID", "book-1")
+				So(err, ShouldBeNil)
+				_, err = books.FindByKey(ctx, "ID", "book-2")
+				So(err, ShouldBeNil)
+				_, err = authorBooks.FindByKey(ctx, "AuthorID", "author-1")
+				So(err, ShouldBeNil)
+
+				Convey("Then the stats of the repository of books should be aggregated from its components, "+
+					"And the repository without stats should report none", func() {
+					byEntity := registry.Stats()
+					So(byEntity["book"], ShouldResemble, datarepo.RepositoryStats{
+						Hits: 2, Misses: 1, Sets: 1, Reads: 1, Creates: 1,
+					})
+					So(byEntity["authorBook"], ShouldResemble, datarepo.RepositoryStats{})
+					So(registry.TotalStats(), ShouldResemble, byEntity["book"])
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryRegistryTestSuite) TestStatsWhileReading() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Aggregate the stats of a repository while it's being read", s.T(), func() {
+		Convey("Given a registered repository of books whose store keeps stats, "+
+			"And a cached book", func() {
+			statsStore := stats.NewStatsCacheStore(s.system.CacheStore)
+			books := s.system.builder().
+				WithUniqueKeyCache(idCache, statsStore).
+				BuildCachedRepository()
+			registry := datarepo.NewRegistry()
+			So(registry.Register("book", books), ShouldBeNil)
+			So(books.Create(ctx, &model.Book{ID: "book-1", AuthorID: "author-1"}), ShouldBeNil)
+
+			Convey("When the book is read concurrently while the stats are aggregated", func() {
+				var wg sync.WaitGroup
+				for i := 0; i < 10; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						_, _ = books.FindByKey(ctx, "ID", "book-1")
+					}()
+				}
+				for i := 0; i < 10; i++ {
+					registry.Stats()
+				}
+				wg.Wait()
+
+				Convey("Then every read should be counted", func() {
+					So(registry.Stats()["book"].Hits, ShouldEqual, 10)
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryRegistryTestSuite) TestShutdown() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Shut down the registered repositories", s.T(), func() {
+		Convey("Given a repository of books whose writer fails to shut down, "+
+			"And a repository of authors' books whose writer shuts down", func() {
+			failingWriter := &shutdownBookWriter{bookSource: s.system.Books, err: errUnhealthy}
+			writer := &shutdownBookWriter{bookSource: s.system.Books}
+			registry := datarepo.NewRegistry()
+			So(registry.Register("book", s.system.builder().
+				WithDataWriter(failingWriter).
+				WithUniqueKeyCache(idCache, s.system.CacheStore).
+				BuildCachedRepository()), ShouldBeNil)
+			So(registry.Register("authorBook", s.system.builder().
+				WithDataWriter(writer).
+				WithNonUniqueKeyCache(authorIdCache, s.system.CacheStore).
+				BuildCachedRepository()), ShouldBeNil)
+
+			Convey("When the registry is shut down", func() {
+				err := registry.Shutdown(ctx)
+
+				Convey("Then every repository should be shut down, "+
+					"And the failure should be reported for its repository", func() {
+					So(failingWriter.shutdowns, ShouldEqual, 1)
+					So(writer.shutdowns, ShouldEqual, 1)
+					var registryErr *datarepo.RegistryError
+					So(errors.As(err, &registryErr), ShouldBeTrue)
+					So(registryErr.Errors, ShouldResemble, map[string]error{"book": errUnhealthy})
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryRegistryTestSuite) SetupTest() {
+	s.system = startSystemForTests()
+}
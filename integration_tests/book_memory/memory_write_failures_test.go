@@ -0,0 +1,140 @@
+package book_memory
+
+import (
+	"errors"
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/integration_tests/model"
+	"github.com/merlinapp/datarepo-go/retryqueue/memory"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type MemoryWriteFailuresTestSuite struct {
+	suite.Suite
+	system *testSystem
+	store  *failingCacheStore
+	queue  datarepo.RetryQueue
+}
+
+func TestMemoryWriteFailuresTestSuite(t *testing.T) {
+	suite.Run(t, new(MemoryWriteFailuresTestSuite))
+}
+
+// the evictions are only replayed when the tests ask for them
+var manualRetryOptions = datarepo.RetryOptions{PollInterval: time.Hour}
+
+func (s *MemoryWriteFailuresTestSuite) TestFailedUpdateOfCacheEntry() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Update a book when the cache store can't store values", s.T(), func() {
+		Convey("Given a repository with a retry queue whose cache by ID is updated after writes, "+
+			"And a cached book", func() {
+			cache := idCache
+			cache.WritePolicy = datarepo.WritePolicyUpdate
+			repo := s.system.builder().
+				WithUniqueKeyCache(cache, s.store).
+				WithRetryQueue(s.queue, manualRetryOptions).
+				BuildCachedRepository()
+			defer repo.(datarepo.Shutdowner).Shutdown(ctx)
+			So(repo.Create(ctx, &model.Book{ID: "book-1", AuthorID: "author-1", Status: "draft"}), ShouldBeNil)
+
+			Convey("When the book is updated while the store fails to store values", func() {
+				s.store.fail(true, false)
+				err := repo.Update(ctx, &model.Book{ID: "book-1", AuthorID: "author-1", Status: "published"})
+				s.store.fail(false, false)
+
+				Convey("Then a CacheMaintenanceError should be returned, "+
+					"And the stale entry should be deleted, "+
+					"And its eviction should be enqueued in the retry queue", func() {
+					var maintenanceErr *datarepo.CacheMaintenanceError
+					So(errors.As(err, &maintenanceErr), ShouldBeTrue)
+					So(maintenanceErr.Retried, ShouldBeTrue)
+					result, err := repo.FindByKey(ctx, "ID", "book-1", datarepo.CacheOnly())
+					So(err, ShouldBeNil)
+					So(result.IsEmpty(), ShouldBeTrue)
+					due, err := s.queue.Due(time.Now().Add(time.Hour), 0)
+					So(err, ShouldBeNil)
+					So(due, ShouldHaveLength, 1)
+					So(due[0].Key, ShouldEqual, "b:book-1")
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryWriteFailuresTestSuite) TestFailedReadOfNonUniqueEntry() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Update a book when the cache store can't read the entry of its author", s.T(), func() {
+		Convey("Given a repository with a retry queue whose cache by author is updated after writes, "+
+			"And the cached books of an author", func() {
+			cache := authorIdCache
+			cache.WritePolicy = datarepo.WritePolicyUpdate
+			repo := s.system.builder().
+				WithNonUniqueKeyCache(cache, s.store).
+				WithRetryQueue(s.queue, manualRetryOptions).
+				BuildCachedRepository()
+			defer repo.(datarepo.Shutdowner).Shutdown(ctx)
+			So(s.system.Books.store(&model.Book{ID: "book-1", AuthorID: "author-1", Status: "draft"}), ShouldBeNil)
+			_, err := repo.FindByKey(ctx, "AuthorID", "author-1")
+			So(err, ShouldBeNil)
+
+			Convey("When a book of the author is updated while the store fails to read values", func() {
+				s.store.failReads(true)
+				err := repo.Update(ctx, &model.Book{ID: "book-1", AuthorID: "author-1", Status: "published"})
+				s.store.failReads(false)
+
+				Convey("Then a CacheMaintenanceError should be returned, "+
+					"And the eviction of the entry of the author should be enqueued in the retry queue", func() {
+					var maintenanceErr *datarepo.CacheMaintenanceError
+					So(errors.As(err, &maintenanceErr), ShouldBeTrue)
+					So(maintenanceErr.Retried, ShouldBeTrue)
+					due, err := s.queue.Due(time.Now().Add(time.Hour), 0)
+					So(err, ShouldBeNil)
+					So(due, ShouldHaveLength, 1)
+					So(due[0].Key, ShouldEqual, "a:author-1")
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryWriteFailuresTestSuite) TestFailedDelayedEviction() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: The delayed eviction of a cache using the delayed double delete write policy fails", s.T(), func() {
+		Convey("Given a repository with a retry queue whose cache by ID is evicted twice after writes, "+
+			"And a book that was created", func() {
+			cache := idCache
+			cache.WritePolicy = datarepo.WritePolicyDelayedDoubleDelete
+			cache.DoubleDeleteDelay = time.Hour
+			repo := s.system.builder().
+				WithUniqueKeyCache(cache, s.store).
+				WithRetryQueue(s.queue, manualRetryOptions).
+				BuildCachedRepository()
+			So(repo.Create(ctx, &model.Book{ID: "book-1", AuthorID: "author-1"}), ShouldBeNil)
+
+			Convey("When the pending eviction is performed while the store fails to delete entries", func() {
+				s.store.fail(false, true)
+				err := repo.(datarepo.Shutdowner).Shutdown(ctx)
+				s.store.fail(false, false)
+
+				Convey("Then the eviction should be enqueued in the retry queue", func() {
+					So(err, ShouldBeNil)
+					due, err := s.queue.Due(time.Now().Add(time.Hour), 0)
+					So(err, ShouldBeNil)
+					So(due, ShouldHaveLength, 1)
+					So(due[0].Key, ShouldEqual, "b:book-1")
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryWriteFailuresTestSuite) SetupTest() {
+	s.system = startSystemForTests()
+	s.store = &failingCacheStore{MetadataCacheStore: s.system.CacheStore}
+	s.queue = memory.NewRetryQueue()
+}
@@ -0,0 +1,182 @@
+package book_memory
+
+import (
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/integration_tests/model"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type MemoryWritePolicyTestSuite struct {
+	suite.Suite
+	system *testSystem
+}
+
+func TestMemoryWritePolicyTestSuite(t *testing.T) {
+	suite.Run(t, new(MemoryWritePolicyTestSuite))
+}
+
+var (
+	bookTypeIdCache = datarepo.NonUniqueKeyCacheDefinition{
+		KeyPrefix:       "t:",
+		KeyFieldName:    "BookTypeID",
+		SubKeyFieldName: "ID",
+		Expiration:      5 * time.Minute,
+	}
+	statusCache = datarepo.NonUniqueKeyCacheDefinition{
+		KeyPrefix:       "s:",
+		KeyFieldName:    "Status",
+		SubKeyFieldName: "ID",
+		Expiration:      5 * time.Minute,
+	}
+)
+
+func (s *MemoryWritePolicyTestSuite) TestWritePolicyPerCache() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Update a book cached by caches with different write policies", s.T(), func() {
+		Convey("Given a cache by ID that is updated after writes, "+
+			"And a cache by author that is evicted after writes, "+
+			"And a cache by book type that ignores writes, "+
+			"And a cache by status that is evicted twice after writes, "+
+			"And a book cached by every cache", func() {
+			byId := idCache
+			byId.WritePolicy = datarepo.WritePolicyUpdate
+			byAuthor := authorIdCache
+			byAuthor.WritePolicy = datarepo.WritePolicyEvict
+			byBookType := bookTypeIdCache
+			byBookType.WritePolicy = datarepo.WritePolicyIgnore
+			byStatus := statusCache
+			byStatus.WritePolicy = datarepo.WritePolicyDelayedDoubleDelete
+			byStatus.DoubleDeleteDelay = time.Hour
+			repo := s.system.builder().
+				WithUniqueKeyCache(byId, s.system.CacheStore).
+				WithNonUniqueKeyCache(byAuthor, s.system.CacheStore).
+				WithNonUniqueKeyCache(byBookType, s.system.CacheStore).
+				WithNonUniqueKeyCache(byStatus, s.system.CacheStore).
+				BuildCachedRepository()
+			book := &model.Book{ID: "book-1", AuthorID: "author-1", BookTypeID: "type-1", Status: "published"}
+			So(s.system.Books.store(book), ShouldBeNil)
+			_, err := repo.FindByKey(ctx, "ID", "book-1")
+			So(err, ShouldBeNil)
+			_, err = repo.FindByKey(ctx, "AuthorID", "author-1")
+			So(err, ShouldBeNil)
+			_, err = repo.FindByKey(ctx, "BookTypeID", "type-1")
+			So(err, ShouldBeNil)
+			_, err = repo.FindByKey(ctx, "Status", "published")
+			So(err, ShouldBeNil)
+
+			Convey("When the book type of the book is updated", func() {
+				updated := *book
+				updated.BookTypeID = "type-2"
+				updateErr := repo.Update(ctx, &updated)
+
+				Convey("Then the cache by ID should hold the updated book, "+
+					"And the entry of the author should be evicted, "+
+					"And the entry of the book type should still hold the previous book, "+
+					"And the entry of the status should be evicted", func() {
+					So(updateErr, ShouldBeNil)
+					var cached model.Book
+					found, _ := s.system.CacheStore.Get(ctx, "b:book-1", &cached)
+					So(found, ShouldBeTrue)
+					So(cached.BookTypeID, ShouldEqual, "type-2")
+
+					var books []model.Book
+					found, _ = s.system.CacheStore.Get(ctx, "a:author-1", &books)
+					So(found, ShouldBeFalse)
+
+					found, _ = s.system.CacheStore.Get(ctx, "t:type-1", &books)
+					So(found, ShouldBeTrue)
+					So(books, ShouldHaveLength, 1)
+					So(books[0].BookTypeID, ShouldEqual, "type-1")
+
+					found, _ = s.system.CacheStore.Get(ctx, "s:published", &books)
+					So(found, ShouldBeFalse)
+				})
+			})
+
+			Convey("When the book is updated, "+
+				"And a concurrent read caches the previous book by status before the second eviction", func() {
+				updated := *book
+				So(repo.Update(ctx, &updated), ShouldBeNil)
+				s.system.CacheStore.Set(ctx, "s:published", []model.Book{*book}, time.Minute)
+				var books []model.Book
+				foundBeforeEviction, _ := s.system.CacheStore.Get(ctx, "s:published", &books)
+				shutdownErr := repo.(datarepo.Shutdowner).Shutdown(ctx)
+
+				Convey("Then the second eviction should remove the entry cached by the read, "+
+					"And the shutdown should perform the pending eviction immediately", func() {
+					So(foundBeforeEviction, ShouldBeTrue)
+					So(shutdownErr, ShouldBeNil)
+					found, _ := s.system.CacheStore.Get(ctx, "s:published", &books)
+					So(found, ShouldBeFalse)
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryWritePolicyTestSuite) TestDefaultWritePolicy() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Create books cached by caches without their own write policy", s.T(), func() {
+		Convey("Given a repository that evicts caches after writes, "+
+			"And a cache by ID without a write policy, "+
+			"And a cache by author that is updated after writes, "+
+			"And a cached book of the author", func() {
+			byAuthor := authorIdCache
+			byAuthor.WritePolicy = datarepo.WritePolicyUpdate
+			repo := s.system.builder().
+				EvictAfterWrite(true).
+				WithUniqueKeyCache(idCache, s.system.CacheStore).
+				WithNonUniqueKeyCache(byAuthor, s.system.CacheStore).
+				BuildCachedRepository()
+			So(s.system.Books.store(&model.Book{ID: "book-0", AuthorID: "author-1"}), ShouldBeNil)
+			_, err := repo.FindByKey(ctx, "AuthorID", "author-1")
+			So(err, ShouldBeNil)
+
+			Convey("When another book of the author is created", func() {
+				createErr := repo.Create(ctx, &model.Book{ID: "book-1", AuthorID: "author-1"})
+
+				Convey("Then the cache by ID should use the policy of the repository and not cache the book, "+
+					"And the cache by author should use its own policy and add the book to the cached entry", func() {
+					So(createErr, ShouldBeNil)
+					var cached model.Book
+					found, _ := s.system.CacheStore.Get(ctx, "b:book-1", &cached)
+					So(found, ShouldBeFalse)
+
+					var books []model.Book
+					found, _ = s.system.CacheStore.Get(ctx, "a:author-1", &books)
+					So(found, ShouldBeTrue)
+					So(books, ShouldHaveLength, 2)
+					So(books[1].ID, ShouldEqual, "book-1")
+				})
+			})
+		})
+
+		Convey("Given a repository that doesn't evict caches after writes, "+
+			"And a cache by ID without a write policy", func() {
+			repo := s.system.builder().
+				WithUniqueKeyCache(idCache, s.system.CacheStore).
+				BuildCachedRepository()
+
+			Convey("When a book is created", func() {
+				createErr := repo.Create(ctx, &model.Book{ID: "book-1", AuthorID: "author-1"})
+
+				Convey("Then the cache by ID should be updated with the book", func() {
+					So(createErr, ShouldBeNil)
+					var cached model.Book
+					found, _ := s.system.CacheStore.Get(ctx, "b:book-1", &cached)
+					So(found, ShouldBeTrue)
+					So(cached.AuthorID, ShouldEqual, "author-1")
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryWritePolicyTestSuite) SetupTest() {
+	s.system = startSystemForTests()
+}
@@ -0,0 +1,246 @@
+package book_memory
+
+import (
+	"context"
+	"errors"
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/cachestore/memory"
+	"github.com/merlinapp/datarepo-go/integration_tests/model"
+	"sync"
+	"time"
+)
+
+// The components used by the tests, with books kept in memory instead of a database
+type testSystem struct {
+	Ctx        context.Context
+	Books      *bookSource
+	CacheStore datarepo.MetadataCacheStore
+}
+
+func startSystemForTests() *testSystem {
+	return &testSystem{
+		Ctx:        context.Background(),
+		Books:      newBookSource(),
+		CacheStore: memory.NewFreeCacheInMemoryStore(1024 * 1024),
+	}
+}
+
+// Creates a Builder of a repository of books that reads and writes the books of the system
+func (s *testSystem) builder() datarepo.Builder {
+	return datarepo.CachedRepositoryBuilder(&model.Book{}).
+		WithUniqueKeyDataFetcher(&uniqueBookFetcher{s.Books}).
+		WithNonUniqueKeyDataFetcher(&nonUniqueBookFetcher{s.Books}).
+		WithDataWriter(s.Books)
+}
+
+var (
+	idCache = datarepo.UniqueKeyCacheDefinition{
+		KeyPrefix:    "b:",
+		KeyFieldName: "ID",
+		Expiration:   5 * time.Minute,
+	}
+	authorIdCache = datarepo.NonUniqueKeyCacheDefinition{
+		KeyPrefix:       "a:",
+		KeyFieldName:    "AuthorID",
+		SubKeyFieldName: "ID",
+		Expiration:      5 * time.Minute,
+	}
+)
+
+// Error returned by a failingCacheStore when it's asked to fail
+var errStoreUnavailable = errors.New("the cache store is unavailable")
+
+// A CacheStore that fails to read or store values or delete entries on demand, delegating to another store otherwise
+type failingCacheStore struct {
+	datarepo.MetadataCacheStore
+	mu          sync.Mutex
+	failGets    bool
+	failSets    bool
+	failDeletes bool
+}
+
+// Makes the store fail the following Set and Delete operations, or stop failing them
+func (s *failingCacheStore) fail(sets bool, deletes bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failSets = sets
+	s.failDeletes = deletes
+}
+
+// Makes the store fail the following Get operations, or stop failing them
+func (s *failingCacheStore) failReads(gets bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failGets = gets
+}
+
+func (s *failingCacheStore) Get(ctx context.Context, key string, out interface{}) (bool, error) {
+	s.mu.Lock()
+	failGets := s.failGets
+	s.mu.Unlock()
+	if failGets {
+		return false, errStoreUnavailable
+	}
+	return s.MetadataCacheStore.Get(ctx, key, out)
+}
+
+func (s *failingCacheStore) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) {
+	_ = s.TrySet(ctx, key, value, expiration)
+}
+
+func (s *failingCacheStore) TrySet(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	s.mu.Lock()
+	failSets := s.failSets
+	s.mu.Unlock()
+	if failSets {
+		return errStoreUnavailable
+	}
+	return datarepo.TrySet(ctx, s.MetadataCacheStore, key, value, expiration)
+}
+
+func (s *failingCacheStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	failDeletes := s.failDeletes
+	s.mu.Unlock()
+	if failDeletes {
+		return errStoreUnavailable
+	}
+	return s.MetadataCacheStore.Delete(ctx, key)
+}
+
+// Books kept in memory, which is both the DataWriter and the data source of the DataFetchers of the tests
+type bookSource struct {
+	mu    sync.Mutex
+	books map[string]*model.Book
+	// ids in the order the books were created, so that lists are returned in a stable order
+	ids   []string
+	reads int
+}
+
+func newBookSource() *bookSource {
+	return &bookSource{books: make(map[string]*model.Book)}
+}
+
+func (s *bookSource) Create(ctx context.Context, value interface{}) error {
+	return s.store(value.(*model.Book))
+}
+
+func (s *bookSource) Update(ctx context.Context, value interface{}) error {
+	return s.store(value.(*model.Book))
+}
+
+func (s *bookSource) PartialUpdate(ctx context.Context, value interface{}) error {
+	return s.store(value.(*model.Book))
+}
+
+// Stores a copy of the book, as a database would
+func (s *bookSource) store(book *model.Book) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.books[book.ID]; !ok {
+		s.ids = append(s.ids, book.ID)
+	}
+	stored := *book
+	s.books[book.ID] = &stored
+	return nil
+}
+
+// Deletes the book without going through a repository
+func (s *bookSource) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.books, id)
+}
+
+// Returns the number of FindByKeys invocations of the DataFetchers
+func (s *bookSource) Reads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reads
+}
+
+// returns copies of the books whose field matches the id, in the order they were created
+func (s *bookSource) find(keyFieldName string, id interface{}) []*model.Book {
+	var books []*model.Book
+	for _, bookId := range s.ids {
+		book, ok := s.books[bookId]
+		if !ok {
+			continue
+		}
+		var value interface{}
+		switch keyFieldName {
+		case "ID":
+			value = book.ID
+		case "AuthorID":
+			value = book.AuthorID
+		case "BookTypeID":
+			value = book.BookTypeID
+		case "Status":
+			value = book.Status
+		case "ISBN":
+			if book.ISBN == nil {
+				continue
+			}
+			value = *book.ISBN
+		}
+		if value == id {
+			found := *book
+			books = append(books, &found)
+		}
+	}
+	return books
+}
+
+type uniqueBookFetcher struct {
+	*bookSource
+}
+
+func (f *uniqueBookFetcher) FindByKey(ctx context.Context, keyFieldName string, id interface{}) (datarepo.Result, error) {
+	results, err := f.FindByKeys(ctx, keyFieldName, []interface{}{id})
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+func (f *uniqueBookFetcher) FindByKeys(ctx context.Context, keyFieldName string, ids []interface{}) ([]datarepo.Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reads++
+	results := make([]datarepo.Result, len(ids))
+	for i, id := range ids {
+		if books := f.find(keyFieldName, id); len(books) > 0 {
+			results[i] = datarepo.ValueResult{Value: books[0]}
+		} else {
+			results[i] = datarepo.EmptyResult{}
+		}
+	}
+	return results, nil
+}
+
+type nonUniqueBookFetcher struct {
+	*bookSource
+}
+
+func (f *nonUniqueBookFetcher) FindByKey(ctx context.Context, keyFieldName string, id interface{}) (datarepo.Result, error) {
+	results, err := f.FindByKeys(ctx, keyFieldName, []interface{}{id})
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+func (f *nonUniqueBookFetcher) FindByKeys(ctx context.Context, keyFieldName string, ids []interface{}) ([]datarepo.Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reads++
+	results := make([]datarepo.Result, len(ids))
+	for i, id := range ids {
+		if books := f.find(keyFieldName, id); len(books) > 0 {
+			results[i] = datarepo.ValueResult{Value: &books}
+		} else {
+			results[i] = datarepo.EmptyResult{}
+		}
+	}
+	return results, nil
+}
@@ -9,7 +9,6 @@ import (
 	"github.com/merlinapp/datarepo-go/integration_tests"
 	"github.com/merlinapp/datarepo-go/integration_tests/bookcategory_gorm_numericid/testdomain"
 	"github.com/merlinapp/datarepo-go/integration_tests/model"
-	"github.com/merlinapp/datarepo-go/repo/gorm"
 	"time"
 )
 
@@ -26,7 +25,7 @@ func startSystemForIntegrationTests() *testdomain.SystemInstance {
 	cacheStore := memory.NewFreeCacheInMemoryStore(1 * 1024 * 1024)
 	statsCacheStore := stats.NewStatsCacheStore(cacheStore)
 
-	bookCategoryRepo := gorm.CachedRepositoryBuilder(db, &model.BookCategory{}).
+	bookCategoryRepo := model.NewBookCategoryGormRepositoryBuilder(db).
 		WithUniqueKeyCache(bookCategoryCache, statsCacheStore).
 		BuildCachedRepository()
 
@@ -11,3 +11,10 @@ package integration_tests
 // GORM based repository and an in-memory cache.
 // - bookcategory_gorm_numericid: Tests that use the BookCategory entity which has a numeric id, using
 // a GORM based repository and an in-memory cache.
+// - benchmarks: Benchmarks of the hot paths of the library, like reading cached values or injecting
+// results, using an in-memory cache and data fetchers that don't need a database.
+// - book_memory: Tests of the Book entity that don't need a database, using an in-memory cache and data
+// fetchers that read books kept in memory.
+// - retryqueue: Tests of the RetryQueue implementations, which don't need a database or a cache.
+// - typed_memory: Tests of the Review entity and the typed repository, field accessors and codec generated for
+// it by datarepo-gen, using an in-memory cache and data fetchers that read reviews kept in memory.
@@ -7,6 +7,7 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jinzhu/gorm"
 	"github.com/merlinapp/datarepo-go/integration_tests/model"
+	repogorm "github.com/merlinapp/datarepo-go/repo/gorm"
 	"log"
 	"os"
 	"time"
@@ -78,4 +79,5 @@ func autoMigrate(db *gorm.DB) {
 	db.AutoMigrate(&model.Book{})
 	db.AutoMigrate(&model.BookType{})
 	db.AutoMigrate(&model.BookCategory{})
+	_ = repogorm.AutoMigrateOutbox(db, "")
 }
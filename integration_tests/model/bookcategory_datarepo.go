@@ -0,0 +1,79 @@
+// Code generated by datarepo-gen. DO NOT EDIT.
+
+package model
+
+import (
+	"context"
+
+	"github.com/jinzhu/gorm"
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/drreflect"
+	repogorm "github.com/merlinapp/datarepo-go/repo/gorm"
+)
+
+// Accessors of the fields of BookCategory, used instead of reflection
+var bookCategoryFields = drreflect.FieldAccessors[BookCategory]{
+	"ID":   func(v *BookCategory) interface{} { return v.ID },
+	"Name": func(v *BookCategory) interface{} { return v.Name },
+}
+
+// Names of the fields of BookCategory in its JSON encoding
+var bookCategoryJSONNames = []string{"id", "name"}
+
+// Codec of BookCategory, which produces and accepts the same JSON as encoding/json without reflection
+var bookCategoryCodec = drreflect.Codec[BookCategory]{
+	Append: func(buf []byte, v *BookCategory) ([]byte, error) {
+		buf = append(buf, '{')
+		start := len(buf)
+		buf = drreflect.AppendJSONKey(buf, start, `"id":`)
+		buf = drreflect.AppendJSONInt(buf, v.ID)
+		buf = drreflect.AppendJSONKey(buf, start, `"name":`)
+		buf = drreflect.AppendJSONString(buf, v.Name)
+		return append(buf, '}'), nil
+	},
+	Decode: func(data []byte, v *BookCategory) error {
+		return drreflect.DecodeJSONObject(data, v, bookCategoryJSONNames, func(name string, value []byte) error {
+			switch name {
+			case "id":
+				return drreflect.DecodeJSONInt(value, &v.ID)
+			case "name":
+				return drreflect.DecodeJSONString(value, &v.Name)
+			}
+			return nil
+		})
+	},
+}
+
+func init() {
+	drreflect.Register(bookCategoryFields)
+	drreflect.RegisterCodec(bookCategoryCodec)
+}
+
+// Typed repository of BookCategory values
+type BookCategoryRepository struct {
+	*datarepo.TypedRepository[BookCategory]
+}
+
+// Creates a BookCategoryRepository for the provided repository
+func NewBookCategoryRepository(repo datarepo.CachedRepository) (*BookCategoryRepository, error) {
+	typed, err := datarepo.NewTypedRepository[BookCategory](repo)
+	if err != nil {
+		return nil, err
+	}
+	return &BookCategoryRepository{typed}, nil
+}
+
+// Retrieves the BookCategory with the provided ID. datarepo.ErrNotFound is returned if there is none
+func (r *BookCategoryRepository) GetByID(ctx context.Context, id int, options ...datarepo.ReadOption) (*BookCategory, error) {
+	return r.Get(ctx, "ID", id, options...)
+}
+
+// Retrieves the BookCategory values with the provided ID values, nil for the ones that don't exist
+func (r *BookCategoryRepository) GetManyByID(ctx context.Context, ids []int, options ...datarepo.ReadOption) ([]*BookCategory, error) {
+	return r.GetMany(ctx, "ID", ids, options...)
+}
+
+// Creates a new Builder for a GORM based cached repository of BookCategory values that uses typed data fetchers
+func NewBookCategoryGormRepositoryBuilder(db *gorm.DB) datarepo.Builder {
+	return repogorm.TypedCachedRepositoryBuilder(db, bookCategoryFields)
+}
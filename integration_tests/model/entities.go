@@ -1,15 +1,21 @@
 package model
 
+import "time"
+
+//go:generate go run github.com/merlinapp/datarepo-go/cmd/datarepo-gen -type BookCategory -unique ID -gorm
+//go:generate go run github.com/merlinapp/datarepo-go/cmd/datarepo-gen -type Review -unique ID -nonunique BookID
+
 type Author struct {
 	ID   string `json:"id" gorm:"primary_key" sql:"type:CHAR(36)"`
 	Name string `json:"name" sql:"type:CHAR(36)"`
 }
 
 type Book struct {
-	ID         string `json:"id" gorm:"primary_key" sql:"type:CHAR(36)"`
-	AuthorID   string `json:"authorId" sql:"type:CHAR(36)"`
-	BookTypeID string `json:"bookTypeId" sql:"type:CHAR(36)"`
-	Status     string `json:"status"`
+	ID         string  `json:"id" gorm:"primary_key" sql:"type:CHAR(36)"`
+	AuthorID   string  `json:"authorId" sql:"type:CHAR(36)"`
+	BookTypeID string  `json:"bookTypeId" sql:"type:CHAR(36)"`
+	Status     string  `json:"status"`
+	ISBN       *string `json:"isbn,omitempty" sql:"type:CHAR(36)"`
 }
 
 type BookType struct {
@@ -21,3 +27,17 @@ type BookCategory struct {
 	ID   int    `json:"id" gorm:"primary_key" sql:"type:int(11)"`
 	Name string `json:"name"`
 }
+
+// A review of a book, whose fields cover the kinds of fields serialized by the codecs of datarepo-gen
+type Review struct {
+	ID       uint64   `json:"id" gorm:"primary_key"`
+	BookID   string   `json:"bookId" sql:"type:CHAR(36)"`
+	Rating   float32  `json:"rating"`
+	Title    *string  `json:"title,omitempty"`
+	Votes    *int     `json:"votes"`
+	Verified bool     `json:"verified,omitempty"`
+	Tags     []string `json:"tags,omitempty" gorm:"-"`
+	// Unlike the other fields, the JSON name of CreatedAt isn't defined
+	CreatedAt time.Time
+	Notes     string `json:"-"`
+}
@@ -0,0 +1,136 @@
+// Code generated by datarepo-gen. DO NOT EDIT.
+
+package model
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/drreflect"
+)
+
+// Accessors of the fields of Review, used instead of reflection
+var reviewFields = drreflect.FieldAccessors[Review]{
+	"ID":     func(v *Review) interface{} { return v.ID },
+	"BookID": func(v *Review) interface{} { return v.BookID },
+	"Rating": func(v *Review) interface{} { return v.Rating },
+	"Title": func(v *Review) interface{} {
+		if v.Title == nil {
+			return nil
+		}
+		return *v.Title
+	},
+	"Votes": func(v *Review) interface{} {
+		if v.Votes == nil {
+			return nil
+		}
+		return *v.Votes
+	},
+	"Verified":  func(v *Review) interface{} { return v.Verified },
+	"Tags":      func(v *Review) interface{} { return v.Tags },
+	"CreatedAt": func(v *Review) interface{} { return v.CreatedAt },
+	"Notes":     func(v *Review) interface{} { return v.Notes },
+}
+
+// Names of the fields of Review in its JSON encoding
+var reviewJSONNames = []string{"id", "bookId", "rating", "title", "votes", "verified", "tags", "CreatedAt"}
+
+// Codec of Review, which produces and accepts the same JSON as encoding/json without reflection
+var reviewCodec = drreflect.Codec[Review]{
+	Append: func(buf []byte, v *Review) ([]byte, error) {
+		var err error
+		buf = append(buf, '{')
+		start := len(buf)
+		buf = drreflect.AppendJSONKey(buf, start, `"id":`)
+		buf = drreflect.AppendJSONUint(buf, v.ID)
+		buf = drreflect.AppendJSONKey(buf, start, `"bookId":`)
+		buf = drreflect.AppendJSONString(buf, v.BookID)
+		buf = drreflect.AppendJSONKey(buf, start, `"rating":`)
+		if buf, err = drreflect.AppendJSONFloat(buf, v.Rating); err != nil {
+			return nil, err
+		}
+		if v.Title != nil {
+			buf = drreflect.AppendJSONKey(buf, start, `"title":`)
+			buf = drreflect.AppendJSONString(buf, *v.Title)
+		}
+		buf = drreflect.AppendJSONKey(buf, start, `"votes":`)
+		if v.Votes == nil {
+			buf = append(buf, "null"...)
+		} else {
+			buf = drreflect.AppendJSONInt(buf, *v.Votes)
+		}
+		if v.Verified {
+			buf = drreflect.AppendJSONKey(buf, start, `"verified":`)
+			buf = drreflect.AppendJSONBool(buf, v.Verified)
+		}
+		if !drreflect.IsEmptyJSONValue(v.Tags) {
+			buf = drreflect.AppendJSONKey(buf, start, `"tags":`)
+			if buf, err = drreflect.AppendJSON(buf, &v.Tags); err != nil {
+				return nil, err
+			}
+		}
+		buf = drreflect.AppendJSONKey(buf, start, `"CreatedAt":`)
+		if buf, err = drreflect.AppendJSON(buf, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		return append(buf, '}'), nil
+	},
+	Decode: func(data []byte, v *Review) error {
+		return drreflect.DecodeJSONObject(data, v, reviewJSONNames, func(name string, value []byte) error {
+			switch name {
+			case "id":
+				return drreflect.DecodeJSONUint(value, &v.ID)
+			case "bookId":
+				return drreflect.DecodeJSONString(value, &v.BookID)
+			case "rating":
+				return drreflect.DecodeJSONFloat(value, &v.Rating)
+			case "title":
+				return drreflect.DecodeJSONPtr(value, &v.Title, drreflect.DecodeJSONString[string])
+			case "votes":
+				return drreflect.DecodeJSONPtr(value, &v.Votes, drreflect.DecodeJSONInt[int])
+			case "verified":
+				return drreflect.DecodeJSONBool(value, &v.Verified)
+			case "tags":
+				return json.Unmarshal(value, &v.Tags)
+			case "CreatedAt":
+				return json.Unmarshal(value, &v.CreatedAt)
+			}
+			return nil
+		})
+	},
+}
+
+func init() {
+	drreflect.Register(reviewFields)
+	drreflect.RegisterCodec(reviewCodec)
+}
+
+// Typed repository of Review values
+type ReviewRepository struct {
+	*datarepo.TypedRepository[Review]
+}
+
+// Creates a ReviewRepository for the provided repository
+func NewReviewRepository(repo datarepo.CachedRepository) (*ReviewRepository, error) {
+	typed, err := datarepo.NewTypedRepository[Review](repo)
+	if err != nil {
+		return nil, err
+	}
+	return &ReviewRepository{typed}, nil
+}
+
+// Retrieves the Review with the provided ID. datarepo.ErrNotFound is returned if there is none
+func (r *ReviewRepository) GetByID(ctx context.Context, id uint64, options ...datarepo.ReadOption) (*Review, error) {
+	return r.Get(ctx, "ID", id, options...)
+}
+
+// Retrieves the Review values with the provided ID values, nil for the ones that don't exist
+func (r *ReviewRepository) GetManyByID(ctx context.Context, ids []uint64, options ...datarepo.ReadOption) ([]*Review, error) {
+	return r.GetMany(ctx, "ID", ids, options...)
+}
+
+// Retrieves the Review values with the provided BookID
+func (r *ReviewRepository) GetListByBookID(ctx context.Context, id string, options ...datarepo.ReadOption) ([]*Review, error) {
+	return r.GetList(ctx, "BookID", id, options...)
+}
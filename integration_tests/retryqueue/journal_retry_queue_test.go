@@ -0,0 +1,163 @@
+package retryqueue
+
+import (
+	"bufio"
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/retryqueue/file"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type JournalRetryQueueTestSuite struct {
+	suite.Suite
+	path string
+}
+
+func TestJournalRetryQueueTestSuite(t *testing.T) {
+	suite.Run(t, new(JournalRetryQueueTestSuite))
+}
+
+func (s *JournalRetryQueueTestSuite) TestLoadPendingEvictions() {
+	Convey("Scenario: Reopen a journal with pending evictions", s.T(), func() {
+		Convey("Given a journal where three evictions were enqueued, one acknowledged and one retried", func() {
+			queue, err := file.NewJournalRetryQueue(s.path)
+			So(err, ShouldBeNil)
+			now := time.Now()
+			So(queue.Enqueue(eviction("b:1", now)), ShouldBeNil)
+			So(queue.Enqueue(eviction("b:2", now)), ShouldBeNil)
+			So(queue.Enqueue(eviction("b:3", now)), ShouldBeNil)
+			due, _ := queue.Due(now, 0)
+			So(queue.Ack(due[0]), ShouldBeNil)
+			retried := due[1]
+			retried.Attempts = 2
+			retried.NextAttempt = now.Add(time.Minute)
+			So(queue.Retry(retried), ShouldBeNil)
+			So(queue.Close(), ShouldBeNil)
+
+			Convey("When the journal is opened again", func() {
+				reopened, err := file.NewJournalRetryQueue(s.path)
+				So(err, ShouldBeNil)
+				defer reopened.Close()
+				dueNow, _ := reopened.Due(now, 0)
+				dueLater, _ := reopened.Due(now.Add(time.Hour), 0)
+
+				Convey("Then only the pending evictions should be loaded, with the state of their last retry, "+
+					"And new evictions should get new IDs", func() {
+					So(dueNow, ShouldHaveLength, 1)
+					So(dueNow[0].Key, ShouldEqual, "b:3")
+					So(dueLater, ShouldHaveLength, 2)
+					So(dueLater[1].Key, ShouldEqual, "b:2")
+					So(dueLater[1].Attempts, ShouldEqual, 2)
+					So(reopened.Enqueue(eviction("b:4", now)), ShouldBeNil)
+					all, _ := reopened.Due(now, 0)
+					So(all, ShouldHaveLength, 2)
+					So(all[1].Key, ShouldEqual, "b:4")
+					So(all[1].ID, ShouldBeGreaterThan, dueNow[0].ID)
+				})
+			})
+		})
+	})
+}
+
+func (s *JournalRetryQueueTestSuite) TestTornRecord() {
+	Convey("Scenario: Open a journal whose last record was partially written", s.T(), func() {
+		Convey("Given a journal with a pending eviction followed by a truncated record", func() {
+			queue, err := file.NewJournalRetryQueue(s.path)
+			So(err, ShouldBeNil)
+			So(queue.Enqueue(eviction("b:1", time.Now())), ShouldBeNil)
+			So(queue.Close(), ShouldBeNil)
+			f, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0644)
+			So(err, ShouldBeNil)
+			_, err = f.WriteString(`{"op":"enqueue","eviction":{"ID":2,"CacheKe`)
+			So(err, ShouldBeNil)
+			So(f.Close(), ShouldBeNil)
+
+			Convey("When the journal is opened again", func() {
+				reopened, err := file.NewJournalRetryQueue(s.path)
+				So(err, ShouldBeNil)
+				defer reopened.Close()
+
+				Convey("Then the truncated record should be ignored, "+
+					"And the journal should be rewritten without it", func() {
+					due, _ := reopened.Due(time.Now(), 0)
+					So(due, ShouldHaveLength, 1)
+					So(due[0].Key, ShouldEqual, "b:1")
+					So(journalLines(s.path), ShouldEqual, 1)
+				})
+			})
+		})
+	})
+}
+
+func (s *JournalRetryQueueTestSuite) TestCompaction() {
+	Convey("Scenario: Acknowledge most of the evictions of a journal", s.T(), func() {
+		Convey("Given a journal with 1000 enqueued evictions", func() {
+			queue, err := file.NewJournalRetryQueue(s.path)
+			So(err, ShouldBeNil)
+			defer queue.Close()
+			now := time.Now()
+			for i := 0; i < 1000; i++ {
+				So(queue.Enqueue(eviction("b:"+string(rune('a'+i%26)), now)), ShouldBeNil)
+			}
+
+			Convey("When all but one of the evictions are acknowledged", func() {
+				due, _ := queue.Due(now, 0)
+				for _, e := range due[:len(due)-1] {
+					So(queue.Ack(e), ShouldBeNil)
+				}
+
+				Convey("Then the journal should be compacted to the records of the pending evictions", func() {
+					So(journalLines(s.path), ShouldBeLessThan, 1000)
+					pending, _ := queue.Due(now, 0)
+					So(pending, ShouldHaveLength, 1)
+					So(pending[0].ID, ShouldEqual, due[len(due)-1].ID)
+				})
+			})
+		})
+	})
+}
+
+func (s *JournalRetryQueueTestSuite) TestClosedJournal() {
+	Convey("Scenario: Use a journal after it was closed", s.T(), func() {
+		Convey("Given a closed journal", func() {
+			queue, err := file.NewJournalRetryQueue(s.path)
+			So(err, ShouldBeNil)
+			So(queue.Close(), ShouldBeNil)
+
+			Convey("When an eviction is enqueued", func() {
+				err := queue.Enqueue(eviction("b:1", time.Now()))
+
+				Convey("Then an error should be returned", func() {
+					So(err, ShouldNotBeNil)
+				})
+			})
+		})
+	})
+}
+
+func (s *JournalRetryQueueTestSuite) SetupTest() {
+	s.path = filepath.Join(s.T().TempDir(), "evictions.journal")
+}
+
+func eviction(key string, nextAttempt time.Time) datarepo.CacheEviction {
+	return datarepo.CacheEviction{CacheKeyPrefix: "b:", Key: key, NextAttempt: nextAttempt}
+}
+
+// returns the number of records in the journal file
+func journalLines(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return -1
+	}
+	defer f.Close()
+	lines := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines++
+	}
+	return lines
+}
@@ -0,0 +1,81 @@
+package retryqueue
+
+import (
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/retryqueue/memory"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type MemoryRetryQueueTestSuite struct {
+	suite.Suite
+	queue datarepo.RetryQueue
+}
+
+func TestMemoryRetryQueueTestSuite(t *testing.T) {
+	suite.Run(t, new(MemoryRetryQueueTestSuite))
+}
+
+func (s *MemoryRetryQueueTestSuite) TestDueEvictions() {
+	Convey("Scenario: Read the due evictions of a queue", s.T(), func() {
+		Convey("Given evictions due now, due in a minute and due a minute ago", func() {
+			now := time.Now()
+			So(s.queue.Enqueue(eviction("b:now", now)), ShouldBeNil)
+			So(s.queue.Enqueue(eviction("b:later", now.Add(time.Minute))), ShouldBeNil)
+			So(s.queue.Enqueue(eviction("b:earlier", now.Add(-time.Minute))), ShouldBeNil)
+
+			Convey("When the due evictions are read", func() {
+				due, err := s.queue.Due(now, 0)
+				limited, limitedErr := s.queue.Due(now, 1)
+
+				Convey("Then only the due evictions should be returned, the earliest first, "+
+					"And the limit should be honored, "+
+					"And every eviction should have a distinct ID", func() {
+					So(err, ShouldBeNil)
+					So(limitedErr, ShouldBeNil)
+					So(due, ShouldHaveLength, 2)
+					So(due[0].Key, ShouldEqual, "b:earlier")
+					So(due[1].Key, ShouldEqual, "b:now")
+					So(due[0].ID, ShouldNotEqual, due[1].ID)
+					So(limited, ShouldHaveLength, 1)
+					So(limited[0].Key, ShouldEqual, "b:earlier")
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryRetryQueueTestSuite) TestAckAndRetry() {
+	Convey("Scenario: Acknowledge and retry evictions", s.T(), func() {
+		Convey("Given two due evictions", func() {
+			now := time.Now()
+			So(s.queue.Enqueue(eviction("b:1", now)), ShouldBeNil)
+			So(s.queue.Enqueue(eviction("b:2", now)), ShouldBeNil)
+			due, _ := s.queue.Due(now, 0)
+
+			Convey("When the first is acknowledged and the second is retried later", func() {
+				So(s.queue.Ack(due[0]), ShouldBeNil)
+				retried := due[1]
+				retried.Attempts = 1
+				retried.NextAttempt = now.Add(time.Minute)
+				So(s.queue.Retry(retried), ShouldBeNil)
+
+				Convey("Then no eviction should be due now, "+
+					"And the retried eviction should be due after its next attempt", func() {
+					dueNow, _ := s.queue.Due(now, 0)
+					So(dueNow, ShouldBeEmpty)
+					dueLater, _ := s.queue.Due(now.Add(time.Minute), 0)
+					So(dueLater, ShouldHaveLength, 1)
+					So(dueLater[0].Key, ShouldEqual, "b:2")
+					So(dueLater[0].Attempts, ShouldEqual, 1)
+				})
+			})
+		})
+	})
+}
+
+func (s *MemoryRetryQueueTestSuite) SetupTest() {
+	s.queue = memory.NewRetryQueue()
+}
@@ -0,0 +1,165 @@
+package typed_memory
+
+import (
+	"encoding/json"
+	"github.com/merlinapp/datarepo-go/drreflect"
+	"github.com/merlinapp/datarepo-go/integration_tests/model"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/suite"
+	"math"
+	"testing"
+	"time"
+)
+
+type ReviewCodecTestSuite struct {
+	suite.Suite
+}
+
+func TestReviewCodecTestSuite(t *testing.T) {
+	suite.Run(t, new(ReviewCodecTestSuite))
+}
+
+func (s *ReviewCodecTestSuite) TestEncodeLikeEncodingJSON() {
+	title := "<b>Tom & Jerry</b>   \"quoted\" \\ \t\n ñ 日本 \x01 \xff"
+	votes := -42
+	reviews := []*model.Review{
+		{},
+		{ID: 1, BookID: "book-1", Rating: 4.5, Title: &title, Votes: &votes, Verified: true,
+			Tags: []string{"<tag>"}, CreatedAt: time.Date(2024, 5, 6, 7, 8, 9, 10, time.UTC), Notes: "hidden"},
+		{ID: math.MaxUint64, Rating: 1e-7},
+		{ID: 2, Rating: 1e21},
+		{ID: 3, Rating: -0.1, Tags: []string{}},
+		{ID: 4, Rating: float32(math.MaxFloat32)},
+	}
+
+	Convey("Scenario: Encode reviews with the codec generated by datarepo-gen", s.T(), func() {
+		for _, review := range reviews {
+			Convey("Given the review "+string(mustMarshal(review)), func() {
+				Convey("When it's encoded with the registered codec", func() {
+					encoded, ok, err := drreflect.EncodeJSON(review)
+
+					Convey("Then the result should be the same as encoding/json's", func() {
+						So(ok, ShouldBeTrue)
+						So(err, ShouldBeNil)
+						So(string(encoded), ShouldEqual, string(mustMarshal(review)))
+					})
+				})
+			})
+		}
+
+		Convey("Given a slice of reviews with a nil review", func() {
+			values := []*model.Review{reviews[1], nil, reviews[2]}
+
+			Convey("When the slice and a pointer to it are encoded with the registered codec", func() {
+				encoded, ok, err := drreflect.EncodeJSON(values)
+				encodedPtr, okPtr, errPtr := drreflect.EncodeJSON(&values)
+
+				Convey("Then the results should be the same as encoding/json's", func() {
+					So(ok && okPtr, ShouldBeTrue)
+					So(err, ShouldBeNil)
+					So(errPtr, ShouldBeNil)
+					So(string(encoded), ShouldEqual, string(mustMarshal(values)))
+					So(string(encodedPtr), ShouldEqual, string(mustMarshal(values)))
+				})
+			})
+		})
+
+		Convey("Given a review with a NaN rating", func() {
+			review := &model.Review{Rating: float32(math.NaN())}
+
+			Convey("When it's encoded with the registered codec", func() {
+				_, ok, err := drreflect.EncodeJSON(review)
+
+				Convey("Then the error of encoding/json should be returned", func() {
+					_, expectedErr := json.Marshal(review)
+					So(ok, ShouldBeTrue)
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldEqual, expectedErr.Error())
+				})
+			})
+		})
+	})
+}
+
+func (s *ReviewCodecTestSuite) TestDecodeLikeEncodingJSON() {
+	documents := []string{
+		`{}`,
+		`null`,
+		` { "id" : 7 , "bookId" : "book-1", "rating": 3.25, "title": "a \"title\" ñ", "votes": null,
+			"verified": true, "tags": ["x", "y"], "CreatedAt": "2024-05-06T07:08:09Z", "Notes": "ignored" } `,
+		`{"ID": 8, "BOOKID": "book-2", "Title": "case insensitive", "unknown": {"nested": [1, {"a": "]"}]}}`,
+		`{"id": 9, "votes": 12, "rating": 1e3, "verified": false}`,
+		`{"id": 10, "id": 11}`,
+		`{"id": -1}`,
+		`{"id": "12"}`,
+		`{"rating": "high"}`,
+		`{"votes": 1.5}`,
+		`{"id": 13,}`,
+		`[1, 2]`,
+		`{"title": null, "votes": 3}`,
+	}
+
+	Convey("Scenario: Decode reviews with the codec generated by datarepo-gen", s.T(), func() {
+		for _, document := range documents {
+			Convey("Given the JSON document "+document, func() {
+				Convey("When it's decoded with the registered codec into a review with a title", func() {
+					previous := "previous"
+					decoded := &model.Review{Title: &previous, Notes: "kept"}
+					ok, err := drreflect.DecodeJSON([]byte(document), decoded)
+
+					Convey("Then the review and the error should be the same as encoding/json's", func() {
+						previous := "previous"
+						expected := &model.Review{Title: &previous, Notes: "kept"}
+						expectedErr := json.Unmarshal([]byte(document), expected)
+						So(ok, ShouldBeTrue)
+						So(decoded, ShouldResemble, expected)
+						if expectedErr == nil {
+							So(err, ShouldBeNil)
+						} else {
+							So(err, ShouldNotBeNil)
+							So(err.Error(), ShouldEqual, expectedErr.Error())
+						}
+					})
+				})
+			})
+		}
+
+		Convey("Given a JSON array of reviews with a null review", func() {
+			document := `[{"id": 1, "bookId": "book-1"}, null, {"ID": 2, "title": "second"}]`
+
+			Convey("When it's decoded with the registered codec into a slice of reviews", func() {
+				var decoded []*model.Review
+				ok, err := drreflect.DecodeJSON([]byte(document), &decoded)
+
+				Convey("Then the slice should be the same as encoding/json's", func() {
+					var expected []*model.Review
+					So(json.Unmarshal([]byte(document), &expected), ShouldBeNil)
+					So(ok, ShouldBeTrue)
+					So(err, ShouldBeNil)
+					So(decoded, ShouldResemble, expected)
+				})
+			})
+		})
+
+		Convey("Given a nil pointer to a review", func() {
+			var review *model.Review
+
+			Convey("When a document is decoded into it with the registered codec", func() {
+				ok, err := drreflect.DecodeJSON([]byte(`{}`), review)
+
+				Convey("Then the codec shouldn't be used, so that encoding/json reports the error", func() {
+					So(ok, ShouldBeFalse)
+					So(err, ShouldBeNil)
+				})
+			})
+		})
+	})
+}
+
+func mustMarshal(v interface{}) []byte {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return encoded
+}
@@ -0,0 +1,139 @@
+package typed_memory
+
+import (
+	"context"
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/cachestore/memory"
+	"github.com/merlinapp/datarepo-go/integration_tests/model"
+	"sync"
+	"time"
+)
+
+// The components used by the tests, with reviews kept in memory instead of a database
+type testSystem struct {
+	Ctx        context.Context
+	Reviews    *reviewSource
+	CacheStore datarepo.MetadataCacheStore
+}
+
+func startSystemForTests() *testSystem {
+	return &testSystem{
+		Ctx:        context.Background(),
+		Reviews:    newReviewSource(),
+		CacheStore: memory.NewFreeCacheInMemoryStore(1024 * 1024),
+	}
+}
+
+var (
+	idCache = datarepo.UniqueKeyCacheDefinition{
+		KeyPrefix:    "r:",
+		KeyFieldName: "ID",
+		Expiration:   5 * time.Minute,
+	}
+	bookIdCache = datarepo.NonUniqueKeyCacheDefinition{
+		KeyPrefix:       "rb:",
+		KeyFieldName:    "BookID",
+		SubKeyFieldName: "ID",
+		Expiration:      5 * time.Minute,
+	}
+)
+
+// Creates the typed repository of reviews generated by datarepo-gen, reading and writing the reviews of the system
+func (s *testSystem) repository() *model.ReviewRepository {
+	repo := datarepo.CachedRepositoryBuilder(&model.Review{}).
+		WithUniqueKeyDataFetcher(&reviewFetcher{s.Reviews, true}).
+		WithNonUniqueKeyDataFetcher(&reviewFetcher{s.Reviews, false}).
+		WithDataWriter(s.Reviews).
+		WithUniqueKeyCache(idCache, s.CacheStore).
+		WithNonUniqueKeyCache(bookIdCache, s.CacheStore).
+		BuildCachedRepository()
+	typed, err := model.NewReviewRepository(repo)
+	if err != nil {
+		panic(err)
+	}
+	return typed
+}
+
+// Reviews kept in memory, which is both the DataWriter and the data source of the DataFetchers of the tests
+type reviewSource struct {
+	mu      sync.Mutex
+	reviews []*model.Review
+	reads   int
+}
+
+func newReviewSource() *reviewSource {
+	return &reviewSource{}
+}
+
+func (s *reviewSource) Create(ctx context.Context, value interface{}) error {
+	return s.store(value.(*model.Review))
+}
+
+func (s *reviewSource) Update(ctx context.Context, value interface{}) error {
+	return s.store(value.(*model.Review))
+}
+
+func (s *reviewSource) PartialUpdate(ctx context.Context, value interface{}) error {
+	return s.store(value.(*model.Review))
+}
+
+// Stores a copy of the review, as a database would
+func (s *reviewSource) store(review *model.Review) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := *review
+	for i, existent := range s.reviews {
+		if existent.ID == review.ID {
+			s.reviews[i] = &stored
+			return nil
+		}
+	}
+	s.reviews = append(s.reviews, &stored)
+	return nil
+}
+
+// Returns the number of FindByKeys invocations of the DataFetchers
+func (s *reviewSource) Reads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reads
+}
+
+// A DataFetcher of the reviews, returning a single review per key if unique is set, or lists otherwise
+type reviewFetcher struct {
+	*reviewSource
+	unique bool
+}
+
+func (f *reviewFetcher) FindByKey(ctx context.Context, keyFieldName string, id interface{}) (datarepo.Result, error) {
+	results, err := f.FindByKeys(ctx, keyFieldName, []interface{}{id})
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+func (f *reviewFetcher) FindByKeys(ctx context.Context, keyFieldName string, ids []interface{}) ([]datarepo.Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reads++
+	results := make([]datarepo.Result, len(ids))
+	for i, id := range ids {
+		var reviews []*model.Review
+		for _, review := range f.reviews {
+			if keyFieldName == "ID" && review.ID == id || keyFieldName == "BookID" && review.BookID == id {
+				found := *review
+				reviews = append(reviews, &found)
+			}
+		}
+		switch {
+		case len(reviews) == 0:
+			results[i] = datarepo.EmptyResult{}
+		case f.unique:
+			results[i] = datarepo.ValueResult{Value: reviews[0]}
+		default:
+			results[i] = datarepo.ValueResult{Value: &reviews}
+		}
+	}
+	return results, nil
+}
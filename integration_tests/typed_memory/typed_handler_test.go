@@ -0,0 +1,75 @@
+package typed_memory
+
+import (
+	"github.com/merlinapp/datarepo-go/drreflect"
+	"github.com/merlinapp/datarepo-go/integration_tests/model"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/suite"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type TypedHandlerTestSuite struct {
+	suite.Suite
+}
+
+func TestTypedHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(TypedHandlerTestSuite))
+}
+
+var reviewFieldNames = []string{"ID", "BookID", "Rating", "Title", "Votes", "Verified", "Tags", "CreatedAt", "Notes"}
+
+func (s *TypedHandlerTestSuite) TestFieldValuesMatchReflection() {
+	title := "a title"
+	votes := 3
+	reviews := []*model.Review{
+		{},
+		{ID: 1, BookID: "book-1", Rating: 4.5, Title: &title, Votes: &votes, Verified: true,
+			Tags: []string{"x"}, CreatedAt: time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC), Notes: "notes"},
+	}
+
+	Convey("Scenario: Read the fields of a registered type", s.T(), func() {
+		Convey("Given the handler registered by the code generated by datarepo-gen and a reflection based handler", func() {
+			typed := drreflect.NewReflectStructTypeHandlerFromValue(&model.Review{})
+			reflective := drreflect.NewReflectStructTypeHandler(reflect.TypeOf(model.Review{}))
+
+			Convey("Then the registered handler shouldn't use reflection", func() {
+				So(reflect.TypeOf(typed), ShouldNotEqual, reflect.TypeOf(reflective))
+			})
+
+			for _, review := range reviews {
+				Convey("When the fields of the review "+string(mustMarshal(review))+" are read by both handlers", func() {
+					Convey("Then the values read from the pointer and from the struct should be equal", func() {
+						for _, fieldName := range reviewFieldNames {
+							expected := reflective.GetFieldValue(review, fieldName)
+							So(typed.GetFieldValue(review, fieldName), ShouldResemble, expected)
+							So(typed.GetFieldValue(*review, fieldName), ShouldResemble, expected)
+						}
+					})
+				})
+			}
+
+			Convey("When the fields of a nil pointer to a review are read by both handlers", func() {
+				var review *model.Review
+
+				Convey("Then the values should be nil", func() {
+					for _, fieldName := range reviewFieldNames {
+						So(reflective.GetFieldValue(review, fieldName), ShouldBeNil)
+						So(typed.GetFieldValue(review, fieldName), ShouldBeNil)
+					}
+				})
+			})
+
+			Convey("When the type of a field is read by both handlers", func() {
+				typedType, typedOk := typed.FieldType("Votes")
+				reflectiveType, reflectiveOk := reflective.FieldType("Votes")
+
+				Convey("Then the types should be equal", func() {
+					So(typedOk && reflectiveOk, ShouldBeTrue)
+					So(typedType, ShouldEqual, reflectiveType)
+				})
+			})
+		})
+	})
+}
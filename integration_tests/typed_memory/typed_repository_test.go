@@ -0,0 +1,70 @@
+package typed_memory
+
+import (
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/integration_tests/model"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type TypedRepositoryTestSuite struct {
+	suite.Suite
+	system *testSystem
+}
+
+func TestTypedRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(TypedRepositoryTestSuite))
+}
+
+func (s *TypedRepositoryTestSuite) TestCachedValuesRoundTrip() {
+	ctx := s.system.Ctx
+
+	Convey("Scenario: Read reviews cached with the codec generated by datarepo-gen", s.T(), func() {
+		Convey("Given a repository of reviews cached in memory by ID and by book, "+
+			"And two reviews of a book", func() {
+			repo := s.system.repository()
+			title := "<b>Great</b> ñ"
+			votes := 7
+			first := &model.Review{ID: 1, BookID: "book-1", Rating: 4.5, Title: &title, Votes: &votes, Verified: true,
+				Tags: []string{"fantasy"}, CreatedAt: time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC), Notes: "not cached"}
+			second := &model.Review{ID: 2, BookID: "book-1", Rating: 1e-7}
+			So(repo.Create(ctx, first), ShouldBeNil)
+			So(repo.Create(ctx, second), ShouldBeNil)
+
+			Convey("When the reviews are read twice by ID and by book", func() {
+				_, err := repo.GetManyByID(ctx, []uint64{1, 2})
+				So(err, ShouldBeNil)
+				_, err = repo.GetListByBookID(ctx, "book-1")
+				So(err, ShouldBeNil)
+				reads := s.system.Reviews.Reads()
+				byId, err := repo.GetByID(ctx, 1)
+				So(err, ShouldBeNil)
+				byBook, err := repo.GetListByBookID(ctx, "book-1")
+				So(err, ShouldBeNil)
+
+				Convey("Then the second reads should be served by the caches, "+
+					"And the cached reviews should be equal to the stored ones except for the fields left out of JSON", func() {
+					expected := *first
+					expected.Notes = ""
+					So(s.system.Reviews.Reads(), ShouldEqual, reads)
+					So(byId, ShouldResemble, &expected)
+					So(byBook, ShouldResemble, []*model.Review{&expected, second})
+				})
+			})
+
+			Convey("When a review that doesn't exist is read", func() {
+				_, err := repo.GetByID(ctx, 3)
+
+				Convey("Then ErrNotFound should be returned", func() {
+					So(err, ShouldEqual, datarepo.ErrNotFound)
+				})
+			})
+		})
+	})
+}
+
+func (s *TypedRepositoryTestSuite) SetupTest() {
+	s.system = startSystemForTests()
+}
@@ -0,0 +1,77 @@
+// Package codec implements the serialization of cache entries shared by the cache stores of this library
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/merlinapp/datarepo-go/drreflect"
+	"reflect"
+	"time"
+)
+
+// Error returned when an entry was stored with a schema that doesn't match the type it's deserialized into,
+// for example, after a field was added to the cached struct
+var ErrSchemaMismatch = errors.New("the cache entry was stored with a different schema")
+
+// envelope used to store a value in a cache together with its metadata
+type entry struct {
+	StoredAt int64           `json:"_t"`
+	Schema   string          `json:"_s,omitempty"`
+	Value    json.RawMessage `json:"_v"`
+}
+
+// Serializes the value to JSON wrapped in an envelope that records when it was stored and the schema
+// fingerprint of its type. Values of types with a codec registered with drreflect.RegisterCodec are
+// serialized with it
+func Marshal(v interface{}, storedAt time.Time) ([]byte, error) {
+	value, ok, err := drreflect.EncodeJSON(v)
+	if !ok {
+		value, err = json.Marshal(v)
+	}
+	if err != nil {
+		return nil, err
+	}
+	e := entry{StoredAt: storedAt.UnixNano(), Value: value}
+	if v != nil {
+		e.Schema = drreflect.SchemaFingerprint(reflect.TypeOf(v))
+	}
+	return json.Marshal(e)
+}
+
+// Deserializes an entry created with Marshal into v, returning the time the value was stored.
+//
+// ErrSchemaMismatch is returned if the schema fingerprint of the entry doesn't match the type of v, unless
+// v points to an interface. Entries stored without a fingerprint or without an envelope by previous versions
+// of this library are deserialized as well, in which case a zero time is returned for the latter
+func Unmarshal(b []byte, v interface{}) (time.Time, error) {
+	var e entry
+	if err := json.Unmarshal(b, &e); err != nil || e.Value == nil {
+		return time.Time{}, json.Unmarshal(b, v)
+	}
+	if e.Schema != "" && !matchesSchema(e.Schema, v) {
+		return time.Time{}, ErrSchemaMismatch
+	}
+	if err := decodeValue(e.Value, v); err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, e.StoredAt), nil
+}
+
+// decodes the value with the codec registered for its type, if any, or with encoding/json otherwise
+func decodeValue(b []byte, v interface{}) error {
+	if ok, err := drreflect.DecodeJSON(b, v); ok {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func matchesSchema(schema string, v interface{}) bool {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() == reflect.Interface {
+		return true
+	}
+	return drreflect.SchemaFingerprint(t) == schema
+}
@@ -11,13 +11,41 @@ type ReadOnlyCachedRepository struct {
 	mock.Mock
 }
 
-// FindByKey provides a mock function with given fields: ctx, keyFieldName, id
-func (_m *ReadOnlyCachedRepository) FindByKey(ctx context.Context, keyFieldName string, id interface{}) (datarepo.Result, error) {
-	ret := _m.Called(ctx, keyFieldName, id)
+// BumpGeneration provides a mock function with given fields: ctx, keyFieldName
+func (_m *ReadOnlyCachedRepository) BumpGeneration(ctx context.Context, keyFieldName string) (int64, error) {
+	ret := _m.Called(ctx, keyFieldName)
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(context.Context, string) int64); ok {
+		r0 = rf(ctx, keyFieldName)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, keyFieldName)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindByKey provides a mock function with given fields: ctx, keyFieldName, id, options
+func (_m *ReadOnlyCachedRepository) FindByKey(ctx context.Context, keyFieldName string, id interface{}, options ...datarepo.ReadOption) (datarepo.Result, error) {
+	_va := make([]interface{}, len(options))
+	for _i := range options {
+		_va[_i] = options[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, keyFieldName, id)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
 	var r0 datarepo.Result
-	if rf, ok := ret.Get(0).(func(context.Context, string, interface{}) datarepo.Result); ok {
-		r0 = rf(ctx, keyFieldName, id)
+	if rf, ok := ret.Get(0).(func(context.Context, string, interface{}, ...datarepo.ReadOption) datarepo.Result); ok {
+		r0 = rf(ctx, keyFieldName, id, options...)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(datarepo.Result)
@@ -25,8 +53,8 @@ func (_m *ReadOnlyCachedRepository) FindByKey(ctx context.Context, keyFieldName
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string, interface{}) error); ok {
-		r1 = rf(ctx, keyFieldName, id)
+	if rf, ok := ret.Get(1).(func(context.Context, string, interface{}, ...datarepo.ReadOption) error); ok {
+		r1 = rf(ctx, keyFieldName, id, options...)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -34,13 +62,20 @@ func (_m *ReadOnlyCachedRepository) FindByKey(ctx context.Context, keyFieldName
 	return r0, r1
 }
 
-// FindByKeys provides a mock function with given fields: ctx, keyFieldName, ids
-func (_m *ReadOnlyCachedRepository) FindByKeys(ctx context.Context, keyFieldName string, ids interface{}) ([]datarepo.Result, error) {
-	ret := _m.Called(ctx, keyFieldName, ids)
+// FindByKeys provides a mock function with given fields: ctx, keyFieldName, ids, options
+func (_m *ReadOnlyCachedRepository) FindByKeys(ctx context.Context, keyFieldName string, ids interface{}, options ...datarepo.ReadOption) ([]datarepo.Result, error) {
+	_va := make([]interface{}, len(options))
+	for _i := range options {
+		_va[_i] = options[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, keyFieldName, ids)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
 	var r0 []datarepo.Result
-	if rf, ok := ret.Get(0).(func(context.Context, string, interface{}) []datarepo.Result); ok {
-		r0 = rf(ctx, keyFieldName, ids)
+	if rf, ok := ret.Get(0).(func(context.Context, string, interface{}, ...datarepo.ReadOption) []datarepo.Result); ok {
+		r0 = rf(ctx, keyFieldName, ids, options...)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]datarepo.Result)
@@ -48,8 +83,115 @@ func (_m *ReadOnlyCachedRepository) FindByKeys(ctx context.Context, keyFieldName
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string, interface{}) error); ok {
-		r1 = rf(ctx, keyFieldName, ids)
+	if rf, ok := ret.Get(1).(func(context.Context, string, interface{}, ...datarepo.ReadOption) error); ok {
+		r1 = rf(ctx, keyFieldName, ids, options...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Flush provides a mock function with given fields: ctx, keyFieldName
+func (_m *ReadOnlyCachedRepository) Flush(ctx context.Context, keyFieldName string) error {
+	ret := _m.Called(ctx, keyFieldName)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, keyFieldName)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Invalidate provides a mock function with given fields: ctx, keyFieldName, ids
+func (_m *ReadOnlyCachedRepository) Invalidate(ctx context.Context, keyFieldName string, ids ...interface{}) error {
+	_va := make([]interface{}, len(ids))
+	for _i := range ids {
+		_va[_i] = ids[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, keyFieldName)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, ...interface{}) error); ok {
+		r0 = rf(ctx, keyFieldName, ids...)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Refresh provides a mock function with given fields: ctx, keyFieldName, ids
+func (_m *ReadOnlyCachedRepository) Refresh(ctx context.Context, keyFieldName string, ids ...interface{}) ([]datarepo.Result, error) {
+	_va := make([]interface{}, len(ids))
+	for _i := range ids {
+		_va[_i] = ids[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, keyFieldName)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 []datarepo.Result
+	if rf, ok := ret.Get(0).(func(context.Context, string, ...interface{}) []datarepo.Result); ok {
+		r0 = rf(ctx, keyFieldName, ids...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]datarepo.Result)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, ...interface{}) error); ok {
+		r1 = rf(ctx, keyFieldName, ids...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Warm provides a mock function with given fields: ctx, keyFieldName, ids
+func (_m *ReadOnlyCachedRepository) Warm(ctx context.Context, keyFieldName string, ids interface{}) error {
+	ret := _m.Called(ctx, keyFieldName, ids)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, interface{}) error); ok {
+		r0 = rf(ctx, keyFieldName, ids)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// WarmAll provides a mock function with given fields: ctx, keyFieldName, pageSize, options
+func (_m *ReadOnlyCachedRepository) WarmAll(ctx context.Context, keyFieldName string, pageSize int, options ...datarepo.WarmUpOption) (datarepo.WarmUpProgress, error) {
+	_va := make([]interface{}, len(options))
+	for _i := range options {
+		_va[_i] = options[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, keyFieldName, pageSize)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 datarepo.WarmUpProgress
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, ...datarepo.WarmUpOption) datarepo.WarmUpProgress); ok {
+		r0 = rf(ctx, keyFieldName, pageSize, options...)
+	} else {
+		r0 = ret.Get(0).(datarepo.WarmUpProgress)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, int, ...datarepo.WarmUpOption) error); ok {
+		r1 = rf(ctx, keyFieldName, pageSize, options...)
 	} else {
 		r1 = ret.Error(1)
 	}
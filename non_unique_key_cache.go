@@ -11,7 +11,9 @@ type nonUniqueKeyCacheHandler struct {
 	// Name of the field that defines the subkey that will be used to compare and store elements that
 	// belong to the same key
 	subKeyFieldName string
-	subTypeHandler  drreflect.StructTypeHandler
+	// Derives the subkey of the values instead of reading the subKeyFieldName field
+	subKeyFunc     KeyFunc
+	subTypeHandler drreflect.StructTypeHandler
 }
 
 func NonUniqueKeyCache(v interface{}, cacheDef NonUniqueKeyCacheDefinition) Handler {
@@ -19,14 +21,20 @@ func NonUniqueKeyCache(v interface{}, cacheDef NonUniqueKeyCacheDefinition) Hand
 	definition := nonUniqueKeyCacheHandler{
 		baseCacheHandler{
 			keyPrefix:    cacheDef.KeyPrefix,
-			keyFieldName: cacheDef.KeyFieldName,
+			keyFieldName: cacheDef.cacheName(),
+			keyFields:    CompositeKeyFields(cacheDef.cacheName()),
+			keyFunc:      cacheDef.KeyFunc,
 			expiration:   cacheDef.Expiration,
 			typeHandler:  th.SlicePtrTypeHandler(),
 		},
 		cacheDef.SubKeyFieldName,
+		cacheDef.SubKeyFunc,
 		th,
 	}
 	definition.validateConfiguration()
+	if cacheDef.Normalized {
+		return newNormalizedNonUniqueKeyCacheHandler(definition)
+	}
 	return &definition
 }
 
@@ -35,27 +43,66 @@ func (c *nonUniqueKeyCacheHandler) SingleResultPerKey() bool {
 }
 
 func (c *nonUniqueKeyCacheHandler) DeleteValue(ctx context.Context, cacheStore CacheStore, value interface{}) error {
-	key := c.cacheKeyFromValue(value)
-	if key == c.keyPrefix {
+	key, ok := c.ValueCacheKey(value)
+	if !ok {
 		return nil
 	}
 	return cacheStore.Delete(ctx, key)
 }
 
+// Values with a nil or empty key, like a nil pointer field, aren't cached
+func (c *nonUniqueKeyCacheHandler) ValueKey(value interface{}) (interface{}, bool) {
+	key, ok := c.valueKey(c.subTypeHandler, value)
+	return key, ok && cast.ToString(key) != ""
+}
+
+func (c *nonUniqueKeyCacheHandler) ValueCacheKey(value interface{}) (string, bool) {
+	key, ok := c.ValueKey(value)
+	return c.cacheKey(key), ok
+}
+
+func (c *nonUniqueKeyCacheHandler) ValueSubKey(value interface{}) (interface{}, bool) {
+	if c.subKeyFunc != nil {
+		return c.subKeyFunc(value)
+	}
+	return c.getFieldValue(value, c.subKeyFieldName), true
+}
+
 func (c *nonUniqueKeyCacheHandler) Set(ctx context.Context, cacheStore CacheStore, value interface{}) error {
-	key := c.cacheKeyFromValue(value)
-	if key == c.keyPrefix {
+	key, ok := c.ValueCacheKey(value)
+	if !ok {
 		return nil
 	}
 	cached := c.typeHandler.NewPtrToElement()
 	found, err := cacheStore.Get(ctx, key, cached.Ptr())
+	if err != nil {
+		return err
+	}
 	if !found {
 		return nil
 	}
-	if err != nil {
+
+	return c.setInCache(ctx, cacheStore, key, value, cached.Ptr())
+}
+
+// adds the value to its cached entry unless the entry already holds a value with the same subkey
+func (c *nonUniqueKeyCacheHandler) setMissing(ctx context.Context, cacheStore CacheStore, value interface{}) error {
+	key, ok := c.ValueCacheKey(value)
+	if !ok {
+		return nil
+	}
+	cached := c.typeHandler.NewPtrToElement()
+	found, err := cacheStore.Get(ctx, key, cached.Ptr())
+	if err != nil || !found {
 		return err
 	}
-
+	subKey := c.cacheSubKey(value)
+	cachedValues := drreflect.NewReflectSlicePointerVHandler(cached.Ptr())
+	for _, cachedValue := range cachedValues.AsInterfaceSlice() {
+		if c.cacheSubKey(cachedValue) == subKey {
+			return nil
+		}
+	}
 	return c.setInCache(ctx, cacheStore, key, value, cached.Ptr())
 }
 
@@ -88,16 +135,17 @@ func (c *nonUniqueKeyCacheHandler) setInCache(ctx context.Context, cacheStore Ca
 		sliceHandler.Append(value)
 		values = sliceHandler.Element()
 	}
-	cacheStore.Set(ctx, key, values, c.expiration)
-	return nil
+	return c.setWritten(ctx, cacheStore, key, values)
 }
 
 func (c *nonUniqueKeyCacheHandler) cacheKeyFromValue(value interface{}) string {
-	return c.cacheKey(cast.ToString(c.getFieldValue(value, c.keyFieldName)))
+	key, _ := c.ValueKey(value)
+	return c.cacheKey(cast.ToString(key))
 }
 
 func (c *nonUniqueKeyCacheHandler) cacheSubKey(value interface{}) string {
-	return cast.ToString(c.getFieldValue(value, c.subKeyFieldName))
+	subKey, _ := c.ValueSubKey(value)
+	return cast.ToString(subKey)
 }
 
 func (c *nonUniqueKeyCacheHandler) getFieldValue(value interface{}, fieldName string) interface{} {
@@ -108,7 +156,7 @@ func (c *nonUniqueKeyCacheHandler) validateConfiguration() {
 	if c.keyFieldName == "" {
 		panic("A keyFieldName must be defined")
 	}
-	if c.subKeyFieldName == "" {
-		panic("A subKeyFieldName must be defined for caches of type OneToMany")
+	if c.subKeyFieldName == "" && c.subKeyFunc == nil {
+		panic("A subKeyFieldName or a subKeyFunc must be defined for caches of type OneToMany")
 	}
 }
@@ -0,0 +1,364 @@
+package datarepo
+
+import (
+	"context"
+	"github.com/merlinapp/datarepo-go/drreflect"
+	"github.com/spf13/cast"
+	"log"
+	"reflect"
+)
+
+// Non-unique key cache that stores only the ordered subkeys of the values of each key. The values are read
+// from the unique key cache of the subkeys, so each value is cached in a single place
+type normalizedNonUniqueKeyCacheHandler struct {
+	nonUniqueKeyCacheHandler
+	// handler of the slices of subkeys stored in the cache entries
+	subKeysTypeHandler drreflect.SliceTypeHandler
+	// unique key cache the values are read from, keyed by the subkeys
+	members *Cache
+}
+
+// implemented by the cache handlers that read their values from a unique key cache of the repository
+type memberCacheHandler interface {
+	setMemberCache(cache *Cache)
+}
+
+func newNormalizedNonUniqueKeyCacheHandler(handler nonUniqueKeyCacheHandler) *normalizedNonUniqueKeyCacheHandler {
+	// derived subkeys can be of any type, subkeys read from a field are stored with the type of the field
+	subKeyType := reflect.TypeOf((*interface{})(nil)).Elem()
+	if handler.subKeyFunc == nil {
+		if t, ok := handler.subTypeHandler.FieldType(handler.subKeyFieldName); ok {
+			subKeyType = t
+		}
+	}
+	return &normalizedNonUniqueKeyCacheHandler{
+		nonUniqueKeyCacheHandler: handler,
+		subKeysTypeHandler:       drreflect.NewReflectSliceTypeHandler(reflect.SliceOf(subKeyType)),
+	}
+}
+
+func (c *normalizedNonUniqueKeyCacheHandler) setMemberCache(cache *Cache) {
+	c.members = cache
+}
+
+func (c *normalizedNonUniqueKeyCacheHandler) Get(ctx context.Context, cacheStore CacheStore, key interface{}, fetcher DataFetcher, options ReadOptions) (Result, error) {
+	results, err := c.GetMulti(ctx, cacheStore, []interface{}{key}, fetcher, options)
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+func (c *normalizedNonUniqueKeyCacheHandler) GetMulti(ctx context.Context, cacheStore CacheStore, keys []interface{}, fetcher DataFetcher, options ReadOptions) ([]Result, error) {
+	if options.SkipCache {
+		return c.nonUniqueKeyCacheHandler.GetMulti(ctx, cacheStore, keys, fetcher, options)
+	}
+
+	strKeys := make([]string, len(keys))
+	for i, key := range keys {
+		strKeys[i] = c.cacheKey(key)
+	}
+	cached := c.subKeysTypeHandler.NewPtrToSlice()
+	cached.MakeSlice(0, len(keys))
+	found, metadata, err := c.getMultiFromStore(ctx, cacheStore, strKeys, cached.Ptr(), options)
+	if err != nil {
+		return nil, err
+	}
+	subKeys := make([][]interface{}, len(keys))
+	cached.ForEach(func(i int, handler drreflect.PointerVHandler) {
+		if found[i] {
+			subKeys[i] = c.subKeysTypeHandler.AsInterfaceSlice(handler.Element())
+		}
+	})
+	members, err := c.getMembers(ctx, subKeys, options)
+	if err != nil {
+		return nil, err
+	}
+
+	missingKeyMap := make(map[interface{}]int)
+	missingKeys := make([]interface{}, 0, len(keys))
+	var hits []interface{}
+	results := make([]Result, len(keys))
+	for i, key := range keys {
+		if found[i] {
+			if values, ok := c.hydrate(key, subKeys[i], members, options); ok {
+				results[i] = cachedResult(values, metadata[i], options)
+				hits = append(hits, key)
+				continue
+			}
+		}
+		if _, ok := missingKeyMap[key]; !ok {
+			missingKeyMap[key] = len(missingKeys)
+			missingKeys = append(missingKeys, key)
+		}
+		results[i] = EmptyResult{}
+	}
+	if !options.CacheOnly {
+		c.sampleReadRepair(cacheStore, fetcher, hits)
+	}
+
+	if len(missingKeys) > 0 && !options.CacheOnly {
+		missingResults, err := fetcher.FindByKeys(ctx, c.keyFieldName, missingKeys)
+		if err != nil {
+			return nil, err
+		}
+		var filled []Result
+		for i, key := range missingKeys {
+			if !missingResults[i].IsEmpty() {
+				if err := c.setEntry(ctx, cacheStore, c.cacheKey(key), missingResults[i].StoredValue()); err != nil {
+					log.Println("Error caching entry for key: ", key, "-", err)
+				}
+				filled = append(filled, missingResults[i])
+			}
+		}
+		for i, key := range keys {
+			if idx, ok := missingKeyMap[key]; ok {
+				results[i] = fetchedResult(missingResults[idx], options)
+			}
+		}
+		c.filled(ctx, filled)
+	}
+
+	return results, nil
+}
+
+func (c *normalizedNonUniqueKeyCacheHandler) Refresh(ctx context.Context, cacheStore CacheStore, keys []interface{}, fetcher DataFetcher) ([]Result, error) {
+	results, err := fetcher.FindByKeys(ctx, c.keyFieldName, keys)
+	if err != nil {
+		return nil, err
+	}
+	var filled []Result
+	for i, key := range keys {
+		strKey := c.cacheKey(key)
+		if results[i].IsEmpty() {
+			if err := cacheStore.Delete(ctx, strKey); err != nil {
+				return nil, err
+			}
+		} else {
+			if err := c.setEntry(ctx, cacheStore, strKey, results[i].StoredValue()); err != nil {
+				log.Println("Error caching entry for key: ", strKey, "-", err)
+			}
+			filled = append(filled, results[i])
+		}
+	}
+	c.filled(ctx, filled)
+	return results, nil
+}
+
+// Adds the subkey of the value to the entry of its key if the entry is cached. The value itself is only
+// stored in the unique key cache of the subkeys
+func (c *normalizedNonUniqueKeyCacheHandler) Set(ctx context.Context, cacheStore CacheStore, value interface{}) error {
+	key, ok := c.ValueCacheKey(value)
+	if !ok {
+		return nil
+	}
+	subKey, ok := c.ValueSubKey(value)
+	if !ok || subKey == nil {
+		return nil
+	}
+	cached := drreflect.NewReflectSlicePointerVHandler(c.subKeysTypeHandler.NewPtrToElement().Ptr())
+	found, err := cacheStore.Get(ctx, key, cached.Ptr())
+	if err != nil {
+		return err
+	}
+	if !found {
+		return nil
+	}
+
+	strSubKey := cast.ToString(subKey)
+	for _, cachedSubKey := range cached.AsInterfaceSlice() {
+		if cast.ToString(cachedSubKey) == strSubKey {
+			return nil
+		}
+	}
+	cached.Append(subKey)
+	return c.setWritten(ctx, cacheStore, key, cached.Ptr())
+}
+
+// stores the subkeys of the values in the entry, and the values in the unique key cache of the subkeys
+// adds the subkey of the value to its cached entry, which Set already does only if the subkey is missing
+func (c *normalizedNonUniqueKeyCacheHandler) setMissing(ctx context.Context, cacheStore CacheStore, value interface{}) error {
+	return c.Set(ctx, cacheStore, value)
+}
+
+func (c *normalizedNonUniqueKeyCacheHandler) setEntry(ctx context.Context, cacheStore CacheStore, key string, values interface{}) error {
+	subKeys := drreflect.NewReflectSlicePointerVHandler(c.subKeysTypeHandler.NewPtrToElement().Ptr())
+	subKeys.MakeSlice(0, 0)
+	drreflect.NewReflectSlicePointerVHandler(values).ForEach(func(_ int, handler drreflect.PointerVHandler) {
+		value := handler.Element()
+		subKey, ok := c.ValueSubKey(value)
+		if !ok || subKey == nil {
+			return
+		}
+		subKeys.Append(subKey)
+		if err := c.members.Set(ctx, value); err != nil {
+			log.Println("Error caching value of entry for key: ", key, "-", err)
+		}
+	})
+	return TrySet(ctx, cacheStore, key, subKeys.Ptr(), c.expiration)
+}
+
+// reads the values of the subkeys of the cached entries from the unique key cache of the subkeys
+func (c *normalizedNonUniqueKeyCacheHandler) getMembers(ctx context.Context, subKeys [][]interface{}, options ReadOptions) (map[interface{}]interface{}, error) {
+	seen := make(map[interface{}]bool)
+	var keys []interface{}
+	for _, entry := range subKeys {
+		for _, subKey := range entry {
+			if !seen[subKey] {
+				seen[subKey] = true
+				keys = append(keys, subKey)
+			}
+		}
+	}
+	members := make(map[interface{}]interface{}, len(keys))
+	if len(keys) == 0 {
+		return members, nil
+	}
+
+	options.Metadata = false
+	results, err := c.members.GetMulti(ctx, keys, options)
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range results {
+		if !result.IsEmpty() {
+			members[keys[i]] = result.StoredValue()
+		}
+	}
+	return members, nil
+}
+
+// builds the values of a cached entry from its subkeys. Values that no longer exist are left out, unless
+// the values are read from the cache only, where a value that isn't cached makes the entry unusable.
+// Values whose key changed since their subkey was added to the entry no longer belong to it and are left out
+func (c *normalizedNonUniqueKeyCacheHandler) hydrate(key interface{}, subKeys []interface{}, members map[interface{}]interface{}, options ReadOptions) (interface{}, bool) {
+	values := c.subTypeHandler.NewPtrToSlice()
+	values.MakeSlice(0, len(subKeys))
+	for _, subKey := range subKeys {
+		member, ok := members[subKey]
+		if !ok {
+			if options.CacheOnly {
+				return nil, false
+			}
+			continue
+		}
+		if !c.belongs(member, key) {
+			continue
+		}
+		values.Append(member)
+	}
+	return values.Ptr(), true
+}
+
+// checks if a member read for a subkey of an entry still has the key of the entry
+func (c *normalizedNonUniqueKeyCacheHandler) belongs(member interface{}, key interface{}) bool {
+	valueKey, ok := c.ValueKey(member)
+	return ok && cast.ToString(valueKey) == cast.ToString(key)
+}
+
+// verifies a sample of the keys that were found in the cache, if read-repair is enabled
+func (c *normalizedNonUniqueKeyCacheHandler) sampleReadRepair(cacheStore CacheStore, fetcher DataFetcher, hits []interface{}) {
+	if c.readRepair == nil {
+		return
+	}
+	keys := c.readRepair.sample(hits)
+	if len(keys) == 0 {
+		return
+	}
+	c.readRepair.run(func(ctx context.Context) {
+		c.repair(ctx, cacheStore, fetcher, keys)
+	})
+}
+
+// compares the cached subkeys of the keys with the values in the repository, overwriting the entries that
+// differ. The values themselves are verified by the read-repair of the unique key cache of the subkeys
+func (c *normalizedNonUniqueKeyCacheHandler) repair(ctx context.Context, cacheStore CacheStore, fetcher DataFetcher, keys []interface{}) {
+	strKeys := make([]string, len(keys))
+	for i, key := range keys {
+		strKeys[i] = c.cacheKey(key)
+	}
+	// the cache is read before the repository so that values written meanwhile aren't replaced by older ones
+	cached := c.subKeysTypeHandler.NewPtrToSlice()
+	cached.MakeSlice(0, len(keys))
+	found, err := cacheStore.GetMulti(ctx, strKeys, cached.Ptr())
+	if err != nil {
+		log.Println("Error reading cache entries for read-repair with prefix: ", c.keyPrefix, "-", err)
+		return
+	}
+	results, err := fetcher.FindByKeys(ctx, c.keyFieldName, keys)
+	if err != nil {
+		log.Println("Error fetching data for read-repair of cache with prefix: ", c.keyPrefix, "-", err)
+		return
+	}
+
+	cachedEntries := make([]interface{}, len(keys))
+	cachedSubKeys := make([][]interface{}, len(keys))
+	cached.ForEach(func(i int, handler drreflect.PointerVHandler) {
+		if found[i] {
+			cachedEntries[i] = handler.Element()
+			cachedSubKeys[i] = c.subKeysTypeHandler.AsInterfaceSlice(handler.Element())
+		}
+	})
+	for i, key := range keys {
+		if !found[i] {
+			continue
+		}
+		var stored interface{}
+		if !results[i].IsEmpty() {
+			stored = results[i].StoredValue()
+			if sameSubKeys(cachedSubKeys[i], c.valuesSubKeys(stored)) {
+				continue
+			}
+		}
+		if !unchangedEntry(ctx, cacheStore, strKeys[i], c.subKeysTypeHandler, cachedEntries[i]) {
+			continue
+		}
+		var repairErr error
+		if stored == nil {
+			repairErr = cacheStore.Delete(ctx, strKeys[i])
+		} else {
+			repairErr = c.setEntry(ctx, cacheStore, strKeys[i], stored)
+		}
+		if repairErr != nil {
+			log.Println("Error repairing cache entry for key: ", strKeys[i], "-", repairErr)
+			continue
+		}
+		c.readRepair.options.OnDrift(DriftEvent{
+			CacheKeyPrefix: c.keyPrefix,
+			Key:            key,
+			Cached:         cachedSubKeys[i],
+			Stored:         stored,
+		})
+	}
+}
+
+// returns the subkeys of a slice of values
+func (c *normalizedNonUniqueKeyCacheHandler) valuesSubKeys(values interface{}) []interface{} {
+	var subKeys []interface{}
+	drreflect.NewReflectSlicePointerVHandler(values).ForEach(func(_ int, handler drreflect.PointerVHandler) {
+		if subKey, ok := c.ValueSubKey(handler.Element()); ok && subKey != nil {
+			subKeys = append(subKeys, subKey)
+		}
+	})
+	return subKeys
+}
+
+// checks if both slices hold the same subkeys regardless of their order, comparing them as strings as
+// subkeys read from the cache may not be of the same type as the subkeys of the values
+func sameSubKeys(s1, s2 []interface{}) bool {
+	if len(s1) != len(s2) {
+		return false
+	}
+	counts := make(map[string]int, len(s1))
+	for _, subKey := range s1 {
+		counts[cast.ToString(subKey)]++
+	}
+	for _, subKey := range s2 {
+		strSubKey := cast.ToString(subKey)
+		if counts[strSubKey] == 0 {
+			return false
+		}
+		counts[strSubKey]--
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package datarepo
+
+import (
+	"errors"
+	"time"
+)
+
+// Options that change how data is read from a repository on a single call
+type ReadOptions struct {
+	// Indicates that the data must be read from the DataFetcher, without reading or populating the cache
+	SkipCache bool
+	// Indicates that the data must be read from the cache only. Ids that aren't cached return an empty
+	// result and the DataFetcher is never used
+	CacheOnly bool
+	// Cached entries stored longer ago than this duration are treated as not cached. A zero value
+	// accepts cached entries of any age.
+	//
+	// The age of entries is only known if the CacheStore implements MetadataCacheStore, entries of
+	// unknown age are treated as not cached when this option is used
+	MaxStaleness time.Duration
+	// Indicates that the results must carry metadata about where they were read from, see GetResultMetadata
+	Metadata bool
+}
+
+// Option that changes how data is read from a repository on a single call
+type ReadOption func(options *ReadOptions)
+
+// Reads the data from the DataFetcher, without reading or populating the cache
+func SkipCache() ReadOption {
+	return func(options *ReadOptions) {
+		options.SkipCache = true
+	}
+}
+
+// Reads the data from the cache only, never using the DataFetcher
+func CacheOnly() ReadOption {
+	return func(options *ReadOptions) {
+		options.CacheOnly = true
+	}
+}
+
+// Rejects cached entries stored longer ago than the provided duration
+func MaxStaleness(d time.Duration) ReadOption {
+	return func(options *ReadOptions) {
+		options.MaxStaleness = d
+	}
+}
+
+// Attaches metadata about where they were read from to the results, see GetResultMetadata
+func WithMetadata() ReadOption {
+	return func(options *ReadOptions) {
+		options.Metadata = true
+	}
+}
+
+// Creates the ReadOptions resulting from applying the provided options, returning an error if
+// the options can't be used together
+func NewReadOptions(options ...ReadOption) (ReadOptions, error) {
+	var result ReadOptions
+	for _, option := range options {
+		option(&result)
+	}
+	if result.SkipCache && result.CacheOnly {
+		return result, errors.New("the SkipCache and CacheOnly read options can't be used together")
+	}
+	if result.MaxStaleness < 0 {
+		return result, errors.New("the MaxStaleness read option must not be negative")
+	}
+	return result, nil
+}
+
+// checks if the metadata of the cached entries must be read to apply these options
+func (o ReadOptions) needsMetadata() bool {
+	return o.MaxStaleness != 0 || o.Metadata
+}
+
+// checks if a cached entry can be used according to these options
+func (o ReadOptions) isFresh(metadata EntryMetadata) bool {
+	if o.MaxStaleness == 0 {
+		return true
+	}
+	if metadata.StoredAt.IsZero() {
+		return false
+	}
+	return time.Since(metadata.StoredAt) <= o.MaxStaleness
+}
@@ -0,0 +1,228 @@
+package datarepo
+
+import (
+	"context"
+	"github.com/merlinapp/datarepo-go/drreflect"
+	"log"
+	"math/rand"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Options of the read-repair sampling of cache hits (see Builder.WithReadRepair)
+type ReadRepairOptions struct {
+	// Fraction of the cache hits that are verified against the DataFetcher, from 0 to 1
+	SampleRate float64
+	// Function that returns true if the cached value of an entry and the value stored in the repository
+	// are equal. The stored value is nil if the repository has no data for the key.
+	//
+	// By default the values are compared field by field, matching the elements of non-unique caches by
+	// their sub key
+	Equal func(cached, stored interface{}) bool
+	// Function invoked when a cached value that doesn't match the repository is overwritten, once the
+	// entry has been overwritten or evicted successfully. By default the drift is logged
+	OnDrift func(event DriftEvent)
+	// Maximum number of verifications running at the same time. Cache hits sampled while the limit is
+	// reached aren't verified. Defaults to 10
+	MaxConcurrent int
+	// Maximum time allowed for a verification. Defaults to 5 seconds
+	Timeout time.Duration
+}
+
+// A cache entry that didn't match the data in the repository and was overwritten by read-repair
+type DriftEvent struct {
+	// Key prefix of the cache the entry belongs to
+	CacheKeyPrefix string
+	// Key of the entry, as provided when reading the repository
+	Key interface{}
+	// Value found in the cache
+	Cached interface{}
+	// Value stored in the repository, nil if the repository has no data for the key, in which case the
+	// entry is evicted
+	Stored interface{}
+}
+
+// Verifies sampled cache hits in the background, shared by the caches of a repository
+type readRepairer struct {
+	options ReadRepairOptions
+	sem     chan struct{}
+	wg      sync.WaitGroup
+}
+
+// read-repair of a single cache
+type cacheReadRepair struct {
+	*readRepairer
+	handler Handler
+}
+
+// implemented by the cache handlers that support read-repair
+type readRepairHandler interface {
+	setReadRepair(readRepair *cacheReadRepair)
+}
+
+func newReadRepairer(options ReadRepairOptions) *readRepairer {
+	if options.MaxConcurrent <= 0 {
+		options.MaxConcurrent = 10
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = 5 * time.Second
+	}
+	if options.OnDrift == nil {
+		options.OnDrift = func(event DriftEvent) {
+			log.Println("Cache entry didn't match the repository for cache with prefix: ", event.CacheKeyPrefix,
+				"- key:", event.Key)
+		}
+	}
+	return &readRepairer{
+		options: options,
+		sem:     make(chan struct{}, options.MaxConcurrent),
+	}
+}
+
+// returns the keys chosen for verification according to the sample rate
+func (r *readRepairer) sample(keys []interface{}) []interface{} {
+	var sampled []interface{}
+	for _, key := range keys {
+		if rand.Float64() < r.options.SampleRate {
+			sampled = append(sampled, key)
+		}
+	}
+	return sampled
+}
+
+// runs the verification in the background unless the maximum number of verifications is already running
+func (r *readRepairer) run(verification func(ctx context.Context)) {
+	select {
+	case r.sem <- struct{}{}:
+	default:
+		return
+	}
+	r.wg.Add(1)
+	go func() {
+		defer func() {
+			<-r.sem
+			r.wg.Done()
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), r.options.Timeout)
+		defer cancel()
+		verification(ctx)
+	}()
+}
+
+// Waits for the running verifications to finish until the context is done
+func (r *readRepairer) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *cacheReadRepair) equal(cached, stored interface{}) bool {
+	if r.options.Equal != nil {
+		return r.options.Equal(cached, stored)
+	}
+	if stored == nil {
+		return false
+	}
+	return len(diffEntries(r.handler, cached, stored)) == 0
+}
+
+func (c *baseCacheHandler) setReadRepair(readRepair *cacheReadRepair) {
+	c.readRepair = readRepair
+}
+
+// verifies a sample of the keys that were found in the cache, if read-repair is enabled
+func (c *baseCacheHandler) sampleReadRepair(cacheStore CacheStore, fetcher DataFetcher, hits []interface{}) {
+	if c.readRepair == nil {
+		return
+	}
+	keys := c.readRepair.sample(hits)
+	if len(keys) == 0 {
+		return
+	}
+	c.readRepair.run(func(ctx context.Context) {
+		c.repair(ctx, cacheStore, fetcher, keys)
+	})
+}
+
+// compares the cached entries of the keys with the data in the repository, overwriting the ones that differ
+func (c *baseCacheHandler) repair(ctx context.Context, cacheStore CacheStore, fetcher DataFetcher, keys []interface{}) {
+	strKeys := make([]string, len(keys))
+	for i, key := range keys {
+		strKeys[i] = c.cacheKey(key)
+	}
+	// the cache is read before the repository so that values written meanwhile aren't replaced by older ones
+	cached := c.typeHandler.NewPtrToSlice()
+	cached.MakeSlice(0, len(keys))
+	found, err := cacheStore.GetMulti(ctx, strKeys, cached.Ptr())
+	if err != nil {
+		log.Println("Error reading cache entries for read-repair with prefix: ", c.keyPrefix, "-", err)
+		return
+	}
+	results, err := fetcher.FindByKeys(ctx, c.keyFieldName, keys)
+	if err != nil {
+		log.Println("Error fetching data for read-repair of cache with prefix: ", c.keyPrefix, "-", err)
+		return
+	}
+
+	cachedValues := make([]interface{}, len(keys))
+	cached.ForEach(func(i int, handler drreflect.PointerVHandler) {
+		cachedValues[i] = handler.Element()
+	})
+	for i, key := range keys {
+		if !found[i] {
+			continue
+		}
+		var stored interface{}
+		if !results[i].IsEmpty() {
+			stored = results[i].StoredValue()
+		}
+		if c.readRepair.equal(cachedValues[i], stored) {
+			continue
+		}
+		if !unchangedEntry(ctx, cacheStore, strKeys[i], c.typeHandler, cachedValues[i]) {
+			continue
+		}
+		var repairErr error
+		if stored == nil {
+			repairErr = cacheStore.Delete(ctx, strKeys[i])
+		} else {
+			repairErr = TrySet(ctx, cacheStore, strKeys[i], stored, c.expiration)
+		}
+		if repairErr != nil {
+			log.Println("Error repairing cache entry for key: ", strKeys[i], "-", repairErr)
+			continue
+		}
+		c.readRepair.options.OnDrift(DriftEvent{
+			CacheKeyPrefix: c.keyPrefix,
+			Key:            key,
+			Cached:         cachedValues[i],
+			Stored:         stored,
+		})
+	}
+}
+
+// reads the entry of the key again right before it's repaired, returning true if it still holds the cached
+// value that was compared with the repository. Entries written or evicted since they were first read are left
+// as they are, as the value fetched from the repository may be older than the written one.
+//
+// A write between this read and the repair can still be overwritten. In that case the entry holds the value
+// fetched from the repository until the key is written again or the entry expires
+func unchangedEntry(ctx context.Context, cacheStore CacheStore, key string, typeHandler drreflect.TypeHandler, cached interface{}) bool {
+	current := typeHandler.NewPtrToElement()
+	found, err := cacheStore.Get(ctx, key, current.Ptr())
+	if err != nil {
+		log.Println("Error reading cache entry for read-repair for key: ", key, "-", err)
+		return false
+	}
+	return found && reflect.DeepEqual(reflect.Indirect(reflect.ValueOf(current.Ptr())).Interface(),
+		reflect.Indirect(reflect.ValueOf(cached)).Interface())
+}
@@ -0,0 +1,317 @@
+package datarepo
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A Registry keeps track of the repositories of an application by entity name.
+//
+// Besides looking up repositories, the registry detects repositories that share a CacheStore using
+// colliding key prefixes, aggregates the stats of the registered repositories, checks the health of the
+// underlying cache stores and databases and shuts down their background workers.
+//
+// A Registry is safe for concurrent use
+type Registry struct {
+	mu    sync.RWMutex
+	repos map[string]ReadOnlyCachedRepository
+}
+
+// Stats of a repository, aggregated from the stats wrappers used by the repository (see the
+// cachestore/stats and repo/stats packages). Components that don't keep stats are ignored.
+type RepositoryStats struct {
+	// number of Cache Hits
+	Hits int64
+	// number of Cache Misses
+	Misses int64
+	// number of Set operations performed in the cache stores
+	Sets int64
+	// number of Delete operations performed in the cache stores
+	Dels int64
+	// number of ids sent for reading to the data fetchers
+	Reads int64
+	// number of Create operations invoked in the data writer
+	Creates int64
+	// number of Update operations invoked in the data writer
+	Updates int64
+}
+
+// Error returned by Registry operations that are performed on every registered repository,
+// holding the errors found per entity name
+type RegistryError struct {
+	Errors map[string]error
+}
+
+func (e *RegistryError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	messages := make([]string, len(names))
+	for i, name := range names {
+		messages[i] = name + ": " + e.Errors[name].Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Components of a repository that can report whether they're healthy, for example, a CacheStore
+// backed by Redis or a DataFetcher backed by a database
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Components of a repository that run background workers that need to be stopped
+type Shutdowner interface {
+	// Stops the background workers, waiting for them to finish until the context is done
+	Shutdown(ctx context.Context) error
+}
+
+// implemented by the repositories created by this library to expose their components
+type repositoryComponents interface {
+	registeredCaches() map[string]Cache
+	registeredWriter() DataWriter
+}
+
+type cacheStoreStats interface {
+	Sets() int64
+	Hits() int64
+	Miss() int64
+	Dels() int64
+}
+
+type dataFetcherStats interface {
+	Reads() int64
+}
+
+type dataWriterStats interface {
+	Creates() int64
+	Updates() int64
+}
+
+// Creates a new empty Registry
+func NewRegistry() *Registry {
+	return &Registry{repos: make(map[string]ReadOnlyCachedRepository)}
+}
+
+// Registers the repository under the given entity name.
+//
+// An error is returned if a repository was already registered with the same entity name or if any cache of
+// the repository uses a CacheStore and a key prefix that collide with a cache of an already registered
+// repository
+func (r *Registry) Register(entityName string, repo ReadOnlyCachedRepository) error {
+	if entityName == "" {
+		return errors.New("an entity name must be provided to register a repository")
+	}
+	if repo == nil {
+		return errors.New("the repository to register for " + entityName + " must not be nil")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.repos[entityName]; ok {
+		return errors.New("a repository has already been registered for: " + entityName)
+	}
+	if err := r.keyPrefixCollision(entityName, repo); err != nil {
+		return err
+	}
+	r.repos[entityName] = repo
+	return nil
+}
+
+// Retrieves the repository registered with the given entity name
+func (r *Registry) Repository(entityName string) (ReadOnlyCachedRepository, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	repo, ok := r.repos[entityName]
+	return repo, ok
+}
+
+// Retrieves the read-write repository registered with the given entity name.
+//
+// The second return value is false if no repository was registered with the given name or if the
+// registered repository is read-only
+func (r *Registry) CachedRepository(entityName string) (CachedRepository, bool) {
+	repo, ok := r.Repository(entityName)
+	if !ok {
+		return nil, false
+	}
+	rwRepo, ok := repo.(CachedRepository)
+	return rwRepo, ok
+}
+
+// Returns the sorted names of the registered entities
+func (r *Registry) EntityNames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.repos))
+	for name := range r.repos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns the stats of every registered repository by entity name
+func (r *Registry) Stats() map[string]RepositoryStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make(map[string]RepositoryStats, len(r.repos))
+	for name, repo := range r.repos {
+		result[name] = repositoryStats(repo)
+	}
+	return result
+}
+
+// Returns the sum of the stats of every registered repository
+func (r *Registry) TotalStats() RepositoryStats {
+	var total RepositoryStats
+	for _, s := range r.Stats() {
+		total.Hits += s.Hits
+		total.Misses += s.Misses
+		total.Sets += s.Sets
+		total.Dels += s.Dels
+		total.Reads += s.Reads
+		total.Creates += s.Creates
+		total.Updates += s.Updates
+	}
+	return total
+}
+
+// Pings the cache stores, data fetchers and data writers of every registered repository that implement
+// the Pinger interface.
+//
+// If any component is unhealthy a *RegistryError is returned with the first error found per entity
+func (r *Registry) HealthCheck(ctx context.Context) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	errs := make(map[string]error)
+	for name, repo := range r.repos {
+		for _, c := range components(repo) {
+			if p, ok := c.(Pinger); ok {
+				if err := p.Ping(ctx); err != nil {
+					errs[name] = err
+					break
+				}
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return &RegistryError{Errors: errs}
+	}
+	return nil
+}
+
+// Shuts down the background workers of every registered repository, and of their components, that
+// implement the Shutdowner interface.
+//
+// Every repository is shut down even if some of them fail, in which case a *RegistryError is returned
+func (r *Registry) Shutdown(ctx context.Context) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	errs := make(map[string]error)
+	for name, repo := range r.repos {
+		for _, c := range append([]interface{}{repo}, components(repo)...) {
+			if s, ok := c.(Shutdowner); ok {
+				if err := s.Shutdown(ctx); err != nil && errs[name] == nil {
+					errs[name] = err
+				}
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return &RegistryError{Errors: errs}
+	}
+	return nil
+}
+
+func (r *Registry) keyPrefixCollision(entityName string, repo ReadOnlyCachedRepository) error {
+	newCaches := cachesOf(repo)
+	var collisions []string
+	for name, registered := range r.repos {
+		for _, c1 := range newCaches {
+			for _, c2 := range cachesOf(registered) {
+				if !sameComponent(c1.Store, c2.Store) {
+					continue
+				}
+				p1, p2 := c1.Handler.CacheKeyPrefix(), c2.Handler.CacheKeyPrefix()
+				if strings.HasPrefix(p1, p2) || strings.HasPrefix(p2, p1) {
+					collisions = append(collisions, "key prefix '"+p1+"' of "+entityName+
+						" collides with key prefix '"+p2+"' of "+name+" in the same cache store")
+				}
+			}
+		}
+	}
+	if len(collisions) > 0 {
+		sort.Strings(collisions)
+		return errors.New(strings.Join(collisions, "; "))
+	}
+	return nil
+}
+
+func cachesOf(repo ReadOnlyCachedRepository) []Cache {
+	rc, ok := repo.(repositoryComponents)
+	if !ok {
+		return nil
+	}
+	caches := make([]Cache, 0, len(rc.registeredCaches()))
+	for _, c := range rc.registeredCaches() {
+		caches = append(caches, c)
+	}
+	return caches
+}
+
+// returns the distinct cache stores, data fetchers and data writer used by a repository
+func components(repo ReadOnlyCachedRepository) []interface{} {
+	rc, ok := repo.(repositoryComponents)
+	if !ok {
+		return nil
+	}
+	var result []interface{}
+	add := func(c interface{}) {
+		if c == nil {
+			return
+		}
+		for _, existent := range result {
+			if sameComponent(existent, c) {
+				return
+			}
+		}
+		result = append(result, c)
+	}
+	for _, c := range rc.registeredCaches() {
+		add(c.Store)
+		if w, ok := c.DataFetcher.(*emptyResultDataFetcherWrapper); ok {
+			add(w.delegate)
+		} else {
+			add(c.DataFetcher)
+		}
+	}
+	if w := rc.registeredWriter(); w != nil {
+		add(w)
+	}
+	return result
+}
+
+func repositoryStats(repo ReadOnlyCachedRepository) RepositoryStats {
+	var s RepositoryStats
+	for _, c := range components(repo) {
+		if cs, ok := c.(cacheStoreStats); ok {
+			s.Hits += cs.Hits()
+			s.Misses += cs.Miss()
+			s.Sets += cs.Sets()
+			s.Dels += cs.Dels()
+		}
+		if fs, ok := c.(dataFetcherStats); ok {
+			s.Reads += fs.Reads()
+		}
+		if ws, ok := c.(dataWriterStats); ok {
+			s.Creates += ws.Creates()
+			s.Updates += ws.Updates()
+		}
+	}
+	return s
+}
@@ -1,9 +1,11 @@
 package gorm
 
 import (
+	"context"
 	"github.com/jinzhu/gorm"
 	"github.com/merlinapp/datarepo-go"
 	"github.com/merlinapp/datarepo-go/drreflect"
+	"strings"
 )
 
 // Creates a new Builder for a GORM based cached repository that will handle data
@@ -11,12 +13,18 @@ import (
 //
 // The data type is expected to be a struct or a pointer to a struct
 func CachedRepositoryBuilder(db *gorm.DB, dataType interface{}) datarepo.Builder {
+	return CachedRepositoryBuilderWithDerivedKeys(db, dataType, nil)
+}
+
+// Same as CachedRepositoryBuilder, but the data fetchers find the values of the caches defined with a KeyFunc
+// using the query hooks of the derived keys
+func CachedRepositoryBuilderWithDerivedKeys(db *gorm.DB, dataType interface{}, derivedKeys DerivedKeys) datarepo.Builder {
 	if db == nil {
 		panic("The gorm DB instance must not be nil")
 	}
 	builder := datarepo.CachedRepositoryBuilder(dataType).
-		WithUniqueKeyDataFetcher(NewUniqueKeyDataFetcher(db, dataType)).
-		WithNonUniqueKeyDataFetcher(NewNonUniqueKeyDataFetcher(db, dataType)).
+		WithUniqueKeyDataFetcher(NewUniqueKeyDataFetcherWithDerivedKeys(db, dataType, derivedKeys)).
+		WithNonUniqueKeyDataFetcher(NewNonUniqueKeyDataFetcherWithDerivedKeys(db, dataType, derivedKeys)).
 		WithDataWriter(NewDataWriter(db, dataType))
 	return builder
 }
@@ -30,22 +38,34 @@ func NewDataWriter(db *gorm.DB, dataType interface{}) datarepo.DataWriter {
 }
 
 func NewUniqueKeyDataFetcher(db *gorm.DB, dataType interface{}) datarepo.DataFetcher {
+	return NewUniqueKeyDataFetcherWithDerivedKeys(db, dataType, nil)
+}
+
+// Same as NewUniqueKeyDataFetcher, but the values of the derived keys are found with their query hooks
+func NewUniqueKeyDataFetcherWithDerivedKeys(db *gorm.DB, dataType interface{}, derivedKeys DerivedKeys) datarepo.DataFetcher {
 	fieldToColumnMap := getFieldToColumnNames(db, dataType)
 	th := drreflect.NewReflectStructTypeHandlerFromValue(dataType)
 	return &uniqueDataFetcher{
 		db:                db,
 		typeHandler:       th,
 		fieldToColumnName: fieldToColumnMap,
+		derivedKeys:       derivedKeys,
 	}
 }
 
 func NewNonUniqueKeyDataFetcher(db *gorm.DB, dataType interface{}) datarepo.DataFetcher {
+	return NewNonUniqueKeyDataFetcherWithDerivedKeys(db, dataType, nil)
+}
+
+// Same as NewNonUniqueKeyDataFetcher, but the values of the derived keys are found with their query hooks
+func NewNonUniqueKeyDataFetcherWithDerivedKeys(db *gorm.DB, dataType interface{}, derivedKeys DerivedKeys) datarepo.DataFetcher {
 	fieldToColumnMap := getFieldToColumnNames(db, dataType)
 	th := drreflect.NewReflectStructTypeHandlerFromValue(dataType)
 	return &nonUniqueDataFetcher{
 		db:                db,
 		typeHandler:       th,
 		fieldToColumnName: fieldToColumnMap,
+		derivedKeys:       derivedKeys,
 	}
 }
 
@@ -57,6 +77,12 @@ func getFieldToColumnNames(db *gorm.DB, model interface{}) map[string]string {
 	}
 	for _, v := range modelStruct.StructFields {
 		fieldToColumn[v.Name] = v.DBName
+		// fields of embedded structs can also be referenced with their path, like "Meta.ExternalID"
+		fieldToColumn[strings.Join(v.Names, ".")] = v.DBName
 	}
 	return fieldToColumn
 }
+
+func ping(ctx context.Context, db *gorm.DB) error {
+	return db.DB().PingContext(ctx)
+}
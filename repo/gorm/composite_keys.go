@@ -0,0 +1,59 @@
+package gorm
+
+import (
+	"errors"
+	"github.com/jinzhu/gorm"
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/drreflect"
+	"strings"
+)
+
+// adds the conditions that select the values of the composite keys to the query. Row values, like
+// (a, b) IN ((?, ?), (?, ?)), are used when the dialect supports them, and OR-chains otherwise.
+//
+// The ids must not be empty, as neither condition is valid SQL without values. Callers return an empty
+// result without querying instead
+func whereCompositeKeys(db *gorm.DB, columnNames []string, ids []interface{}) (*gorm.DB, error) {
+	args := make([]interface{}, 0, len(ids)*len(columnNames))
+	for _, id := range ids {
+		tuple, ok := id.(datarepo.Tuple)
+		if !ok || tuple.Len() != len(columnNames) {
+			return nil, errors.New("the keys of a composite key must be tuples of " + strings.Join(columnNames, ", "))
+		}
+		args = append(args, tuple.Values()...)
+	}
+
+	if db.Dialect().GetName() == "mssql" {
+		condition := "(" + strings.Join(columnNames, " = ? AND ") + " = ?)"
+		return db.Where(strings.TrimSuffix(strings.Repeat(condition+" OR ", len(ids)), " OR "), args...), nil
+	}
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columnNames)), ", ") + ")"
+	rows := strings.TrimSuffix(strings.Repeat(row+", ", len(ids)), ", ")
+	return db.Where("("+strings.Join(columnNames, ", ")+") IN ("+rows+")", args...), nil
+}
+
+// returns the columns of the fields of a composite key
+func compositeKeyColumns(fieldToColumnName map[string]string, fields []string) ([]string, error) {
+	columnNames := make([]string, len(fields))
+	for i, field := range fields {
+		columnName, ok := fieldToColumnName[field]
+		if !ok {
+			return nil, errors.New("column name not defined for: " + field)
+		}
+		columnNames[i] = columnName
+	}
+	return columnNames, nil
+}
+
+// returns a function that reads the composite key of a value as a tuple
+func compositeKeyFunc(typeHandler drreflect.StructTypeHandler, fields []string) datarepo.KeyFunc {
+	return func(value interface{}) (interface{}, bool) {
+		values := make([]interface{}, len(fields))
+		for i, field := range fields {
+			if values[i] = typeHandler.GetFieldValue(value, field); values[i] == nil {
+				return nil, false
+			}
+		}
+		return datarepo.NewTuple(values...), true
+	}
+}
@@ -2,8 +2,9 @@ package gorm
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"github.com/jinzhu/gorm"
+	"github.com/merlinapp/datarepo-go"
 	"github.com/merlinapp/datarepo-go/drreflect"
 )
 
@@ -57,7 +58,11 @@ func (w *dataWriter) PartialUpdate(ctx context.Context, value interface{}) error
 
 func (w *dataWriter) ensurePointer(value interface{}) error {
 	if !w.typeHandler.IsOfPtrType(value) {
-		return errors.New("The provided value isn't of the expected type: " + w.typeHandler.Type().String())
+		return fmt.Errorf("%w: %s", datarepo.ErrTypeMismatch, w.typeHandler.Type())
 	}
 	return nil
 }
+
+func (w *dataWriter) Ping(ctx context.Context) error {
+	return ping(ctx, w.db)
+}
@@ -0,0 +1,19 @@
+package gorm
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/merlinapp/datarepo-go"
+)
+
+// Translates the keys of a cache derived with a KeyFunc, like a lower-cased email, into a query
+type DerivedKey struct {
+	// Adds the conditions that select the values of the keys to the query, for example:
+	//	db.Where("LOWER(email) IN (?)", keys)
+	Query func(db *gorm.DB, keys []interface{}) *gorm.DB
+	// Derives the key of a fetched value, which is usually the KeyFunc of the cache definition. The keys
+	// must be of the same type as the keys the values are fetched with
+	Key datarepo.KeyFunc
+}
+
+// Query hooks of the derived keys of a data type, by the KeyFieldName of their caches
+type DerivedKeys map[string]DerivedKey
@@ -0,0 +1,50 @@
+package gorm
+
+import (
+	"errors"
+	"github.com/jinzhu/gorm"
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/drreflect"
+)
+
+// loads the values of the ids, returning them together with the function that reads their key. The ids are
+// matched with the derived keys, the composite keys or the column of the key field
+func findByKeys(db *gorm.DB, typeHandler drreflect.StructTypeHandler, fieldToColumnName map[string]string,
+	derivedKeys DerivedKeys, keyFieldName string, ids []interface{}) (drreflect.SlicePointerHandler, datarepo.KeyFunc, error) {
+	dataSlice := typeHandler.NewPtrToSlice()
+	if derivedKey, ok := derivedKeys[keyFieldName]; ok {
+		if err := derivedKey.Query(db, ids).Find(dataSlice.Ptr()).Error; err != nil {
+			return nil, nil, err
+		}
+		return dataSlice, derivedKey.Key, nil
+	}
+	if fields := datarepo.CompositeKeyFields(keyFieldName); fields != nil {
+		columnNames, err := compositeKeyColumns(fieldToColumnName, fields)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(ids) == 0 {
+			return dataSlice, compositeKeyFunc(typeHandler, fields), nil
+		}
+		query, err := whereCompositeKeys(db, columnNames, ids)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := query.Find(dataSlice.Ptr()).Error; err != nil {
+			return nil, nil, err
+		}
+		return dataSlice, compositeKeyFunc(typeHandler, fields), nil
+	}
+
+	columnName, ok := fieldToColumnName[keyFieldName]
+	if !ok {
+		return nil, nil, errors.New("column name not defined for: " + keyFieldName)
+	}
+	if err := db.Find(dataSlice.Ptr(), columnName+" IN (?)", ids).Error; err != nil {
+		return nil, nil, err
+	}
+	key := func(value interface{}) (interface{}, bool) {
+		return typeHandler.GetFieldValue(value, keyFieldName), true
+	}
+	return dataSlice, key, nil
+}
@@ -0,0 +1,34 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"github.com/jinzhu/gorm"
+	"github.com/merlinapp/datarepo-go/drreflect"
+	"reflect"
+)
+
+// lists the distinct values of a key field in the table of the given data type, in ascending order
+func listKeys(ctx context.Context, db *gorm.DB, typeHandler drreflect.StructTypeHandler, fieldToColumnName map[string]string,
+	keyFieldName string, after interface{}, limit int) ([]interface{}, error) {
+	columnName, ok := fieldToColumnName[keyFieldName]
+	if !ok {
+		return nil, errors.New("column name not defined for: " + keyFieldName)
+	}
+	fieldType, ok := typeHandler.FieldType(keyFieldName)
+	if !ok {
+		return nil, errors.New("field not defined for: " + keyFieldName)
+	}
+
+	// values with a null key aren't cached
+	query := db.Model(typeHandler.NewPtrToElement().Ptr()).Where(columnName + " IS NOT NULL")
+	if after != nil {
+		query = query.Where(columnName+" > ?", after)
+	}
+	keys := reflect.New(reflect.SliceOf(fieldType))
+	err := query.Order(columnName).Limit(limit).Pluck("DISTINCT "+columnName, keys.Interface()).Error
+	if err != nil {
+		return nil, err
+	}
+	return drreflect.NewReflectSliceTypeHandler(keys.Type()).AsInterfaceSlice(keys.Interface()), nil
+}
@@ -0,0 +1,56 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"github.com/jinzhu/gorm"
+	"github.com/merlinapp/datarepo-go/drreflect"
+	"reflect"
+)
+
+// returns up to n distinct values of a key field in the table of the given data type, chosen at random
+func sampleKeys(ctx context.Context, db *gorm.DB, typeHandler drreflect.StructTypeHandler, fieldToColumnName map[string]string,
+	keyFieldName string, n int) ([]interface{}, error) {
+	columnName, ok := fieldToColumnName[keyFieldName]
+	if !ok {
+		return nil, errors.New("column name not defined for: " + keyFieldName)
+	}
+	fieldType, ok := typeHandler.FieldType(keyFieldName)
+	if !ok {
+		return nil, errors.New("field not defined for: " + keyFieldName)
+	}
+
+	keys := reflect.New(reflect.SliceOf(fieldType))
+	// values with a null key aren't cached
+	err := db.Model(typeHandler.NewPtrToElement().Ptr()).
+		Where(columnName+" IS NOT NULL").
+		Order(randomFunction(db)).
+		Limit(n).
+		Pluck(columnName, keys.Interface()).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// rows of non-unique keys may repeat the same value
+	var result []interface{}
+	seen := make(map[interface{}]bool)
+	for _, key := range drreflect.NewReflectSliceTypeHandler(keys.Type()).AsInterfaceSlice(keys.Interface()) {
+		if !seen[key] {
+			seen[key] = true
+			result = append(result, key)
+		}
+	}
+	return result, nil
+}
+
+// returns the SQL function that generates random values in the dialect of the database
+func randomFunction(db *gorm.DB) string {
+	switch db.Dialect().GetName() {
+	case "postgres", "sqlite3":
+		return "RANDOM()"
+	case "mssql":
+		return "NEWID()"
+	default:
+		return "RAND()"
+	}
+}
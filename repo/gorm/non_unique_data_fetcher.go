@@ -2,7 +2,6 @@ package gorm
 
 import (
 	"context"
-	"errors"
 	"github.com/jinzhu/gorm"
 	"github.com/merlinapp/datarepo-go"
 	"github.com/merlinapp/datarepo-go/drreflect"
@@ -12,6 +11,7 @@ type nonUniqueDataFetcher struct {
 	db                *gorm.DB
 	typeHandler       drreflect.StructTypeHandler
 	fieldToColumnName map[string]string
+	derivedKeys       DerivedKeys
 }
 
 func (u *nonUniqueDataFetcher) FindByKey(ctx context.Context, keyFieldName string, id interface{}) (datarepo.Result, error) {
@@ -23,13 +23,7 @@ func (u *nonUniqueDataFetcher) FindByKey(ctx context.Context, keyFieldName strin
 }
 
 func (u *nonUniqueDataFetcher) FindByKeys(ctx context.Context, keyFieldName string, ids []interface{}) ([]datarepo.Result, error) {
-	dataSlice := u.typeHandler.NewPtrToSlice()
-	columnName, ok := u.fieldToColumnName[keyFieldName]
-	if !ok {
-		return nil, errors.New("column name not defined for: " + keyFieldName)
-	}
-
-	err := u.db.Find(dataSlice.Ptr(), columnName+" IN (?)", ids).Error
+	dataSlice, key, err := findByKeys(u.db, u.typeHandler, u.fieldToColumnName, u.derivedKeys, keyFieldName, ids)
 	if err != nil {
 		return nil, err
 	}
@@ -37,7 +31,10 @@ func (u *nonUniqueDataFetcher) FindByKeys(ctx context.Context, keyFieldName stri
 	result := make([]datarepo.Result, len(ids))
 	resultsPerId := make(map[interface{}]drreflect.SlicePointerHandler)
 	proc := func(_ int, handler drreflect.PointerVHandler) {
-		keyValue := u.typeHandler.GetFieldValue(handler.Element(), keyFieldName)
+		keyValue, ok := key(handler.Element())
+		if !ok {
+			return
+		}
 		if _, ok := resultsPerId[keyValue]; !ok {
 			resultsPerId[keyValue] = u.typeHandler.NewPtrToSlice()
 		}
@@ -54,3 +51,15 @@ func (u *nonUniqueDataFetcher) FindByKeys(ctx context.Context, keyFieldName stri
 
 	return result, err
 }
+
+func (u *nonUniqueDataFetcher) ListKeys(ctx context.Context, keyFieldName string, after interface{}, limit int) ([]interface{}, error) {
+	return listKeys(ctx, u.db, u.typeHandler, u.fieldToColumnName, keyFieldName, after, limit)
+}
+
+func (u *nonUniqueDataFetcher) SampleKeys(ctx context.Context, keyFieldName string, n int) ([]interface{}, error) {
+	return sampleKeys(ctx, u.db, u.typeHandler, u.fieldToColumnName, keyFieldName, n)
+}
+
+func (u *nonUniqueDataFetcher) Ping(ctx context.Context) error {
+	return ping(ctx, u.db)
+}
@@ -0,0 +1,219 @@
+package gorm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/drreflect"
+	"reflect"
+	"time"
+)
+
+// Name of the outbox table used when the options don't define one
+const DefaultOutboxTable = "datarepo_outbox"
+
+// A cache invalidation record written to the outbox table in the same transaction as the data
+type OutboxRecord struct {
+	ID uint64 `gorm:"primary_key;AUTO_INCREMENT"`
+	// Entity name of the repository the written row belongs to, as registered in the datarepo.Registry
+	Entity string `gorm:"type:varchar(255);not null"`
+	// Operation performed on the row
+	Operation string `gorm:"type:varchar(16);not null"`
+	// JSON encoded values of the key fields of the row before and after the write, by field name
+	Keys string `gorm:"type:text;not null"`
+	// JSON encoded row before the write, empty if the row was created
+	OldValue string `gorm:"type:text"`
+	// JSON encoded row after the write
+	NewValue  string    `gorm:"type:text"`
+	CreatedAt time.Time `gorm:"not null"`
+	// Time when the record was applied to the caches, nil while it's pending
+	ProcessedAt *time.Time `gorm:"index"`
+}
+
+// Options of a DataWriter that records the writes in an outbox table
+type OutboxOptions struct {
+	// Entity name of the repository, as registered in the datarepo.Registry used by the OutboxRelay
+	Entity string
+	// Name of the outbox table, DefaultOutboxTable by default
+	TableName string
+	// Fields of the data type whose values are recorded in the Keys of the records, usually the key fields
+	// of the caches of the repository. By default every column of the data type is recorded.
+	//
+	// The rows before and after the write are recorded too, so the keys of caches with composite keys or a
+	// KeyFunc are derived from them by the cache handlers when the records are applied
+	KeyFieldNames []string
+}
+
+type outboxDataWriter struct {
+	db          *gorm.DB
+	typeHandler drreflect.StructTypeHandler
+	options     OutboxOptions
+}
+
+// Creates a GORM based Builder like CachedRepositoryBuilder whose DataWriter records every write in
+// an outbox table (see NewOutboxDataWriter)
+func CachedRepositoryBuilderWithOutbox(db *gorm.DB, dataType interface{}, options OutboxOptions) datarepo.Builder {
+	return CachedRepositoryBuilder(db, dataType).
+		WithDataWriter(NewOutboxDataWriter(db, dataType, options))
+}
+
+// Creates a DataWriter that, in the same transaction as every Create, Update and PartialUpdate, inserts
+// a record in the outbox table with the values of the key fields of the row before and after the write.
+//
+// The records are applied to the caches by an OutboxRelay, so the cache entries affected by a write are
+// invalidated even if the process stops after the transaction is committed
+func NewOutboxDataWriter(db *gorm.DB, dataType interface{}, options OutboxOptions) datarepo.DataWriter {
+	if options.Entity == "" {
+		panic("The entity name of the outbox records must not be empty")
+	}
+	if options.TableName == "" {
+		options.TableName = DefaultOutboxTable
+	}
+	if len(options.KeyFieldNames) == 0 {
+		for _, field := range db.NewScope(dataType).GetModelStruct().StructFields {
+			if field.IsNormal && !field.IsIgnored {
+				options.KeyFieldNames = append(options.KeyFieldNames, field.Name)
+			}
+		}
+	}
+	return &outboxDataWriter{
+		db:          db,
+		typeHandler: drreflect.NewReflectStructTypeHandlerFromValue(dataType),
+		options:     options,
+	}
+}
+
+// Creates or updates the outbox table with the given name, DefaultOutboxTable if empty
+func AutoMigrateOutbox(db *gorm.DB, tableName string) error {
+	if tableName == "" {
+		tableName = DefaultOutboxTable
+	}
+	return db.Table(tableName).AutoMigrate(&OutboxRecord{}).Error
+}
+
+func (w *outboxDataWriter) Create(ctx context.Context, value interface{}) error {
+	return w.write(ctx, value, datarepo.OperationCreate, false, func(tx *gorm.DB) error {
+		return tx.Create(value).Error
+	})
+}
+
+func (w *outboxDataWriter) Update(ctx context.Context, value interface{}) error {
+	return w.write(ctx, value, datarepo.OperationUpdate, true, func(tx *gorm.DB) error {
+		return tx.Save(value).Error
+	})
+}
+
+func (w *outboxDataWriter) PartialUpdate(ctx context.Context, value interface{}) error {
+	return w.write(ctx, value, datarepo.OperationUpdate, true, func(tx *gorm.DB) error {
+		return tx.Model(value).Updates(value).Find(value).Error
+	})
+}
+
+func (w *outboxDataWriter) Ping(ctx context.Context) error {
+	return ping(ctx, w.db)
+}
+
+// performs the write and inserts its outbox record in a single transaction
+func (w *outboxDataWriter) write(ctx context.Context, value interface{}, operation datarepo.ChangeOperation,
+	loadOld bool, writeFn func(tx *gorm.DB) error) error {
+	if !w.typeHandler.IsOfPtrType(value) {
+		return fmt.Errorf("%w: %s", datarepo.ErrTypeMismatch, w.typeHandler.Type())
+	}
+
+	tx := w.db.BeginTx(ctx, nil)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	keys := make(map[string][]interface{})
+	var record OutboxRecord
+	if loadOld {
+		old, err := w.findCurrent(tx, value)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if old != nil {
+			w.addKeys(keys, old)
+			if record.OldValue, err = encodeValue(old); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+	if err := writeFn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	w.addKeys(keys, value)
+
+	encodedKeys, err := json.Marshal(keys)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if record.NewValue, err = encodeValue(value); err != nil {
+		tx.Rollback()
+		return err
+	}
+	record.Entity = w.options.Entity
+	record.Operation = string(operation)
+	record.Keys = string(encodedKeys)
+	if err := tx.Table(w.options.TableName).Create(&record).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// loads the row currently stored with the primary key of the value, returning nil if there's none
+func (w *outboxDataWriter) findCurrent(tx *gorm.DB, value interface{}) (interface{}, error) {
+	where := make(map[string]interface{})
+	for _, field := range tx.NewScope(value).PrimaryFields() {
+		if field.IsBlank {
+			return nil, nil
+		}
+		where[field.DBName] = field.Field.Interface()
+	}
+	if len(where) == 0 {
+		return nil, nil
+	}
+	current := w.typeHandler.NewPtrToElement().Ptr()
+	err := tx.Where(where).First(current).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return current, nil
+}
+
+// adds the values of the recorded fields of the value to the keys, skipping nil and repeated values
+func (w *outboxDataWriter) addKeys(keys map[string][]interface{}, value interface{}) {
+	for _, fieldName := range w.options.KeyFieldNames {
+		fieldValue := w.typeHandler.GetFieldValue(value, fieldName)
+		if fieldValue == nil {
+			continue
+		}
+		repeated := false
+		for _, existent := range keys[fieldName] {
+			if reflect.DeepEqual(existent, fieldValue) {
+				repeated = true
+				break
+			}
+		}
+		if !repeated {
+			keys[fieldName] = append(keys[fieldName], fieldValue)
+		}
+	}
+}
+
+func encodeValue(value interface{}) (string, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,179 @@
+package gorm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/jinzhu/gorm"
+	"github.com/merlinapp/datarepo-go"
+	"log"
+	"strconv"
+	"time"
+)
+
+// Options of an OutboxRelay
+type OutboxRelayOptions struct {
+	// Name of the outbox table, DefaultOutboxTable by default
+	TableName string
+	// How often the outbox table is checked for pending records when there are none. Defaults to 1 second
+	PollInterval time.Duration
+	// Maximum number of pending records read on every poll. Defaults to 100
+	BatchSize int
+	// How long processed records are kept before they're deleted. Defaults to 24 hours, a negative
+	// value disables the cleanup
+	Retention time.Duration
+	// How often the processed records older than the Retention are deleted. Defaults to 1 hour
+	CleanupInterval time.Duration
+	// Options of the ChangeEventConsumer used to apply the records to the caches
+	Consumer datarepo.ChangeEventConsumerOptions
+}
+
+// An OutboxRelay applies the records written by the outbox DataWriters (see NewOutboxDataWriter) to the
+// caches of the repositories registered in a Registry, marking them as processed once applied.
+//
+// Records are applied in insertion order and at least once: a record applied right before the process stops
+// is applied again after a restart, which is harmless as invalidating or refreshing the same cache entries
+// twice leaves the caches in the same state. Records that fail are retried on the next poll.
+//
+// The relay is also a datarepo.AckChangeEventSource, so it can be consumed by a custom ChangeEventConsumer.
+// It's not safe to consume a relay from more than one goroutine
+type OutboxRelay struct {
+	db          *gorm.DB
+	registry    *datarepo.Registry
+	options     OutboxRelayOptions
+	pending     []OutboxRecord
+	lastID      uint64
+	lastCleanup time.Time
+}
+
+// Creates a new OutboxRelay that applies the records of the outbox table to the repositories of the registry
+func NewOutboxRelay(db *gorm.DB, registry *datarepo.Registry, options OutboxRelayOptions) *OutboxRelay {
+	if options.TableName == "" {
+		options.TableName = DefaultOutboxTable
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = time.Second
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = 100
+	}
+	if options.Retention == 0 {
+		options.Retention = 24 * time.Hour
+	}
+	if options.CleanupInterval <= 0 {
+		options.CleanupInterval = time.Hour
+	}
+	return &OutboxRelay{
+		db:       db,
+		registry: registry,
+		options:  options,
+	}
+}
+
+// Applies the pending records of the outbox table until the context is done
+func (r *OutboxRelay) Run(ctx context.Context) error {
+	return datarepo.NewChangeEventConsumer(r.registry, r, r.options.Consumer).Run(ctx)
+}
+
+// Returns the change event of the next pending record, waiting for new records until the context is done
+func (r *OutboxRelay) Next(ctx context.Context) (datarepo.ChangeEvent, error) {
+	for {
+		for len(r.pending) > 0 {
+			record := r.pending[0]
+			r.pending = r.pending[1:]
+			event, err := recordChangeEvent(record)
+			if err == nil {
+				return event, nil
+			}
+			// malformed records can't ever be applied, so they're discarded instead of blocking the relay
+			log.Println("Error decoding outbox record: ", record.ID, "-", err)
+			if err := r.markProcessed(record.ID); err != nil {
+				return datarepo.ChangeEvent{}, err
+			}
+		}
+
+		if err := r.poll(); err != nil {
+			return datarepo.ChangeEvent{}, err
+		}
+		if len(r.pending) > 0 {
+			continue
+		}
+		// records that failed are retried once every record after them has been read
+		r.lastID = 0
+		if r.options.Retention > 0 && time.Since(r.lastCleanup) >= r.options.CleanupInterval {
+			if err := r.Cleanup(ctx); err != nil {
+				log.Println("Error deleting processed outbox records -", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return datarepo.ChangeEvent{}, ctx.Err()
+		case <-time.After(r.options.PollInterval):
+		}
+	}
+}
+
+// Marks the record of the event as processed. Records that were already processed are left untouched
+func (r *OutboxRelay) Ack(ctx context.Context, event datarepo.ChangeEvent) error {
+	id, err := strconv.ParseUint(event.ID, 10, 64)
+	if err != nil {
+		return err
+	}
+	return r.markProcessed(id)
+}
+
+// Deletes the processed records older than the Retention
+func (r *OutboxRelay) Cleanup(ctx context.Context) error {
+	r.lastCleanup = time.Now()
+	return r.db.Table(r.options.TableName).
+		Where("processed_at IS NOT NULL AND processed_at < ?", time.Now().Add(-r.options.Retention)).
+		Delete(&OutboxRecord{}).Error
+}
+
+// reads the next batch of pending records
+func (r *OutboxRelay) poll() error {
+	var records []OutboxRecord
+	err := r.db.Table(r.options.TableName).
+		Where("processed_at IS NULL AND id > ?", r.lastID).
+		Order("id").
+		Limit(r.options.BatchSize).
+		Find(&records).Error
+	if err != nil {
+		return err
+	}
+	if len(records) > 0 {
+		r.lastID = records[len(records)-1].ID
+	}
+	r.pending = records
+	return nil
+}
+
+func (r *OutboxRelay) markProcessed(id uint64) error {
+	return r.db.Table(r.options.TableName).
+		Where("id = ? AND processed_at IS NULL", id).
+		Update("processed_at", time.Now()).Error
+}
+
+// Creates the change event of a record. The keys are left as json.Number values and the rows as
+// json.RawMessage values, which the ChangeEventConsumer converts to the types of the repository
+func recordChangeEvent(record OutboxRecord) (datarepo.ChangeEvent, error) {
+	var keys map[string][]interface{}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(record.Keys)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&keys); err != nil {
+		return datarepo.ChangeEvent{}, err
+	}
+	event := datarepo.ChangeEvent{
+		ID:        strconv.FormatUint(record.ID, 10),
+		Entity:    record.Entity,
+		Operation: datarepo.ChangeOperation(record.Operation),
+		Keys:      keys,
+	}
+	if record.OldValue != "" {
+		event.OldValue = json.RawMessage(record.OldValue)
+	}
+	if record.NewValue != "" {
+		event.NewValue = json.RawMessage(record.NewValue)
+	}
+	return event, nil
+}
@@ -0,0 +1,233 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"github.com/jinzhu/gorm"
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/drreflect"
+)
+
+// Creates a new Builder for a GORM based cached repository of values of type S that uses the typed
+// data fetchers, reading the keys of the values with the provided accessors instead of reflection.
+//
+// Besides the data fetchers, the repository behaves exactly as one created with CachedRepositoryBuilder
+func TypedCachedRepositoryBuilder[S any](db *gorm.DB, keys drreflect.FieldAccessors[S]) datarepo.Builder {
+	return TypedCachedRepositoryBuilderWithDerivedKeys(db, keys, nil)
+}
+
+// Same as TypedCachedRepositoryBuilder, but the data fetchers find the values of the caches defined with a
+// KeyFunc using the query hooks of the derived keys
+func TypedCachedRepositoryBuilderWithDerivedKeys[S any](db *gorm.DB, keys drreflect.FieldAccessors[S], derivedKeys DerivedKeys) datarepo.Builder {
+	if db == nil {
+		panic("The gorm DB instance must not be nil")
+	}
+	dataType := new(S)
+	builder := datarepo.CachedRepositoryBuilder(dataType).
+		WithUniqueKeyDataFetcher(NewTypedUniqueKeyDataFetcherWithDerivedKeys(db, keys, derivedKeys)).
+		WithNonUniqueKeyDataFetcher(NewTypedNonUniqueKeyDataFetcherWithDerivedKeys(db, keys, derivedKeys)).
+		WithDataWriter(NewDataWriter(db, dataType))
+	return builder
+}
+
+// Same as NewUniqueKeyDataFetcher for values of type S, reading the keys of the values with the provided
+// accessors instead of reflection
+func NewTypedUniqueKeyDataFetcher[S any](db *gorm.DB, keys drreflect.FieldAccessors[S]) datarepo.DataFetcher {
+	return NewTypedUniqueKeyDataFetcherWithDerivedKeys(db, keys, nil)
+}
+
+// Same as NewTypedUniqueKeyDataFetcher, but the values of the derived keys are found with their query hooks
+func NewTypedUniqueKeyDataFetcherWithDerivedKeys[S any](db *gorm.DB, keys drreflect.FieldAccessors[S], derivedKeys DerivedKeys) datarepo.DataFetcher {
+	return &typedUniqueDataFetcher[S]{newTypedDataFetcher(db, keys, derivedKeys)}
+}
+
+// Same as NewNonUniqueKeyDataFetcher for values of type S, reading the keys of the values with the provided
+// accessors instead of reflection
+func NewTypedNonUniqueKeyDataFetcher[S any](db *gorm.DB, keys drreflect.FieldAccessors[S]) datarepo.DataFetcher {
+	return NewTypedNonUniqueKeyDataFetcherWithDerivedKeys(db, keys, nil)
+}
+
+// Same as NewTypedNonUniqueKeyDataFetcher, but the values of the derived keys are found with their query hooks
+func NewTypedNonUniqueKeyDataFetcherWithDerivedKeys[S any](db *gorm.DB, keys drreflect.FieldAccessors[S], derivedKeys DerivedKeys) datarepo.DataFetcher {
+	return &typedNonUniqueDataFetcher[S]{newTypedDataFetcher(db, keys, derivedKeys)}
+}
+
+type typedDataFetcher[S any] struct {
+	db                *gorm.DB
+	keys              drreflect.FieldAccessors[S]
+	typeHandler       drreflect.StructTypeHandler
+	fieldToColumnName map[string]string
+	derivedKeys       DerivedKeys
+}
+
+func newTypedDataFetcher[S any](db *gorm.DB, keys drreflect.FieldAccessors[S], derivedKeys DerivedKeys) typedDataFetcher[S] {
+	dataType := new(S)
+	return typedDataFetcher[S]{
+		db:                db,
+		keys:              keys,
+		typeHandler:       drreflect.NewReflectStructTypeHandlerFromValue(dataType),
+		fieldToColumnName: getFieldToColumnNames(db, dataType),
+		derivedKeys:       derivedKeys,
+	}
+}
+
+// loads the values of the ids, returning them together with the function that reads their key. The ids are
+// matched with the derived keys, the composite keys or the column of the key field
+func (f *typedDataFetcher[S]) find(keyFieldName string, ids []interface{}) ([]*S, func(*S) (interface{}, bool), error) {
+	if derivedKey, ok := f.derivedKeys[keyFieldName]; ok {
+		var values []*S
+		if err := derivedKey.Query(f.db, ids).Find(&values).Error; err != nil {
+			return nil, nil, err
+		}
+		key := func(value *S) (interface{}, bool) {
+			return derivedKey.Key(value)
+		}
+		return values, key, nil
+	}
+	if fields := datarepo.CompositeKeyFields(keyFieldName); fields != nil {
+		return f.findComposite(fields, ids)
+	}
+	columnName, ok := f.fieldToColumnName[keyFieldName]
+	if !ok {
+		return nil, nil, errors.New("column name not defined for: " + keyFieldName)
+	}
+
+	var values []*S
+	if err := f.db.Find(&values, columnName+" IN (?)", ids).Error; err != nil {
+		return nil, nil, err
+	}
+	field := f.field(keyFieldName)
+	key := func(value *S) (interface{}, bool) {
+		return field(value), true
+	}
+	return values, key, nil
+}
+
+// loads the values of the composite keys, which are read as tuples
+func (f *typedDataFetcher[S]) findComposite(fields []string, ids []interface{}) ([]*S, func(*S) (interface{}, bool), error) {
+	columnNames, err := compositeKeyColumns(f.fieldToColumnName, fields)
+	if err != nil {
+		return nil, nil, err
+	}
+	var values []*S
+	if len(ids) > 0 {
+		query, err := whereCompositeKeys(f.db, columnNames, ids)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := query.Find(&values).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	accessors := make([]func(*S) interface{}, len(fields))
+	for i, field := range fields {
+		accessors[i] = f.field(field)
+	}
+	key := func(value *S) (interface{}, bool) {
+		tuple := make([]interface{}, len(accessors))
+		for i, accessor := range accessors {
+			if tuple[i] = accessor(value); tuple[i] == nil {
+				return nil, false
+			}
+		}
+		return datarepo.NewTuple(tuple...), true
+	}
+	return values, key, nil
+}
+
+// returns the function that reads a field, which uses reflection for the fields without an accessor
+func (f *typedDataFetcher[S]) field(fieldName string) func(*S) interface{} {
+	if accessor, ok := f.keys[fieldName]; ok {
+		return accessor
+	}
+	return func(value *S) interface{} {
+		return f.typeHandler.GetFieldValue(value, fieldName)
+	}
+}
+
+func (f *typedDataFetcher[S]) ListKeys(ctx context.Context, keyFieldName string, after interface{}, limit int) ([]interface{}, error) {
+	return listKeys(ctx, f.db, f.typeHandler, f.fieldToColumnName, keyFieldName, after, limit)
+}
+
+func (f *typedDataFetcher[S]) SampleKeys(ctx context.Context, keyFieldName string, n int) ([]interface{}, error) {
+	return sampleKeys(ctx, f.db, f.typeHandler, f.fieldToColumnName, keyFieldName, n)
+}
+
+func (f *typedDataFetcher[S]) Ping(ctx context.Context) error {
+	return ping(ctx, f.db)
+}
+
+type typedUniqueDataFetcher[S any] struct {
+	typedDataFetcher[S]
+}
+
+func (u *typedUniqueDataFetcher[S]) FindByKey(ctx context.Context, keyFieldName string, id interface{}) (datarepo.Result, error) {
+	result, err := u.FindByKeys(ctx, keyFieldName, []interface{}{id})
+	if err != nil {
+		return nil, err
+	}
+	return result[0], err
+}
+
+func (u *typedUniqueDataFetcher[S]) FindByKeys(ctx context.Context, keyFieldName string, ids []interface{}) ([]datarepo.Result, error) {
+	values, key, err := u.find(keyFieldName, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsPerId := make(map[interface{}]*S, len(values))
+	for _, value := range values {
+		if keyValue, ok := key(value); ok {
+			resultsPerId[keyValue] = value
+		}
+	}
+	result := make([]datarepo.Result, len(ids))
+	for i, id := range ids {
+		if value, ok := resultsPerId[id]; ok {
+			result[i] = datarepo.ValueResult{Value: value}
+		} else {
+			result[i] = datarepo.EmptyResult{}
+		}
+	}
+	return result, nil
+}
+
+type typedNonUniqueDataFetcher[S any] struct {
+	typedDataFetcher[S]
+}
+
+func (u *typedNonUniqueDataFetcher[S]) FindByKey(ctx context.Context, keyFieldName string, id interface{}) (datarepo.Result, error) {
+	result, err := u.FindByKeys(ctx, keyFieldName, []interface{}{id})
+	if err != nil {
+		return nil, err
+	}
+	return result[0], err
+}
+
+func (u *typedNonUniqueDataFetcher[S]) FindByKeys(ctx context.Context, keyFieldName string, ids []interface{}) ([]datarepo.Result, error) {
+	values, key, err := u.find(keyFieldName, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsPerId := make(map[interface{}]*[]*S)
+	for _, value := range values {
+		keyValue, ok := key(value)
+		if !ok {
+			continue
+		}
+		if _, ok := resultsPerId[keyValue]; !ok {
+			resultsPerId[keyValue] = &[]*S{}
+		}
+		*resultsPerId[keyValue] = append(*resultsPerId[keyValue], value)
+	}
+	result := make([]datarepo.Result, len(ids))
+	for i, id := range ids {
+		if value, ok := resultsPerId[id]; ok {
+			result[i] = datarepo.ValueResult{Value: value}
+		} else {
+			result[i] = datarepo.EmptyResult{}
+		}
+	}
+	return result, nil
+}
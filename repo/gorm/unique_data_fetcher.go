@@ -2,7 +2,6 @@ package gorm
 
 import (
 	"context"
-	"errors"
 	"github.com/jinzhu/gorm"
 	"github.com/merlinapp/datarepo-go"
 	"github.com/merlinapp/datarepo-go/drreflect"
@@ -12,6 +11,7 @@ type uniqueDataFetcher struct {
 	db                *gorm.DB
 	typeHandler       drreflect.StructTypeHandler
 	fieldToColumnName map[string]string
+	derivedKeys       DerivedKeys
 }
 
 func (u *uniqueDataFetcher) FindByKey(ctx context.Context, keyFieldName string, id interface{}) (datarepo.Result, error) {
@@ -23,13 +23,7 @@ func (u *uniqueDataFetcher) FindByKey(ctx context.Context, keyFieldName string,
 }
 
 func (u *uniqueDataFetcher) FindByKeys(ctx context.Context, keyFieldName string, ids []interface{}) ([]datarepo.Result, error) {
-	dataSlice := u.typeHandler.NewPtrToSlice()
-	columnName, ok := u.fieldToColumnName[keyFieldName]
-	if !ok {
-		return nil, errors.New("column name not defined for: " + keyFieldName)
-	}
-
-	err := u.db.Find(dataSlice.Ptr(), columnName+" IN (?)", ids).Error
+	dataSlice, key, err := findByKeys(u.db, u.typeHandler, u.fieldToColumnName, u.derivedKeys, keyFieldName, ids)
 	if err != nil {
 		return nil, err
 	}
@@ -37,7 +31,10 @@ func (u *uniqueDataFetcher) FindByKeys(ctx context.Context, keyFieldName string,
 	result := make([]datarepo.Result, len(ids))
 	resultsPerId := make(map[interface{}]drreflect.PointerVHandler)
 	proc := func(_ int, handler drreflect.PointerVHandler) {
-		keyValue := u.typeHandler.GetFieldValue(handler.Element(), keyFieldName)
+		keyValue, ok := key(handler.Element())
+		if !ok {
+			return
+		}
 		if _, ok := resultsPerId[keyValue]; !ok {
 			resultsPerId[keyValue] = u.typeHandler.NewPtrToElement()
 		}
@@ -54,3 +51,15 @@ func (u *uniqueDataFetcher) FindByKeys(ctx context.Context, keyFieldName string,
 
 	return result, err
 }
+
+func (u *uniqueDataFetcher) ListKeys(ctx context.Context, keyFieldName string, after interface{}, limit int) ([]interface{}, error) {
+	return listKeys(ctx, u.db, u.typeHandler, u.fieldToColumnName, keyFieldName, after, limit)
+}
+
+func (u *uniqueDataFetcher) SampleKeys(ctx context.Context, keyFieldName string, n int) ([]interface{}, error) {
+	return sampleKeys(ctx, u.db, u.typeHandler, u.fieldToColumnName, keyFieldName, n)
+}
+
+func (u *uniqueDataFetcher) Ping(ctx context.Context) error {
+	return ping(ctx, u.db)
+}
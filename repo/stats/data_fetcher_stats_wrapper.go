@@ -2,7 +2,9 @@ package stats
 
 import (
 	"context"
+	"errors"
 	"github.com/merlinapp/datarepo-go"
+	"sync/atomic"
 )
 
 type StatsDataFetcher interface {
@@ -25,19 +27,40 @@ func NewStatsDataFetcher(delegate datarepo.DataFetcher) StatsDataFetcher {
 }
 
 func (s *statsDataFetcher) FindByKey(ctx context.Context, keyFieldName string, id interface{}) (datarepo.Result, error) {
-	s.reads++
+	atomic.AddInt64(&s.reads, 1)
 	return s.delegate.FindByKey(ctx, keyFieldName, id)
 }
 
 func (s *statsDataFetcher) FindByKeys(ctx context.Context, keyFieldName string, ids []interface{}) ([]datarepo.Result, error) {
-	s.reads += int64(len(ids))
+	atomic.AddInt64(&s.reads, int64(len(ids)))
 	return s.delegate.FindByKeys(ctx, keyFieldName, ids)
 }
 
 func (s *statsDataFetcher) ClearStats() {
-	s.reads = 0
+	atomic.StoreInt64(&s.reads, 0)
 }
 
 func (s *statsDataFetcher) Reads() int64 {
-	return s.reads
+	return atomic.LoadInt64(&s.reads)
+}
+
+func (s *statsDataFetcher) ListKeys(ctx context.Context, keyFieldName string, after interface{}, limit int) ([]interface{}, error) {
+	if l, ok := s.delegate.(datarepo.KeyLister); ok {
+		return l.ListKeys(ctx, keyFieldName, after, limit)
+	}
+	return nil, errors.New("the data fetcher can't list keys for: " + keyFieldName)
+}
+
+func (s *statsDataFetcher) SampleKeys(ctx context.Context, keyFieldName string, n int) ([]interface{}, error) {
+	if l, ok := s.delegate.(datarepo.KeySampler); ok {
+		return l.SampleKeys(ctx, keyFieldName, n)
+	}
+	return nil, errors.New("the data fetcher can't sample keys for: " + keyFieldName)
+}
+
+func (s *statsDataFetcher) Ping(ctx context.Context) error {
+	if p, ok := s.delegate.(datarepo.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
 }
@@ -3,6 +3,7 @@ package stats
 import (
 	"context"
 	"github.com/merlinapp/datarepo-go"
+	"sync/atomic"
 )
 
 type StatsDataWriter interface {
@@ -28,29 +29,36 @@ func NewStatsDataWriter(delegate datarepo.DataWriter) StatsDataWriter {
 }
 
 func (s *statsDataWriter) Create(ctx context.Context, value interface{}) error {
-	s.creates++
+	atomic.AddInt64(&s.creates, 1)
 	return s.delegate.Create(ctx, value)
 }
 
 func (s *statsDataWriter) Update(ctx context.Context, value interface{}) error {
-	s.updates++
+	atomic.AddInt64(&s.updates, 1)
 	return s.delegate.Update(ctx, value)
 }
 
 func (s *statsDataWriter) PartialUpdate(ctx context.Context, value interface{}) error {
-	s.updates++
+	atomic.AddInt64(&s.updates, 1)
 	return s.delegate.PartialUpdate(ctx, value)
 }
 
 func (s *statsDataWriter) ClearStats() {
-	s.creates = 0
-	s.updates = 0
+	atomic.StoreInt64(&s.creates, 0)
+	atomic.StoreInt64(&s.updates, 0)
 }
 
 func (s *statsDataWriter) Creates() int64 {
-	return s.creates
+	return atomic.LoadInt64(&s.creates)
 }
 
 func (s *statsDataWriter) Updates() int64 {
-	return s.updates
+	return atomic.LoadInt64(&s.updates)
+}
+
+func (s *statsDataWriter) Ping(ctx context.Context) error {
+	if p, ok := s.delegate.(datarepo.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
 }
@@ -2,11 +2,20 @@ package datarepo
 
 import (
 	"github.com/merlinapp/datarepo-go/drreflect"
+	"time"
 )
 
+// Source of the results read from the DataFetcher
+const DataFetcherSource = "datafetcher"
+
 type Result interface {
 	IsEmpty() bool
+	// Sets the value of the result into out, which must be a pointer to a variable of the type of the value.
+	// Panics if the result is empty or out isn't of the expected type, see Inject for a version that doesn't
 	InjectResult(out interface{})
+	// Same as InjectResult, but returns ErrNotFound if the result is empty, or an error wrapping
+	// ErrTypeMismatch if out isn't of the expected type, instead of panicking
+	Inject(out interface{}) error
 	StoredValue() interface{}
 }
 
@@ -21,6 +30,10 @@ func (e EmptyResult) InjectResult(out interface{}) {
 	panic("cannot inject an empty result")
 }
 
+func (e EmptyResult) Inject(out interface{}) error {
+	return ErrNotFound
+}
+
 func (e EmptyResult) StoredValue() interface{} {
 	panic("cannot get the value of an empty result")
 }
@@ -38,6 +51,14 @@ func (r ValueResult) InjectResult(out interface{}) {
 	r.injectResult(ph)
 }
 
+func (r ValueResult) Inject(out interface{}) error {
+	ph, err := drreflect.NewReflectPointerVHandlerE(out)
+	if err != nil {
+		return err
+	}
+	return ph.SetElementE(r.Value)
+}
+
 func (r ValueResult) StoredValue() interface{} {
 	return r.Value
 }
@@ -46,6 +67,68 @@ func (r ValueResult) injectResult(out drreflect.PointerVHandler) {
 	out.SetElement(r.Value)
 }
 
+// Metadata about where a result was read from, returned when reading with the WithMetadata read option
+type ResultMetadata struct {
+	// Tier the result was read from: the Source reported by the CacheStore, for example "redis" or "memory",
+	// or DataFetcherSource if it was read from the DataFetcher
+	Source string
+	// Time when the value was stored in the cache. It's zero if the value was read from the DataFetcher or
+	// the CacheStore doesn't know when it was stored
+	StoredAt time.Time
+	// Remaining time to live of the cache entry when it was read. It's zero if the value was read from
+	// the DataFetcher, or the entry doesn't expire or the CacheStore doesn't know when it expires
+	TTL time.Duration
+}
+
+// Returns how long ago the value was stored in the cache, zero if it isn't known
+func (m ResultMetadata) Age() time.Duration {
+	if m.StoredAt.IsZero() {
+		return 0
+	}
+	return time.Since(m.StoredAt)
+}
+
+// A Result that carries metadata about where it was read from
+type MetadataResult interface {
+	Result
+	Metadata() ResultMetadata
+}
+
+// Returns the metadata of the result. The second return value is false if the result doesn't carry
+// metadata, which is the case unless it was read with the WithMetadata read option
+func GetResultMetadata(r Result) (ResultMetadata, bool) {
+	if mr, ok := r.(MetadataResult); ok {
+		return mr.Metadata(), true
+	}
+	return ResultMetadata{}, false
+}
+
+type resultWithMetadata struct {
+	Result
+	metadata ResultMetadata
+}
+
+func (r resultWithMetadata) Metadata() ResultMetadata {
+	return r.metadata
+}
+
+// attaches the metadata to a non-empty result
+func withMetadata(r Result, metadata ResultMetadata) Result {
+	if r.IsEmpty() {
+		return r
+	}
+	return resultWithMetadata{Result: r, metadata: metadata}
+}
+
+// creates the metadata of a result read from a cache entry
+func cachedResultMetadata(metadata EntryMetadata) ResultMetadata {
+	result := ResultMetadata{Source: metadata.Source, StoredAt: metadata.StoredAt}
+	if !metadata.ExpiresAt.IsZero() {
+		result.TTL = time.Until(metadata.ExpiresAt)
+	}
+	return result
+}
+
 func InjectResults(r []Result, out interface{}) {
 	handler := drreflect.NewReflectSlicePointerVHandler(out)
 	mappingFunction := func(_ int, pointerHandler drreflect.PointerVHandler, in interface{}) {
@@ -58,3 +141,26 @@ func InjectResults(r []Result, out interface{}) {
 	}
 	handler.CopyFrom(r, mappingFunction)
 }
+
+// Same as InjectResults, but returns an error wrapping ErrTypeMismatch instead of panicking if out isn't a
+// pointer to a slice of the type of the values of the results. The slice may be partially populated when an
+// error is returned
+func InjectResultsE(r []Result, out interface{}) error {
+	handler, err := drreflect.NewReflectSlicePointerVHandlerE(out)
+	if err != nil {
+		return err
+	}
+	mappingFunction := func(_ int, pointerHandler drreflect.PointerVHandler, in interface{}) {
+		if err != nil {
+			return
+		}
+		r := in.(Result)
+		if r.IsEmpty() {
+			pointerHandler.SetZeroElement()
+		} else {
+			err = r.Inject(pointerHandler.Ptr())
+		}
+	}
+	handler.CopyFrom(r, mappingFunction)
+	return err
+}
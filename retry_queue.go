@@ -0,0 +1,197 @@
+package datarepo
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A cache eviction that failed after data was written to the repository and needs to be retried
+type CacheEviction struct {
+	// Identifier assigned by the RetryQueue when the eviction is enqueued
+	ID uint64
+	// Key prefix of the cache the entry belongs to
+	CacheKeyPrefix string
+	// Key of the entry to evict from the cache store
+	Key string
+	// Number of failed attempts to evict the entry
+	Attempts int
+	// Time after which the eviction should be attempted again
+	NextAttempt time.Time
+}
+
+// A RetryQueue keeps the cache evictions that failed after data was written to a repository, so they
+// can be replayed until they succeed.
+//
+// Implementations must be safe for concurrent use
+type RetryQueue interface {
+	// Adds the eviction to the queue, assigning it a new ID
+	Enqueue(eviction CacheEviction) error
+	// Returns up to limit evictions whose NextAttempt is not after the provided time. The evictions remain
+	// in the queue until they're acknowledged
+	Due(now time.Time, limit int) ([]CacheEviction, error)
+	// Removes an eviction that succeeded from the queue
+	Ack(eviction CacheEviction) error
+	// Updates the Attempts and NextAttempt of an eviction that failed again
+	Retry(eviction CacheEviction) error
+}
+
+// Options of the background worker that replays the evictions of a RetryQueue
+type RetryOptions struct {
+	// How often the queue is checked for due evictions. Defaults to 1 second
+	PollInterval time.Duration
+	// Delay before the first retry of an eviction, doubled after every failed attempt. Defaults to 100 milliseconds
+	InitialBackoff time.Duration
+	// Maximum delay between retries of an eviction. Defaults to 1 minute
+	MaxBackoff time.Duration
+	// Maximum number of evictions replayed on every poll. Defaults to 100
+	BatchSize int
+}
+
+// Error returned by the write operations of a CachedRepository when the data was written successfully
+// but the caches couldn't be updated or evicted.
+//
+// The caches that failed may hold stale data. If Retried is true, then the evictions of those caches were
+// enqueued in the RetryQueue of the repository and will be replayed until they succeed, otherwise the
+// caller should evict the affected entries, for example with ReadOnlyCachedRepository.Invalidate
+type CacheMaintenanceError struct {
+	// Errors found while maintaining the caches
+	Errors []error
+	// Indicates that all the affected cache entries were enqueued for eviction in the RetryQueue
+	Retried bool
+}
+
+func (e *CacheMaintenanceError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return "data was written but the caches couldn't be maintained: " + strings.Join(messages, "; ")
+}
+
+// Always returns true, as a CacheMaintenanceError is only returned after the data was written successfully
+func (e *CacheMaintenanceError) WriteSucceeded() bool {
+	return true
+}
+
+func (o RetryOptions) withDefaults() RetryOptions {
+	if o.PollInterval <= 0 {
+		o.PollInterval = time.Second
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = 100 * time.Millisecond
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = time.Minute
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 100
+	}
+	return o
+}
+
+// returns the delay before the next attempt of an eviction that failed the given number of times
+func (o RetryOptions) backoff(attempts int) time.Duration {
+	delay := o.InitialBackoff
+	for i := 1; i < attempts && delay < o.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > o.MaxBackoff {
+		delay = o.MaxBackoff
+	}
+	return delay
+}
+
+// Replays the evictions of a RetryQueue in the background until they succeed
+type retryWorker struct {
+	queue   RetryQueue
+	options RetryOptions
+	caches  map[string]Cache
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+func newRetryWorker(queue RetryQueue, options RetryOptions, caches map[string]Cache) *retryWorker {
+	w := &retryWorker{
+		queue:   queue,
+		options: options.withDefaults(),
+		caches:  make(map[string]Cache, len(caches)),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for _, c := range caches {
+		w.caches[c.Handler.CacheKeyPrefix()] = c
+	}
+	return w
+}
+
+// Enqueues the eviction of the cache entry the value belongs to
+func (w *retryWorker) enqueue(cache Cache, value interface{}) error {
+	key, ok := cache.Handler.ValueCacheKey(value)
+	if !ok {
+		return nil
+	}
+	return w.queue.Enqueue(CacheEviction{
+		CacheKeyPrefix: cache.Handler.CacheKeyPrefix(),
+		Key:            key,
+		NextAttempt:    time.Now().Add(w.options.InitialBackoff),
+	})
+}
+
+func (w *retryWorker) start() {
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(w.options.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				w.replay()
+			}
+		}
+	}()
+}
+
+// attempts the evictions that are due, rescheduling the ones that fail again
+func (w *retryWorker) replay() {
+	evictions, err := w.queue.Due(time.Now(), w.options.BatchSize)
+	if err != nil {
+		log.Println("Error reading cache evictions from the retry queue -", err)
+		return
+	}
+	for _, eviction := range evictions {
+		cache, ok := w.caches[eviction.CacheKeyPrefix]
+		if ok {
+			err = cache.Store.Delete(context.Background(), eviction.Key)
+		}
+		if !ok || err == nil {
+			// evictions of caches that are no longer defined can't be replayed
+			err = w.queue.Ack(eviction)
+		} else {
+			eviction.Attempts++
+			eviction.NextAttempt = time.Now().Add(w.options.backoff(eviction.Attempts))
+			err = w.queue.Retry(eviction)
+		}
+		if err != nil {
+			log.Println("Error updating cache eviction for key: ", eviction.Key, "-", err)
+		}
+	}
+}
+
+// Stops the worker, waiting for the replay in progress to finish until the context is done
+func (w *retryWorker) Shutdown(ctx context.Context) error {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,227 @@
+package file
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/retryqueue/internal/evictions"
+	"os"
+	"sync"
+	"time"
+)
+
+// A RetryQueue persisted in a local journal file
+type JournalRetryQueue interface {
+	datarepo.RetryQueue
+	// Closes the journal file. The queue can't be used after it's closed
+	Close() error
+}
+
+const (
+	opEnqueue = "enqueue"
+	opAck     = "ack"
+	opRetry   = "retry"
+)
+
+// number of journal records written after which the journal is compacted if most of them are obsolete
+const compactionThreshold = 1000
+
+// a single change of the queue, stored as a line in the journal
+type record struct {
+	Op       string                 `json:"op"`
+	Eviction datarepo.CacheEviction `json:"eviction"`
+}
+
+type journalRetryQueue struct {
+	mu        sync.Mutex
+	path      string
+	file      *os.File
+	records   int
+	evictions *evictions.Set
+}
+
+// Creates a new RetryQueue that persists the failed cache evictions in a journal file at the provided path,
+// so they can be replayed even if the process stops before they succeed.
+//
+// If the file exists, the pending evictions are loaded from it and the file is compacted. Every change
+// to the queue is synced to disk before the operation returns
+func NewJournalRetryQueue(path string) (JournalRetryQueue, error) {
+	q := &journalRetryQueue{
+		path:      path,
+		evictions: evictions.NewSet(),
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	if err := q.compact(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *journalRetryQueue) Enqueue(eviction datarepo.CacheEviction) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.ensureOpen(); err != nil {
+		return err
+	}
+	eviction = q.evictions.Add(eviction)
+	if err := q.append(record{Op: opEnqueue, Eviction: eviction}); err != nil {
+		q.evictions.Remove(eviction.ID)
+		return err
+	}
+	return nil
+}
+
+func (q *journalRetryQueue) Due(now time.Time, limit int) ([]datarepo.CacheEviction, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.ensureOpen(); err != nil {
+		return nil, err
+	}
+	return q.evictions.Due(now, limit), nil
+}
+
+func (q *journalRetryQueue) Ack(eviction datarepo.CacheEviction) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.ensureOpen(); err != nil {
+		return err
+	}
+	if err := q.append(record{Op: opAck, Eviction: eviction}); err != nil {
+		return err
+	}
+	q.evictions.Remove(eviction.ID)
+	if q.records >= compactionThreshold && q.records > 2*q.evictions.Len() {
+		return q.compact()
+	}
+	return nil
+}
+
+func (q *journalRetryQueue) Retry(eviction datarepo.CacheEviction) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.ensureOpen(); err != nil {
+		return err
+	}
+	if err := q.append(record{Op: opRetry, Eviction: eviction}); err != nil {
+		return err
+	}
+	q.evictions.Update(eviction)
+	return nil
+}
+
+func (q *journalRetryQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.file == nil {
+		return nil
+	}
+	err := q.file.Close()
+	q.file = nil
+	return err
+}
+
+func (q *journalRetryQueue) ensureOpen() error {
+	if q.file == nil {
+		return errors.New("the retry queue journal is closed: " + q.path)
+	}
+	return nil
+}
+
+// rebuilds the pending evictions from the journal file, if it exists
+func (q *journalRetryQueue) load() error {
+	f, err := os.Open(q.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// a partially written record is expected if the process stopped while writing it
+			continue
+		}
+		switch r.Op {
+		case opEnqueue:
+			q.evictions.Put(r.Eviction)
+		case opAck:
+			q.evictions.Remove(r.Eviction.ID)
+		case opRetry:
+			q.evictions.Update(r.Eviction)
+		}
+	}
+	return scanner.Err()
+}
+
+// rewrites the journal file with the pending evictions only
+func (q *journalRetryQueue) compact() error {
+	tmpPath := q.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	for _, e := range q.evictions.All() {
+		if err = writeRecord(w, record{Op: opEnqueue, Eviction: e}); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cErr := tmp.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if q.file != nil {
+		q.file.Close()
+		q.file = nil
+	}
+	if err := os.Rename(tmpPath, q.path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(q.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	q.file = f
+	q.records = q.evictions.Len()
+	return nil
+}
+
+func (q *journalRetryQueue) append(r record) error {
+	w := bufio.NewWriter(q.file)
+	if err := writeRecord(w, r); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	q.records++
+	return q.file.Sync()
+}
+
+func writeRecord(w *bufio.Writer, r record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	return w.WriteByte('\n')
+}
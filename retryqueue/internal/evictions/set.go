@@ -0,0 +1,86 @@
+// Package evictions implements the bookkeeping of pending cache evictions shared by the RetryQueue
+// implementations of this library
+package evictions
+
+import (
+	"github.com/merlinapp/datarepo-go"
+	"sort"
+	"time"
+)
+
+// A Set of pending cache evictions by ID. A Set isn't safe for concurrent use
+type Set struct {
+	lastID    uint64
+	evictions map[uint64]datarepo.CacheEviction
+}
+
+func NewSet() *Set {
+	return &Set{evictions: make(map[uint64]datarepo.CacheEviction)}
+}
+
+// Adds the eviction to the set assigning it a new ID, and returns the eviction with the assigned ID
+func (s *Set) Add(eviction datarepo.CacheEviction) datarepo.CacheEviction {
+	s.lastID++
+	eviction.ID = s.lastID
+	s.evictions[eviction.ID] = eviction
+	return eviction
+}
+
+// Adds or replaces the eviction keeping the ID it already has
+func (s *Set) Put(eviction datarepo.CacheEviction) {
+	if eviction.ID > s.lastID {
+		s.lastID = eviction.ID
+	}
+	s.evictions[eviction.ID] = eviction
+}
+
+// Updates the eviction if it's in the set. Returns false if it isn't
+func (s *Set) Update(eviction datarepo.CacheEviction) bool {
+	if _, ok := s.evictions[eviction.ID]; !ok {
+		return false
+	}
+	s.evictions[eviction.ID] = eviction
+	return true
+}
+
+// Removes the eviction with the given ID from the set
+func (s *Set) Remove(id uint64) {
+	delete(s.evictions, id)
+}
+
+// Returns up to limit evictions due at the provided time, the ones due earlier first
+func (s *Set) Due(now time.Time, limit int) []datarepo.CacheEviction {
+	due := make([]datarepo.CacheEviction, 0)
+	for _, e := range s.evictions {
+		if !e.NextAttempt.After(now) {
+			due = append(due, e)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].NextAttempt.Equal(due[j].NextAttempt) {
+			return due[i].ID < due[j].ID
+		}
+		return due[i].NextAttempt.Before(due[j].NextAttempt)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	return due
+}
+
+// Returns all the evictions in the set ordered by ID
+func (s *Set) All() []datarepo.CacheEviction {
+	all := make([]datarepo.CacheEviction, 0, len(s.evictions))
+	for _, e := range s.evictions {
+		all = append(all, e)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].ID < all[j].ID
+	})
+	return all
+}
+
+// Returns the number of evictions in the set
+func (s *Set) Len() int {
+	return len(s.evictions)
+}
@@ -0,0 +1,47 @@
+package memory
+
+import (
+	"github.com/merlinapp/datarepo-go"
+	"github.com/merlinapp/datarepo-go/retryqueue/internal/evictions"
+	"sync"
+	"time"
+)
+
+type memoryRetryQueue struct {
+	mu        sync.Mutex
+	evictions *evictions.Set
+}
+
+// Creates a new RetryQueue that keeps the failed cache evictions in memory.
+//
+// Pending evictions are lost if the process stops, see the retryqueue/file package for a durable queue
+func NewRetryQueue() datarepo.RetryQueue {
+	return &memoryRetryQueue{evictions: evictions.NewSet()}
+}
+
+func (q *memoryRetryQueue) Enqueue(eviction datarepo.CacheEviction) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.evictions.Add(eviction)
+	return nil
+}
+
+func (q *memoryRetryQueue) Due(now time.Time, limit int) ([]datarepo.CacheEviction, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.evictions.Due(now, limit), nil
+}
+
+func (q *memoryRetryQueue) Ack(eviction datarepo.CacheEviction) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.evictions.Remove(eviction.ID)
+	return nil
+}
+
+func (q *memoryRetryQueue) Retry(eviction datarepo.CacheEviction) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.evictions.Update(eviction)
+	return nil
+}
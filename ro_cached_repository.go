@@ -2,8 +2,9 @@ package datarepo
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"github.com/merlinapp/datarepo-go/drreflect"
+	"reflect"
 )
 
 type ReadOnlyCachedRepository interface {
@@ -12,7 +13,9 @@ type ReadOnlyCachedRepository interface {
 	// - If the keyFieldName is a Unique Key of the entity, then the result will be a single element.
 	//
 	// - If the keyFieldName is not a Unique Key of the entity, then the result will be a slice of elements.
-	FindByKey(ctx context.Context, keyFieldName string, id interface{}) (Result, error)
+	//
+	// Options can be provided to change how the cache is used for this call, for example, to skip the cache
+	FindByKey(ctx context.Context, keyFieldName string, id interface{}, options ...ReadOption) (Result, error)
 	// Retrieves the data from the repository using the given keyFieldName for the given ids.
 	//
 	// - If the keyFieldName is a Unique Key of the entity, then the result will be a single element per id.
@@ -23,35 +26,133 @@ type ReadOnlyCachedRepository interface {
 	// the id in position 0
 	//
 	// Ids is expected to be a pointer to a slice or a slice of the corresponding type stored in the keyFieldName,
-	// for example, if the keyFieldName stores strings, then ids is expected to be of type *[]string or []string
-	FindByKeys(ctx context.Context, keyFieldName string, ids interface{}) ([]Result, error)
+	// for example, if the keyFieldName stores strings, then ids is expected to be of type *[]string or []string.
+	// A *FetchError wrapping ErrTypeMismatch is returned otherwise
+	//
+	// Options can be provided to change how the cache is used for this call, for example, to skip the cache
+	FindByKeys(ctx context.Context, keyFieldName string, ids interface{}, options ...ReadOption) ([]Result, error)
+	// Loads the data of the given ids from the DataFetcher and stores it in the cache defined for the keyFieldName,
+	// replacing any value already cached for those ids.
+	//
+	// Ids is expected to be a pointer to a slice or a slice of the corresponding type stored in the keyFieldName
+	Warm(ctx context.Context, keyFieldName string, ids interface{}) error
+	// Warms up the cache defined for the keyFieldName with all the data in the repository, loading pageSize keys
+	// at a time.
+	//
+	// The DataFetcher of the cache must implement the KeyLister interface. The returned progress can be used to
+	// resume the warm-up with the WarmUpFromCursor option if an error occurs.
+	WarmAll(ctx context.Context, keyFieldName string, pageSize int, options ...WarmUpOption) (WarmUpProgress, error)
+	// Evicts the entries of the given ids from the cache defined for the keyFieldName.
+	//
+	// Each id is expected to be of the corresponding type stored in the keyFieldName
+	Invalidate(ctx context.Context, keyFieldName string, ids ...interface{}) error
+	// Retrieves the data of the given ids from the DataFetcher, bypassing the cache defined for the keyFieldName,
+	// and overwrites the entries of the cache with the retrieved data. Entries of ids that no longer have data
+	// are evicted from the cache.
+	//
+	// Each element in the returned slice corresponds to an id, in the same way as in FindByKeys
+	Refresh(ctx context.Context, keyFieldName string, ids ...interface{}) ([]Result, error)
+	// Deletes every entry of the cache defined for the keyFieldName.
+	//
+	// The CacheStore of the cache must implement the FlushableCacheStore interface
+	Flush(ctx context.Context, keyFieldName string) error
+	// Increments the generation of the cache defined for the keyFieldName, which must be generational,
+	// invalidating all its entries at once. Returns the new generation.
+	//
+	// Other processes notice the new generation once their GenerationRefreshInterval elapses
+	BumpGeneration(ctx context.Context, keyFieldName string) (int64, error)
 }
 
 type readOnlyCachedRepository struct {
 	caches map[string]Cache
+	// verifies sampled cache hits, nil if read-repair is disabled
+	readRepair *readRepairer
 }
 
-func (r *readOnlyCachedRepository) FindByKey(ctx context.Context, keyFieldName string, id interface{}) (Result, error) {
-	return r.FetchSingleFromCache(ctx, keyFieldName, id)
+func (r *readOnlyCachedRepository) FindByKey(ctx context.Context, keyFieldName string, id interface{}, options ...ReadOption) (Result, error) {
+	readOptions, err := NewReadOptions(options...)
+	if err != nil {
+		return nil, err
+	}
+	return r.FetchSingleFromCache(ctx, keyFieldName, id, readOptions)
 }
 
-func (r *readOnlyCachedRepository) FindByKeys(ctx context.Context, keyFieldName string, ids interface{}) ([]Result, error) {
+func (r *readOnlyCachedRepository) FindByKeys(ctx context.Context, keyFieldName string, ids interface{}, options ...ReadOption) ([]Result, error) {
+	readOptions, err := NewReadOptions(options...)
+	if err != nil {
+		return nil, err
+	}
+	v := reflect.ValueOf(ids)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice {
+		err := fmt.Errorf("%w: ids must be a slice or a pointer to a slice, got %T", ErrTypeMismatch, ids)
+		return nil, &FetchError{KeyFieldName: keyFieldName, IDs: []interface{}{ids}, Err: err}
+	}
 	sh := drreflect.NewReflectSliceTypeHandlerFromValue(ids)
-	return r.FetchMultiFromCache(ctx, keyFieldName, sh.AsInterfaceSlice(ids))
+	return r.FetchMultiFromCache(ctx, keyFieldName, sh.AsInterfaceSlice(ids), readOptions)
 }
 
-func (r *readOnlyCachedRepository) FetchSingleFromCache(ctx context.Context, keyFieldName string, id interface{}) (Result, error) {
-	if cacheConfig, ok := r.caches[keyFieldName]; ok {
-		return cacheConfig.Get(ctx, id)
-	} else {
-		return nil, errors.New("Undefined cache for: " + keyFieldName)
+func (r *readOnlyCachedRepository) Invalidate(ctx context.Context, keyFieldName string, ids ...interface{}) error {
+	cacheConfig, ok := r.caches[keyFieldName]
+	if !ok {
+		return unknownKeyFieldError(keyFieldName)
+	}
+	for _, id := range ids {
+		if err := cacheConfig.Delete(ctx, id); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-func (r *readOnlyCachedRepository) FetchMultiFromCache(ctx context.Context, keyFieldName string, ids []interface{}) ([]Result, error) {
+func (r *readOnlyCachedRepository) Refresh(ctx context.Context, keyFieldName string, ids ...interface{}) ([]Result, error) {
 	if cacheConfig, ok := r.caches[keyFieldName]; ok {
-		return cacheConfig.GetMulti(ctx, ids)
+		return cacheConfig.Refresh(ctx, ids)
 	} else {
-		return nil, errors.New("Undefined cache for: " + keyFieldName)
+		return nil, unknownKeyFieldError(keyFieldName)
 	}
 }
+
+// Reads the id using the cache of the key field. Errors are returned as a *FetchError
+func (r *readOnlyCachedRepository) FetchSingleFromCache(ctx context.Context, keyFieldName string, id interface{}, options ReadOptions) (Result, error) {
+	cacheConfig, ok := r.caches[keyFieldName]
+	if !ok {
+		return nil, &FetchError{KeyFieldName: keyFieldName, IDs: []interface{}{id}, Err: unknownKeyFieldError(keyFieldName)}
+	}
+	result, err := cacheConfig.Get(ctx, id, options)
+	if err != nil {
+		return nil, &FetchError{KeyFieldName: keyFieldName, IDs: []interface{}{id}, Err: err}
+	}
+	return result, nil
+}
+
+// Reads the ids using the cache of the key field. Errors are returned as a *FetchError
+func (r *readOnlyCachedRepository) FetchMultiFromCache(ctx context.Context, keyFieldName string, ids []interface{}, options ReadOptions) ([]Result, error) {
+	cacheConfig, ok := r.caches[keyFieldName]
+	if !ok {
+		return nil, &FetchError{KeyFieldName: keyFieldName, IDs: ids, Err: unknownKeyFieldError(keyFieldName)}
+	}
+	results, err := cacheConfig.GetMulti(ctx, ids, options)
+	if err != nil {
+		return nil, &FetchError{KeyFieldName: keyFieldName, IDs: ids, Err: err}
+	}
+	return results, nil
+}
+
+// Waits for the read-repair verifications in progress to finish
+func (r *readOnlyCachedRepository) Shutdown(ctx context.Context) error {
+	if r.readRepair == nil {
+		return nil
+	}
+	return r.readRepair.Shutdown(ctx)
+}
+
+func (r *readOnlyCachedRepository) registeredCaches() map[string]Cache {
+	return r.caches
+}
+
+func (r *readOnlyCachedRepository) registeredWriter() DataWriter {
+	return nil
+}
@@ -0,0 +1,68 @@
+package datarepo
+
+import (
+	"github.com/spf13/cast"
+	"strconv"
+	"strings"
+)
+
+// Maximum number of fields of a composite key
+const MaxTupleSize = 8
+
+// The key of a cache defined with composite KeyFieldNames, holding the values of the fields in the same order.
+//
+// Tuples are comparable, so they can be used wherever a single value key is used, like in the ids of
+// FindByKeys, and are encoded into cache keys deterministically by String. The values of a tuple must be
+// of the same types as the fields, for example, an int field can't be matched with an int64 value
+type Tuple struct {
+	size   int
+	values [MaxTupleSize]interface{}
+}
+
+// Creates a Tuple with the provided values, which panics if there are more than MaxTupleSize values
+func NewTuple(values ...interface{}) Tuple {
+	if len(values) > MaxTupleSize {
+		panic("a tuple can't have more than " + strconv.Itoa(MaxTupleSize) + " values")
+	}
+	t := Tuple{size: len(values)}
+	copy(t.values[:], values)
+	return t
+}
+
+// Returns the values of the tuple
+func (t Tuple) Values() []interface{} {
+	values := make([]interface{}, t.size)
+	copy(values, t.values[:t.size])
+	return values
+}
+
+// Returns the number of values of the tuple
+func (t Tuple) Len() int {
+	return t.size
+}
+
+// Encodes the tuple as the values separated by ':', escaping ':' and '\' in the values with '\', which is used
+// to build the cache keys of composite keys
+func (t Tuple) String() string {
+	parts := make([]string, t.size)
+	for i, v := range t.values[:t.size] {
+		parts[i] = tupleEscaper.Replace(cast.ToString(v))
+	}
+	return strings.Join(parts, ":")
+}
+
+var tupleEscaper = strings.NewReplacer(`\`, `\\`, `:`, `\:`)
+
+// Returns the name of a cache with a composite key made of the provided fields, used to read from the cache
+// with FindByKey and the rest of the operations of a repository
+func CompositeKeyName(fieldNames ...string) string {
+	return strings.Join(fieldNames, ",")
+}
+
+// Returns the fields of a composite key from the name of its cache, or nil if the key isn't composite
+func CompositeKeyFields(keyFieldName string) []string {
+	if !strings.Contains(keyFieldName, ",") {
+		return nil
+	}
+	return strings.Split(keyFieldName, ",")
+}
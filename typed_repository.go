@@ -0,0 +1,98 @@
+package datarepo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+)
+
+// A type-safe facade over a CachedRepository storing values of type T, which reads and writes *T values
+// instead of Result and interface{} values, so that passing values of the wrong type is caught at compile time
+type TypedRepository[T any] struct {
+	repo CachedRepository
+}
+
+// Creates a TypedRepository for the provided repository.
+//
+// An error is returned if the unique key caches of the repository don't store values of type T
+func NewTypedRepository[T any](repo CachedRepository) (*TypedRepository[T], error) {
+	if repo == nil {
+		return nil, errors.New("the repository of a typed repository must not be nil")
+	}
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	for _, c := range cachesOf(repo) {
+		if c.Handler.SingleResultPerKey() && c.Handler.CachedType() != t {
+			return nil, errors.New("the repository stores values of type " + c.Handler.CachedType().String() +
+				" instead of " + t.String())
+		}
+	}
+	return &TypedRepository[T]{repo: repo}, nil
+}
+
+// Returns the underlying repository, to use the operations that aren't typed such as Warm or Invalidate
+func (r *TypedRepository[T]) Repository() CachedRepository {
+	return r.repo
+}
+
+// Retrieves the value of the id using the unique key cache defined for the keyFieldName. ErrNotFound is
+// returned if there is no value for the id
+func (r *TypedRepository[T]) Get(ctx context.Context, keyFieldName string, id interface{}, options ...ReadOption) (*T, error) {
+	result, err := r.repo.FindByKey(ctx, keyFieldName, id, options...)
+	if err != nil {
+		return nil, err
+	}
+	var value *T
+	if err := result.Inject(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Retrieves the values of the ids using the unique key cache defined for the keyFieldName. Each element in
+// the returned slice corresponds to an id, in the same way as in FindByKeys, and is nil if there is no value
+// for the id.
+//
+// Ids is expected to be a pointer to a slice or a slice of the corresponding type stored in the keyFieldName
+func (r *TypedRepository[T]) GetMany(ctx context.Context, keyFieldName string, ids interface{}, options ...ReadOption) ([]*T, error) {
+	results, err := r.repo.FindByKeys(ctx, keyFieldName, ids, options...)
+	if err != nil {
+		return nil, err
+	}
+	var values []*T
+	if err := InjectResultsE(results, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Retrieves the values of the id using the non-unique key cache defined for the keyFieldName. An empty
+// slice is returned if there are no values for the id
+func (r *TypedRepository[T]) GetList(ctx context.Context, keyFieldName string, id interface{}, options ...ReadOption) ([]*T, error) {
+	result, err := r.repo.FindByKey(ctx, keyFieldName, id, options...)
+	if err != nil {
+		return nil, err
+	}
+	if result.IsEmpty() {
+		return []*T{}, nil
+	}
+	var values []*T
+	if err := result.Inject(&values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Same as CachedRepository.Create
+func (r *TypedRepository[T]) Create(ctx context.Context, value *T) error {
+	return r.repo.Create(ctx, value)
+}
+
+// Same as CachedRepository.Update
+func (r *TypedRepository[T]) Update(ctx context.Context, value *T) error {
+	return r.repo.Update(ctx, value)
+}
+
+// Same as CachedRepository.PartialUpdate, the value is replaced with the full value stored after the update
+func (r *TypedRepository[T]) PartialUpdate(ctx context.Context, value *T) error {
+	return r.repo.PartialUpdate(ctx, value)
+}
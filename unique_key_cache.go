@@ -16,13 +16,18 @@ func UniqueKeyCache(v interface{}, cacheDefinition UniqueKeyCacheDefinition) Han
 	definition := uniqueKeyCacheHandler{
 		baseCacheHandler{
 			keyPrefix:    cacheDefinition.KeyPrefix,
-			keyFieldName: cacheDefinition.KeyFieldName,
+			keyFieldName: cacheDefinition.cacheName(),
+			keyFields:    CompositeKeyFields(cacheDefinition.cacheName()),
+			keyFunc:      cacheDefinition.KeyFunc,
 			expiration:   cacheDefinition.Expiration,
 			typeHandler:  th,
 		},
 		th,
 	}
 	definition.validateConfiguration()
+	if cacheDefinition.PrimaryKeyFieldName != "" {
+		return newIndirectUniqueKeyCacheHandler(definition, cacheDefinition.PrimaryKeyFieldName)
+	}
 	return &definition
 }
 
@@ -31,20 +36,46 @@ func (c *uniqueKeyCacheHandler) SingleResultPerKey() bool {
 }
 
 func (c *uniqueKeyCacheHandler) DeleteValue(ctx context.Context, cacheStore CacheStore, value interface{}) error {
-	return cacheStore.Delete(ctx, c.cacheKeyFromValue(value))
+	key, ok := c.ValueCacheKey(value)
+	if !ok {
+		return nil
+	}
+	return cacheStore.Delete(ctx, key)
 }
 
-func (c *uniqueKeyCacheHandler) Set(ctx context.Context, cacheStore CacheStore, value interface{}) error {
-	cacheStore.Set(ctx, c.cacheKeyFromValue(value), value, c.expiration)
-	return nil
+// Values with a nil key, like a nil pointer field, aren't cached
+func (c *uniqueKeyCacheHandler) ValueKey(value interface{}) (interface{}, bool) {
+	return c.valueKey(c.subTypeHandler, value)
 }
 
-func (c *uniqueKeyCacheHandler) cacheKeyFromValue(value interface{}) string {
-	return c.cacheKey(cast.ToString(c.getFieldValue(value, c.keyFieldName)))
+func (c *uniqueKeyCacheHandler) ValueCacheKey(value interface{}) (string, bool) {
+	key, ok := c.ValueKey(value)
+	return c.cacheKey(cast.ToString(key)), ok
 }
 
-func (c *uniqueKeyCacheHandler) getFieldValue(value interface{}, fieldName string) interface{} {
-	return c.subTypeHandler.GetFieldValue(value, fieldName)
+func (c *uniqueKeyCacheHandler) ValueSubKey(value interface{}) (interface{}, bool) {
+	return nil, false
+}
+
+func (c *uniqueKeyCacheHandler) Set(ctx context.Context, cacheStore CacheStore, value interface{}) error {
+	key, ok := c.ValueCacheKey(value)
+	if !ok {
+		return nil
+	}
+	return c.setWritten(ctx, cacheStore, key, value)
+}
+
+// stores the value unless its entry is already cached
+func (c *uniqueKeyCacheHandler) setMissing(ctx context.Context, cacheStore CacheStore, value interface{}) error {
+	key, ok := c.ValueCacheKey(value)
+	if !ok {
+		return nil
+	}
+	found, err := cacheStore.Get(ctx, key, c.typeHandler.NewPtrToElement().Ptr())
+	if err != nil || found {
+		return err
+	}
+	return TrySet(ctx, cacheStore, key, value, c.expiration)
 }
 
 func (c *uniqueKeyCacheHandler) validateConfiguration() {
@@ -0,0 +1,112 @@
+package datarepo
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Defines what happens to the entries of a cache when data is written to the repository
+type WritePolicy int
+
+const (
+	// Uses the policy defined for the whole repository with Builder.EvictAfterWrite: WritePolicyEvict if
+	// eviction was requested or WritePolicyUpdate otherwise
+	WritePolicyDefault WritePolicy = iota
+	// Updates the entries of the cache with the written value
+	WritePolicyUpdate
+	// Evicts the entries of the cache affected by the written value
+	WritePolicyEvict
+	// Leaves the cache untouched, the cache entries will be refreshed once they expire
+	WritePolicyIgnore
+	// Evicts the entries of the cache affected by the written value and evicts them again after a delay, to
+	// remove values cached by concurrent reads that fetched the data before the write was committed
+	WritePolicyDelayedDoubleDelete
+)
+
+// Delay used by WritePolicyDelayedDoubleDelete when the cache definition doesn't define one
+const DefaultDoubleDeleteDelay = time.Second
+
+func (p WritePolicy) String() string {
+	switch p {
+	case WritePolicyDefault:
+		return "Default"
+	case WritePolicyUpdate:
+		return "Update"
+	case WritePolicyEvict:
+		return "Evict"
+	case WritePolicyIgnore:
+		return "Ignore"
+	case WritePolicyDelayedDoubleDelete:
+		return "DelayedDoubleDelete"
+	default:
+		return "WritePolicy(" + strconv.Itoa(int(p)) + ")"
+	}
+}
+
+func (p WritePolicy) isValid() bool {
+	return p >= WritePolicyDefault && p <= WritePolicyDelayedDoubleDelete
+}
+
+// Performs the second eviction of the WritePolicyDelayedDoubleDelete policy in the background
+type delayedEvictor struct {
+	wg   sync.WaitGroup
+	stop chan struct{}
+	once sync.Once
+	// worker of the RetryQueue the evictions that fail are enqueued in, nil if the repository has none
+	retryWorker *retryWorker
+}
+
+func newDelayedEvictor(retryWorker *retryWorker) *delayedEvictor {
+	return &delayedEvictor{stop: make(chan struct{}), retryWorker: retryWorker}
+}
+
+// Evicts the value from the cache once the delay elapses, or immediately if the evictor is shut down. The
+// eviction is enqueued in the RetryQueue of the repository if it fails
+func (e *delayedEvictor) evictAfter(cache Cache, value interface{}, delay time.Duration) {
+	// the caller may modify the value after the write, so a copy is kept to evict the same entries
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Ptr && !v.IsNil() {
+		cp := reflect.New(v.Elem().Type())
+		cp.Elem().Set(v.Elem())
+		value = cp.Interface()
+	}
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-e.stop:
+		}
+		if err := cache.DeleteValue(context.Background(), value); err != nil {
+			log.Println("Error performing delayed cache eviction for cache with prefix: ", cache.Handler.CacheKeyPrefix(), "-", err)
+			if e.retryWorker != nil {
+				if qErr := e.retryWorker.enqueue(cache, value); qErr != nil {
+					log.Println("Error enqueuing delayed cache eviction for cache with prefix: ", cache.Handler.CacheKeyPrefix(), "-", qErr)
+				}
+			}
+		}
+	}()
+}
+
+// Performs the pending evictions immediately and waits for them to finish until the context is done
+func (e *delayedEvictor) Shutdown(ctx context.Context) error {
+	e.once.Do(func() {
+		close(e.stop)
+	})
+	done := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}